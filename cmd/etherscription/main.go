@@ -1,90 +1,132 @@
 package main
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"strings"
 
-	"github.com/ziollek/etherscription/internal/storage/memory"
-	"github.com/ziollek/etherscription/pkg/api"
 	"github.com/ziollek/etherscription/pkg/config"
-	"github.com/ziollek/etherscription/pkg/etherum"
-	"github.com/ziollek/etherscription/pkg/logging"
-	"github.com/ziollek/etherscription/pkg/model"
-	"github.com/ziollek/etherscription/pkg/parser"
 )
 
-const txBufferSize = 1000
+// command is a subcommand of the binary, run defines its flags in the given set and parses args,
+// which do not contain the name of the command.
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(flags *flag.FlagSet, args []string) error
+}
 
 var (
-	node            string
-	cfgPath         string
-	port            int
-	shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	commands = []command{
+		{"serve", "[flags]", "Run the service, used when no command is given", serve},
+		{"check-node", "[flags]", "Check whether the node supports JSON-RPC methods used by the service", checkNode},
+		{"inspect-tx", "[flags] <hash>", "Fetch a transaction from the node and print it the way the service stores it", inspectTx},
+		{"backfill", "[flags] --from <block> --to <block>", "Make the running instance fetch transactions of past blocks", backfill},
+		{"subscribe", "[flags] <address>", "Subscribe to transactions of the address in the running instance", subscribe},
+		{"fetch", "[flags] <address>", "Fetch and remove transactions buffered for the address in the running instance", fetch},
+	}
+	errUnknownCommand = errors.New("unknown command")
+	// options maps flags overriding options to paths of options
+	options = map[string]string{
+		"node":       "node",
+		"port":       "port",
+		"log-level":  "logging.level",
+		"log-format": "logging.format",
+		"log-output": "logging.output",
+	}
 )
 
-func init() {
-	flag.StringVar(&node, "node", "", "Ethereum node URL")
-	flag.StringVar(&cfgPath, "config", "configuration.yaml", "Ethereum node URL")
-	flag.IntVar(&port, "port", 8888, "HTTP server port")
-	flag.Parse()
-}
-
 func main() {
-	cfg, err := config.LoadConfig(cfgPath)
-	if err != nil {
-		logging.Logger().Err(err).Msg("Error while loading configuration")
+	if err := execute(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
 	}
-	ctx, done := signal.NotifyContext(context.Background(), shutdownSignals...)
-	defer done()
-	txChan := make(chan model.Transaction, txBufferSize)
-	blocksChan := make(chan int)
-	subscribersStorage := memory.NewKVStorage[string]()
-	transactionsStorage := memory.NewListStorage[model.Transaction]()
-	stateStorage := memory.NewKVStorage[int]()
-	broker := parser.NewBroker(
-		txChan,
-		blocksChan,
-		parser.NewConsumerService(transactionsStorage, subscribersStorage, cfg.Storage),
-		parser.NewStateConsumerService(stateStorage),
-	)
-	go broker.Start(ctx)
-	fetcher := etherum.NewFetcher(
-		cfg.RPC.Interval,
-		etherum.NewRPCClient(node, cfg.RPC),
-		txChan,
-		blocksChan,
-	)
-	go func(ctx context.Context) {
-		err := fetcher.Start(ctx)
-		if err != nil {
-			logging.Logger().Err(err).Msg("Error while starting fetcher")
-			panic(err)
+}
+
+func execute(args []string) error {
+	name := "serve"
+	// flags given without a command run the service, as they did before commands were introduced
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			err := cmd.run(newFlagSet(cmd), args)
+			if errors.Is(err, flag.ErrHelp) {
+				return nil
+			}
+			return err
 		}
-	}(ctx)
-	cleaner := memory.NewCleaner[model.Transaction](transactionsStorage, cfg.Storage.CleanInterval)
-	go cleaner.Start(ctx)
+	}
+	if name == "help" {
+		printUsage(os.Stdout)
+		return nil
+	}
+	printUsage(os.Stderr)
+	return fmt.Errorf("%w: %s", errUnknownCommand, name)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [arguments]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' to list flags of the command.\n", os.Args[0])
+}
 
-	subscriptionService := parser.NewSubscriptionService(transactionsStorage, subscribersStorage, stateStorage)
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: api.ConfigureRouting(api.NewHandler(subscriptionService)),
+// newFlagSet describes the command in its help, errors are reported by main.
+func newFlagSet(cmd command) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s %s\n\n%s.\n\nFlags:\n", os.Args[0], cmd.name, cmd.usage, cmd.description)
+		flags.PrintDefaults()
 	}
-	go func() {
-		if err := server.ListenAndServe(); err != nil {
-			logging.Logger().Err(err).Msg("Error while starting HTTP server")
+	return flags
+}
+
+// configFlags are flags of commands which load the configuration, the ones given explicitly override other layers.
+type configFlags struct {
+	path  string
+	flags *flag.FlagSet
+}
+
+func newConfigFlags(flags *flag.FlagSet) *configFlags {
+	cfgFlags := &configFlags{flags: flags}
+	flags.StringVar(&cfgFlags.path, "config", "", "Path of the configuration file, only defaults and environment variables are used when empty")
+	flags.String("node", "", "Ethereum node URL, overrides node")
+	flags.String("log-level", "", "Log level (trace, debug, info, warn, error), overrides logging.level")
+	flags.String("log-format", "", "Log format (console or json), overrides logging.format")
+	flags.String("log-output", "", "Log output (stdout, stderr or a path of a file), overrides logging.output")
+	return cfgFlags
+}
+
+// overrides returns values of flags given explicitly, so defaults of flags do not override other layers.
+func (c *configFlags) overrides() map[string]string {
+	values := map[string]string{}
+	c.flags.Visit(func(f *flag.Flag) {
+		if option, ok := options[f.Name]; ok {
+			values[option] = f.Value.String()
 		}
-	}()
-	<-ctx.Done()
-	shutdownCtx, shutdownDone := context.WithTimeout(context.Background(), time.Second)
-	defer shutdownDone()
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		logging.Logger().Err(err).Msg("Error while shutting down HTTP server")
+	})
+	return values
+}
+
+// load reads the configuration from all layers and validates it, it is also used to reload the configuration.
+func (c *configFlags) load() (*config.Config, error) {
+	cfg, err := config.Load(c.path, c.overrides())
+	if err != nil {
+		return nil, err
 	}
+	return cfg, cfg.Validate()
+}
+
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/ziollek/etherscription/pkg/etherum"
+	"github.com/ziollek/etherscription/pkg/logging"
+)
+
+var errUnsupportedNode = errors.New("node does not support methods required by the service")
+
+// nodeClient loads the configuration for commands talking directly to the node, logs go to stderr by default,
+// so they do not mix with the output of the command.
+func nodeClient(cfgFlags *configFlags) (*etherum.RPCClient, error) {
+	cfg, err := cfgFlags.load()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cfgFlags.overrides()["logging.output"]; !ok {
+		cfg.Logging.Output = logging.OutputStderr
+	}
+	if err := logging.Configure(cfg.Logging); err != nil {
+		return nil, err
+	}
+	return etherum.NewRPCClient(cfg.Node, cfg.RPC), nil
+}
+
+func checkNode(flags *flag.FlagSet, args []string) error {
+	cfgFlags := newConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	client, err := nodeClient(cfgFlags)
+	if err != nil {
+		return err
+	}
+	ctx, done := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer done()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tRESULT\tUSED BY\tERROR")
+	unsupported := false
+	for _, check := range client.CheckMethods(ctx) {
+		result, usedBy, message := "supported", "all instances", ""
+		if !check.Supported() {
+			result, message = "unsupported", check.Err.Error()
+			unsupported = unsupported || check.Required
+		}
+		if !check.Required {
+			usedBy = check.Feature
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", check.Method, result, usedBy, message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if unsupported {
+		return errUnsupportedNode
+	}
+	return nil
+}
+
+func inspectTx(flags *flag.FlagSet, args []string) error {
+	cfgFlags := newConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("exactly one transaction hash is required")
+	}
+	client, err := nodeClient(cfgFlags)
+	if err != nil {
+		return err
+	}
+	ctx, done := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer done()
+	transaction, err := client.Transaction(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return printJSON(transaction)
+}
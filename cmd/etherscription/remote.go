@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/api"
+	"github.com/ziollek/etherscription/pkg/client"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/model"
+)
+
+const (
+	defaultServer = "http://localhost:8888"
+	// remoteRetries covers restarts of the instance, requests rejected by the instance are not retried
+	// and fetched transactions are requested again only when the instance could not be connected
+	remoteRetries   = 2
+	backfillPolling = time.Second
+)
+
+var errBackfillFailed = errors.New("backfill failed")
+
+// remoteFlags select the running instance, keys default to environment variables,
+// the admin key to the same one which configures the instance.
+type remoteFlags struct {
+	server   string
+	apiKey   string
+	adminKey string
+}
+
+func newRemoteFlags(flags *flag.FlagSet) *remoteFlags {
+	r := &remoteFlags{}
+	server := os.Getenv("ETHERSCRIPTION_SERVER")
+	if server == "" {
+		server = defaultServer
+	}
+	flags.StringVar(&r.server, "server", server, "URL of the running instance, defaults to ETHERSCRIPTION_SERVER")
+	flags.StringVar(&r.apiKey, "api-key", os.Getenv("ETHERSCRIPTION_API_KEY"), "API key of the tenant, defaults to ETHERSCRIPTION_API_KEY")
+	flags.StringVar(&r.adminKey, "admin-key", os.Getenv(config.EnvPrefix+"AUTH_ADMIN_KEY"),
+		"Admin key, defaults to "+config.EnvPrefix+"AUTH_ADMIN_KEY")
+	return r
+}
+
+func (r *remoteFlags) client() *client.Client {
+	return client.New(r.server, client.Options{APIKey: r.apiKey, AdminKey: r.adminKey, Retries: remoteRetries})
+}
+
+func backfill(flags *flag.FlagSet, args []string) error {
+	r := newRemoteFlags(flags)
+	from := flags.Int("from", 0, "First block of the range")
+	to := flags.Int("to", 0, "Last block of the range, it is included")
+	wait := flags.Bool("wait", false, "Report progress until the backfill finishes")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	ctx, done := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer done()
+	remote := r.client()
+	status, err := remote.Backfill(ctx, *from, *to)
+	if err != nil {
+		return err
+	}
+	if !*wait {
+		return printJSON(status.Backfill)
+	}
+	ticker := time.NewTicker(backfillPolling)
+	defer ticker.Stop()
+	for status.Backfill != nil && status.Backfill.Running {
+		select {
+		case <-ctx.Done():
+			// the backfill keeps running in the instance
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if status, err = remote.GetIngestion(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Blocks %d-%d of %d-%d fetched, %d transactions\n",
+			status.Backfill.From, status.Backfill.Next-1, status.Backfill.From, status.Backfill.To, status.Backfill.Transactions)
+	}
+	if err := printJSON(status.Backfill); err != nil {
+		return err
+	}
+	if status.Backfill.Error != "" {
+		return fmt.Errorf("%w: %s", errBackfillFailed, status.Backfill.Error)
+	}
+	return nil
+}
+
+func subscribe(flags *flag.FlagSet, args []string) error {
+	r := newRemoteFlags(flags)
+	callbackURL := flags.String("callback-url", "", "URL to which transactions are pushed instead of being buffered")
+	ttl := flags.Duration("ttl", 0, "Time after which the subscription expires unless renewed, 0 means never")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("exactly one address is required")
+	}
+	ctx, done := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer done()
+	request := api.SubscriptionsRequests{Address: flags.Arg(0), CallbackURL: *callbackURL, TTL: model.Duration(*ttl)}
+	created, err := r.client().Subscribe(ctx, request)
+	if err != nil {
+		return err
+	}
+	return printJSON(api.SubscriptionsResponse{Status: created})
+}
+
+func fetch(flags *flag.FlagSet, args []string) error {
+	r := newRemoteFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("exactly one address is required")
+	}
+	ctx, done := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer done()
+	transactions, err := r.client().GetTransactions(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return printJSON(api.GetTransactionsResponse{Transactions: transactions})
+}
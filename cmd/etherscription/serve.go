@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ziollek/etherscription/internal/storage/backend"
+	"github.com/ziollek/etherscription/pkg/api"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/etherum"
+	"github.com/ziollek/etherscription/pkg/health"
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/metrics"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/parser"
+	"github.com/ziollek/etherscription/pkg/supervisor"
+	"github.com/ziollek/etherscription/pkg/tracing"
+	"github.com/ziollek/etherscription/pkg/webhook"
+)
+
+const (
+	txBufferSize    = 1000
+	shutdownTimeout = time.Second
+	// leaseRenewals is the number of attempts to renew the ingestion lease before it expires
+	leaseRenewals = 3
+)
+
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// serve returns only after all components stopped, so deferred flushing and closing of storages always happen.
+func serve(flags *flag.FlagSet, args []string) error {
+	cfgFlags := newConfigFlags(flags)
+	printConfig := flags.Bool("print-config", false, "Print the effective configuration with secrets masked and exit")
+	flags.Int("port", 8888, "HTTP server port, overrides port")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config.Load(cfgFlags.path, cfgFlags.overrides())
+	if err != nil {
+		logging.Logger().Err(err).Msg("Error while loading configuration")
+		return err
+	}
+	if *printConfig {
+		return cfg.Print(os.Stdout)
+	}
+	if err := cfg.Validate(); err != nil {
+		logging.Logger().Err(err).Msg("Invalid configuration")
+		return err
+	}
+	if err := logging.Configure(cfg.Logging); err != nil {
+		logging.Logger().Err(err).Msg("Error while configuring logging")
+		return err
+	}
+	logging.Module("config").Info().Str("path", cfgFlags.path).Msg("Configuration loaded")
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logging.Logger().Err(err).Msg("Error while setting up tracing")
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logging.Logger().Err(err).Msg("Error while flushing traces")
+		}
+	}()
+	storages, err := backend.New(cfg.Storage)
+	if err != nil {
+		logging.Logger().Err(err).Msg("Error while opening storage")
+		return err
+	}
+	defer func() {
+		if err := storages.Close(); err != nil {
+			logging.Logger().Err(err).Msg("Error while closing storage")
+		}
+	}()
+	ctx, done := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer done()
+	txChan := make(chan model.TracedTransaction, txBufferSize)
+	blocksChan := make(chan int)
+	metrics.RegisterChannel("transactions", txChan)
+	storages.RegisterMetrics()
+	subscribersStorage := storages.Subscriptions
+	transactionsStorage := storages.Transactions
+	stateStorage := storages.State
+	deadLettersStorage := storages.DeadLetters
+	tenantService := parser.NewTenantService(storages.Tenants, cfg.Auth)
+	subscriptionService := parser.NewSubscriptionService(transactionsStorage, subscribersStorage, stateStorage, deadLettersStorage, cfg.Quotas)
+	guard, err := webhook.NewGuard(cfg.Webhook)
+	if err != nil {
+		return err
+	}
+	dispatcher := webhook.NewDispatcher(cfg.Webhook, guard, deadLettersStorage, subscriptionService)
+	consumerService := parser.NewConsumerService(transactionsStorage, subscribersStorage, tenantService, dispatcher, cfg.Storage, cfg.Quotas)
+	var txConsumer parser.Consumer[model.TracedTransaction] = consumerService
+	if storages.History != nil {
+		txConsumer = parser.Consumers[model.TracedTransaction]{consumerService, storages.History}
+	}
+	broker := parser.NewBroker(txChan, blocksChan, txConsumer, parser.NewStateConsumerService(stateStorage))
+	fetcher := etherum.NewFetcher(
+		cfg.RPC,
+		etherum.NewRPCClient(cfg.Node, cfg.RPC),
+		txChan,
+		blocksChan,
+	)
+	cleaners := storages.Cleaners(cfg.Storage.CleanInterval, subscriptionService)
+	reloader := config.NewReloader(cfg, cfgFlags.load)
+	reloader.OnChange(func(cfg *config.Config) { fetcher.SetInterval(cfg.RPC.Interval) }, "rpc.interval")
+	reloader.OnChange(func(cfg *config.Config) { consumerService.SetConfig(cfg.Storage) }, "storage.retention", "storage.store_all_transactions")
+	reloader.OnChange(func(cfg *config.Config) {
+		for _, cleaner := range cleaners {
+			cleaner.SetInterval(cfg.Storage.CleanInterval)
+		}
+	}, "storage.clean_interval")
+	reloader.OnChange(func(cfg *config.Config) { tenantService.SetTenants(cfg.Auth.Tenants) }, "auth.tenants")
+	reloader.OnChange(func(cfg *config.Config) {
+		if err := logging.Configure(cfg.Logging); err != nil {
+			logging.Logger().Err(err).Msg("Error while configuring logging")
+		}
+	}, "logging")
+	limiter := api.NewRateLimiter(cfg.Quotas)
+	reloader.OnChange(func(cfg *config.Config) {
+		subscriptionService.SetQuotas(cfg.Quotas)
+		consumerService.SetQuotas(cfg.Quotas)
+		limiter.SetQuotas(cfg.Quotas)
+	}, "quotas")
+	queue := func() (int, int) { return len(txChan), cap(txChan) }
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%d", cfg.Port),
+		Handler: api.ConfigureRouting(
+			api.NewHandler(subscriptionService, guard, cfg.API, cfg.RPC, limiter),
+			api.NewAdminHandler(subscriptionService, tenantService, limiter, reloader),
+			api.NewHistoryHandler(storages.History),
+			api.NewHealthHandler(health.NewMonitor(cfg.Health, fetcher, queue, storages.Ping)),
+			api.NewOperationsHandler(fetcher, transactionsStorage, queue, storages.Stats),
+			api.NewAuthenticator(tenantService, cfg.Auth),
+			limiter,
+		),
+	}
+
+	// components are stopped in the reverse order: the fetcher stops before the broker drains buffered transactions
+	// and the dispatcher stops after the broker, which may still notify it
+	components := supervisor.New(cfg.Supervisor)
+	components.Add("dispatcher", supervisor.Func(dispatcher.Start))
+	components.Add("broker", supervisor.Func(broker.Start))
+	components.Add("fetcher", ingestion(cfg, storages, fetcher))
+	for _, cleaner := range cleaners {
+		components.Add("cleaner "+cleaner.Name(), supervisor.Func(cleaner.Start))
+	}
+	components.Add("reloader", supervisor.Func(reloadOnSignal(reloader)))
+	components.Add("http server", listen(server))
+	if err := components.Run(ctx); err != nil {
+		logging.Logger().Err(err).Msg("Error while running components")
+		return err
+	}
+	return nil
+}
+
+// reloadOnSignal reloads the configuration on every SIGHUP.
+func reloadOnSignal(reloader *config.Reloader) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		hangups := make(chan os.Signal, 1)
+		signal.Notify(hangups, syscall.SIGHUP)
+		defer signal.Stop(hangups)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangups:
+				result, err := reloader.Reload()
+				if err != nil {
+					logging.Module("config").Err(err).Msg("Cannot reload configuration")
+					continue
+				}
+				logging.Module("config").Info().Strs("applied", result.Applied).Strs("restart_required", result.RestartRequired).
+					Msg("Configuration reloaded")
+			}
+		}
+	}
+}
+
+// listen runs the HTTP server until ctx is done, requests in progress are given shutdownTimeout to finish.
+func listen(server *http.Server) supervisor.Component {
+	return func(ctx context.Context) error {
+		errs := make(chan error, 1)
+		go func() {
+			errs <- server.ListenAndServe()
+		}()
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			shutdownCtx, shutdownDone := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer shutdownDone()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				logging.Logger().Err(err).Msg("Error while shutting down HTTP server")
+			}
+			return nil
+		}
+	}
+}
+
+// ingestion runs the fetcher only on the replica holding the lease of a shared storage, so transactions
+// are not stored and webhooks are not called once per replica.
+func ingestion(cfg *config.Config, storages *backend.Backend, fetcher *etherum.Fetcher) supervisor.Component {
+	if storages.Lease == nil {
+		return fetcher.Start
+	}
+	return supervisor.Exclusive(storages.Lease, cfg.Storage.Redis.LeaseTTL/leaseRenewals, fetcher.Start, fetcher.Standby)
+}
@@ -0,0 +1,267 @@
+package backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ziollek/etherscription/internal/storage/bolt"
+	"github.com/ziollek/etherscription/internal/storage/memory"
+	"github.com/ziollek/etherscription/internal/storage/redis"
+	"github.com/ziollek/etherscription/internal/storage/sqldb"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/metrics"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+const (
+	Memory   = "memory"
+	Bolt     = "bolt"
+	Redis    = "redis"
+	SQLite   = sqldb.SQLite
+	Postgres = sqldb.Postgres
+)
+
+// List is a list storage which can be cleaned by memory.Cleaner.
+type List[T any] interface {
+	storage.ListSaver[T]
+	storage.Cleanable
+}
+
+// History is a queryable transaction history fed by the broker.
+type History interface {
+	storage.History
+	Consume(transaction model.TracedTransaction)
+}
+
+// Backend groups all storages used by the service, they are created by the backend selected in StorageConfig.
+type Backend struct {
+	Subscriptions storage.KVSaver[model.Subscription]
+	Transactions  List[model.TracedTransaction]
+	State         storage.KVSaver[int]
+	DeadLetters   List[model.DeadLetter]
+	Tenants       storage.KVSaver[model.Tenant]
+	// History is available only for sql backends
+	History History
+	// Lease is set for backends shared by replicas, only the replica holding it ingests transactions
+	Lease storage.Lease
+	// pruner replaces key by key cleaning for backends able to drop outdated entries at once
+	pruner storage.Expirer
+	// ping is nil for backends which cannot become unavailable, e.g. the memory one
+	ping  func() error
+	close func() error
+}
+
+func New(cfg *config.StorageConfig) (*Backend, error) {
+	switch cfg.Backend {
+	case "", Memory:
+		options, err := listOptions(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.WAL != nil && cfg.WAL.Enabled {
+			return newDurableMemory(cfg.WAL, options)
+		}
+		return newMemory(options), nil
+	case Bolt:
+		return newBolt(cfg.Path)
+	case Redis:
+		return newRedis(cfg.Redis)
+	case SQLite, Postgres:
+		return newSQL(cfg.Backend, cfg.SQL)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
+
+func (b *Backend) Close() error {
+	return b.close()
+}
+
+// Ping reports whether the storage is available.
+func (b *Backend) Ping() error {
+	if b.ping == nil {
+		return nil
+	}
+	return b.ping()
+}
+
+// Cleaners returns cleaners removing outdated transactions and dead letters, expirers are run along with them.
+func (b *Backend) Cleaners(interval time.Duration, expirers ...storage.Expirer) []*memory.Cleaner {
+	if b.pruner != nil {
+		return []*memory.Cleaner{memory.NewCleaner("sql", nil, interval, append(expirers, b.pruner)...)}
+	}
+	return []*memory.Cleaner{
+		memory.NewCleaner("transactions", b.Transactions, interval, expirers...),
+		memory.NewCleaner("dead_letters", b.DeadLetters, interval),
+	}
+}
+
+// RegisterMetrics exposes the number of keys of storages which can count them without reading all keys,
+// e.g. bolt ones cannot, and the number of entries of list storages which can count them without reading all lists.
+func (b *Backend) RegisterMetrics() {
+	metrics.RegisterStorage("subscriptions", keysCounter(b.Subscriptions), nil)
+	metrics.RegisterStorage("tenants", keysCounter(b.Tenants), nil)
+	metrics.RegisterStorage("transactions", keysCounter(b.Transactions), entriesCounter(b.Transactions))
+	metrics.RegisterStorage("dead_letters", keysCounter(b.DeadLetters), entriesCounter(b.DeadLetters))
+}
+
+// Stats returns sizes of all storages by name, names are the same as in metrics.
+func (b *Backend) Stats() map[string]storage.Stats {
+	stats := map[string]storage.Stats{
+		"subscriptions": {Keys: countKeys(b.Subscriptions), Entries: -1},
+		"tenants":       {Keys: countKeys(b.Tenants), Entries: -1},
+		"transactions":  {Keys: countKeys(b.Transactions), Entries: -1},
+		"dead_letters":  {Keys: countKeys(b.DeadLetters), Entries: -1},
+	}
+	for name, list := range map[string]any{"transactions": b.Transactions, "dead_letters": b.DeadLetters} {
+		if entries := entriesCounter(list); entries != nil {
+			stats[name] = storage.Stats{Keys: stats[name].Keys, Entries: entries()}
+		}
+	}
+	return stats
+}
+
+func keysCounter(keys any) func() int {
+	if counter, ok := keys.(storage.Counter); ok {
+		return counter.Count
+	}
+	return nil
+}
+
+// countKeys reads all keys of storages which cannot count them, stats are requested on demand, unlike metrics.
+func countKeys(keys interface{ Keys() []string }) int {
+	if counter := keysCounter(keys); counter != nil {
+		return counter()
+	}
+	return len(keys.Keys())
+}
+
+func entriesCounter(storage any) func() int {
+	if sized, ok := storage.(interface{ Size() int }); ok {
+		return sized.Size
+	}
+	return nil
+}
+
+func newMemory(options memory.ListOptions) *Backend {
+	return &Backend{
+		Subscriptions: memory.NewKVStorage[model.Subscription](),
+		Transactions:  memory.NewListStorageWithOptions[model.TracedTransaction](options),
+		State:         memory.NewKVStorage[int](),
+		DeadLetters:   memory.NewListStorageWithOptions[model.DeadLetter](options),
+		Tenants:       memory.NewKVStorage[model.Tenant](),
+		close:         func() error { return nil },
+	}
+}
+
+func newDurableMemory(cfg *config.WALConfig, options memory.ListOptions) (*Backend, error) {
+	wal, err := memory.OpenWAL(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open write-ahead log %s: %w", cfg.Dir, err)
+	}
+	b := &Backend{close: wal.Close, ping: wal.Err}
+	if b.Subscriptions, err = memory.NewDurableKVStorage[model.Subscription](wal, "subscriptions"); err != nil {
+		return nil, closeOnError(wal.Discard, err)
+	}
+	if b.Transactions, err = memory.NewDurableListStorage[model.TracedTransaction](wal, "transactions", options); err != nil {
+		return nil, closeOnError(wal.Discard, err)
+	}
+	if b.State, err = memory.NewDurableKVStorage[int](wal, "state"); err != nil {
+		return nil, closeOnError(wal.Discard, err)
+	}
+	if b.DeadLetters, err = memory.NewDurableListStorage[model.DeadLetter](wal, "dead_letters", options); err != nil {
+		return nil, closeOnError(wal.Discard, err)
+	}
+	if b.Tenants, err = memory.NewDurableKVStorage[model.Tenant](wal, "tenants"); err != nil {
+		return nil, closeOnError(wal.Discard, err)
+	}
+	return b, nil
+}
+
+func listOptions(cfg *config.StorageConfig) (memory.ListOptions, error) {
+	options := memory.ListOptions{Shards: cfg.Shards}
+	if cfg.Limits == nil {
+		return options, nil
+	}
+	policy, err := memory.ParseEvictionPolicy(cfg.Limits.EvictionPolicy)
+	if err != nil {
+		return options, err
+	}
+	options.MaxEntries = cfg.Limits.MaxEntries
+	options.MaxEntriesPerKey = cfg.Limits.MaxEntriesPerKey
+	options.Policy = policy
+	return options, nil
+}
+
+func newBolt(path string) (*Backend, error) {
+	db, err := bolt.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database %s: %w", path, err)
+	}
+	b := &Backend{close: db.Close, ping: func() error { return bolt.Ping(db) }}
+	if b.Subscriptions, err = bolt.NewKVStorage[model.Subscription](db, "subscriptions"); err != nil {
+		return nil, closeOnError(db.Close, err)
+	}
+	if b.Transactions, err = bolt.NewListStorage[model.TracedTransaction](db, "transactions"); err != nil {
+		return nil, closeOnError(db.Close, err)
+	}
+	if b.State, err = bolt.NewKVStorage[int](db, "state"); err != nil {
+		return nil, closeOnError(db.Close, err)
+	}
+	if b.DeadLetters, err = bolt.NewListStorage[model.DeadLetter](db, "dead_letters"); err != nil {
+		return nil, closeOnError(db.Close, err)
+	}
+	if b.Tenants, err = bolt.NewKVStorage[model.Tenant](db, "tenants"); err != nil {
+		return nil, closeOnError(db.Close, err)
+	}
+	return b, nil
+}
+
+func newRedis(cfg *config.RedisConfig) (*Backend, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("missing redis configuration")
+	}
+	client, err := redis.Open(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to redis %s: %w", cfg.Address, err)
+	}
+	return &Backend{
+		Subscriptions: redis.NewKVStorage[model.Subscription](client, cfg, "subscriptions"),
+		Transactions:  redis.NewListStorage[model.TracedTransaction](client, cfg, "transactions"),
+		State:         redis.NewKVStorage[int](client, cfg, "state"),
+		DeadLetters:   redis.NewListStorage[model.DeadLetter](client, cfg, "dead_letters"),
+		Tenants:       redis.NewKVStorage[model.Tenant](client, cfg, "tenants"),
+		Lease:         redis.NewLease(client, cfg, "ingestion"),
+		ping:          func() error { return redis.Ping(client, cfg) },
+		close:         client.Close,
+	}, nil
+}
+
+func newSQL(dialect string, cfg *config.SQLConfig) (*Backend, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("missing sql configuration")
+	}
+	db, err := sqldb.Open(dialect, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s database: %w", dialect, err)
+	}
+	return &Backend{
+		Subscriptions: sqldb.NewKVStorage[model.Subscription](db, "subscriptions"),
+		Transactions:  sqldb.NewListStorage[model.TracedTransaction](db, "transactions"),
+		State:         sqldb.NewKVStorage[int](db, "state"),
+		DeadLetters:   sqldb.NewListStorage[model.DeadLetter](db, "dead_letters"),
+		Tenants:       sqldb.NewKVStorage[model.Tenant](db, "tenants"),
+		History:       sqldb.NewHistory(db),
+		pruner:        sqldb.NewPruner(db, cfg.HistoryRetention),
+		ping:          db.Ping,
+		close:         db.Close,
+	}, nil
+}
+
+func closeOnError(closer func() error, err error) error {
+	if closeErr := closer(); closeErr != nil {
+		return fmt.Errorf("%w (closing failed: %s)", err, closeErr)
+	}
+	return err
+}
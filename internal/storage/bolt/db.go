@@ -0,0 +1,67 @@
+package bolt
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/logging"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+const (
+	fileMode    = 0o600
+	openTimeout = time.Second
+)
+
+// Open opens the database file creating it when necessary.
+// Every write is committed in a separate transaction synced to disk, so data survives a crash of the process.
+func Open(path string) (*bbolt.DB, error) {
+	return bbolt.Open(path, fileMode, &bbolt.Options{Timeout: openTimeout})
+}
+
+// Ping verifies that the database is open and a read transaction can be started.
+func Ping(db *bbolt.DB) error {
+	return db.View(func(*bbolt.Tx) error { return nil })
+}
+
+func createBucket(db *bbolt.DB, name string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(name))
+		return err
+	})
+}
+
+// scan pages keys of the bucket using a cursor, so only the requested page is read,
+// buckets selects nested buckets instead of values.
+func scan(db *bbolt.DB, bucket []byte, after string, limit int, buckets bool) ([]string, string) {
+	keys := make([]string, 0)
+	next := ""
+	err := db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(bucket).Cursor()
+		k, v := cursor.First()
+		if after != "" {
+			k, v = cursor.Seek([]byte(after))
+			if k != nil && bytes.Equal(k, []byte(after)) {
+				k, v = cursor.Next()
+			}
+		}
+		for ; k != nil; k, v = cursor.Next() {
+			// nested buckets have no value
+			if (v == nil) != buckets {
+				continue
+			}
+			if limit > 0 && len(keys) == limit {
+				next = keys[len(keys)-1]
+				break
+			}
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Msg("Cannot scan keys")
+		return []string{}, ""
+	}
+	return keys, next
+}
@@ -0,0 +1,85 @@
+package bolt
+
+import (
+	"encoding/json"
+
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/storage"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// KVStorage keeps JSON encoded values in a single bucket.
+type KVStorage[T any] struct {
+	db     *bbolt.DB
+	bucket []byte
+}
+
+func NewKVStorage[T any](db *bbolt.DB, bucket string) (storage.KVSaver[T], error) {
+	if err := createBucket(db, bucket); err != nil {
+		return nil, err
+	}
+	return &KVStorage[T]{db: db, bucket: []byte(bucket)}, nil
+}
+
+func (storage *KVStorage[T]) Get(key string) (T, bool) {
+	var value T
+	found := false
+	err := storage.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(storage.bucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &value)
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Str("key", key).Msg("Cannot read value")
+		return value, false
+	}
+	return value, found
+}
+
+func (storage *KVStorage[T]) Set(key string, value T) {
+	err := storage.db.Update(func(tx *bbolt.Tx) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(storage.bucket).Put([]byte(key), data)
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Str("key", key).Msg("Cannot write value")
+	}
+}
+
+func (storage *KVStorage[T]) Delete(key string) bool {
+	found := false
+	err := storage.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(storage.bucket)
+		found = bucket.Get([]byte(key)) != nil
+		return bucket.Delete([]byte(key))
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Str("key", key).Msg("Cannot delete value")
+		return false
+	}
+	return found
+}
+
+func (storage *KVStorage[_]) Keys() []string {
+	keys := make([]string, 0)
+	err := storage.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(storage.bucket).ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Msg("Cannot list keys")
+	}
+	return keys
+}
+
+func (storage *KVStorage[_]) Scan(cursor string, limit int) ([]string, string) {
+	return scan(storage.db, storage.bucket, cursor, limit, false)
+}
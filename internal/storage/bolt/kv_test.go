@@ -0,0 +1,49 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldStoreValuesAcrossReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path)
+	require.NoError(t, err)
+	kv, err := NewKVStorage[int](db, "state")
+	require.NoError(t, err)
+	kv.Set("first", 1)
+	kv.Set("second", 2)
+	require.True(t, kv.Delete("second"))
+	require.False(t, kv.Delete("second"))
+	require.NoError(t, db.Close())
+
+	db, err = Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+	kv, err = NewKVStorage[int](db, "state")
+	require.NoError(t, err)
+	value, found := kv.Get("first")
+	require.True(t, found)
+	require.Equal(t, 1, value)
+	_, found = kv.Get("second")
+	require.False(t, found)
+	require.Equal(t, []string{"first"}, kv.Keys())
+}
+
+func TestShouldScanKeysInOrder(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	kv, err := NewKVStorage[int](db, "state")
+	require.NoError(t, err)
+	for i, key := range []string{"c", "a", "b"} {
+		kv.Set(key, i)
+	}
+	keys, cursor := kv.Scan("", 2)
+	require.Equal(t, []string{"a", "b"}, keys)
+	keys, cursor = kv.Scan(cursor, 2)
+	require.Equal(t, []string{"c"}, keys)
+	require.Empty(t, cursor)
+}
@@ -0,0 +1,253 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/storage"
+	bbolt "go.etcd.io/bbolt"
+)
+
+type entry[T any] struct {
+	Value      T         `json:"value"`
+	Expiration time.Time `json:"expiration"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// ListStorage keeps every list in a nested bucket, entries are ordered by a sequence number.
+type ListStorage[T any] struct {
+	db     *bbolt.DB
+	bucket []byte
+}
+
+func NewListStorage[T any](db *bbolt.DB, bucket string) (*ListStorage[T], error) {
+	if err := createBucket(db, bucket); err != nil {
+		return nil, err
+	}
+	return &ListStorage[T]{db: db, bucket: []byte(bucket)}, nil
+}
+
+func (storage *ListStorage[T]) FetchAndFlush(key string) []T {
+	values := make([]T, 0)
+	err := storage.db.Update(func(tx *bbolt.Tx) error {
+		parent := tx.Bucket(storage.bucket)
+		list := parent.Bucket([]byte(key))
+		if list == nil {
+			return nil
+		}
+		now := time.Now()
+		err := list.ForEach(func(_, data []byte) error {
+			var e entry[T]
+			if err := json.Unmarshal(data, &e); err != nil {
+				return err
+			}
+			if e.Expiration.After(now) {
+				values = append(values, e.Value)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return parent.DeleteBucket([]byte(key))
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Str("key", key).Msg("Cannot fetch entries")
+		return []T{}
+	}
+	return values
+}
+
+func (storage *ListStorage[T]) Append(key string, value T, ttl time.Duration) {
+	storage.AppendBounded(key, value, ttl, 0)
+}
+
+// AppendBounded counts keys of the list bucket without decoding its entries.
+func (storage *ListStorage[T]) AppendBounded(key string, value T, ttl time.Duration, limit int) bool {
+	appended := true
+	err := storage.db.Update(func(tx *bbolt.Tx) error {
+		list, err := tx.Bucket(storage.bucket).CreateBucketIfNotExists([]byte(key))
+		if err != nil {
+			return err
+		}
+		if limit > 0 && list.Stats().KeyN >= limit {
+			appended = false
+			return nil
+		}
+		sequence, err := list.NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		data, err := json.Marshal(entry[T]{Value: value, Expiration: now.Add(ttl), AddedAt: now})
+		if err != nil {
+			return err
+		}
+		return list.Put(encodeSequence(sequence), data)
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Str("key", key).Msg("Cannot append entry")
+	}
+	return appended
+}
+
+func (storage *ListStorage[T]) Len(key string) int {
+	count := 0
+	err := storage.db.View(func(tx *bbolt.Tx) error {
+		list := tx.Bucket(storage.bucket).Bucket([]byte(key))
+		if list == nil {
+			return nil
+		}
+		now := time.Now()
+		return list.ForEach(func(_, data []byte) error {
+			var e entry[T]
+			if err := json.Unmarshal(data, &e); err != nil {
+				return err
+			}
+			if e.Expiration.After(now) {
+				count++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Str("key", key).Msg("Cannot count entries")
+	}
+	return count
+}
+
+func (storage *ListStorage[T]) Peek(key string) []T {
+	items := readItems[T](storage.db, storage.bucket, key, allItems)
+	values := make([]T, len(items))
+	for i, item := range items {
+		values[i] = item.Value
+	}
+	return values
+}
+
+func (storage *ListStorage[T]) Range(key string, query storage.RangeQuery) []storage.Item[T] {
+	return readItems[T](storage.db, storage.bucket, key, query)
+}
+
+func (storage *ListStorage[_]) Delete(key string) bool {
+	found := false
+	err := storage.db.Update(func(tx *bbolt.Tx) error {
+		parent := tx.Bucket(storage.bucket)
+		found = parent.Bucket([]byte(key)) != nil
+		if !found {
+			return nil
+		}
+		return parent.DeleteBucket([]byte(key))
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Str("key", key).Msg("Cannot delete entries")
+		return false
+	}
+	return found
+}
+
+func (storage *ListStorage[_]) Scan(cursor string, limit int) ([]string, string) {
+	return scan(storage.db, storage.bucket, cursor, limit, true)
+}
+
+func (storage *ListStorage[_]) Keys() []string {
+	keys := make([]string, 0)
+	err := storage.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(storage.bucket).ForEachBucket(func(k []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Msg("Cannot list keys")
+	}
+	return keys
+}
+
+func (storage *ListStorage[T]) CleanOutdated(key string) (int, int) {
+	before, after := 0, 0
+	err := storage.db.Update(func(tx *bbolt.Tx) error {
+		parent := tx.Bucket(storage.bucket)
+		list := parent.Bucket([]byte(key))
+		if list == nil {
+			return nil
+		}
+		now := time.Now()
+		// deleting while iterating with a cursor would skip entries
+		outdated := make([][]byte, 0)
+		err := list.ForEach(func(k, data []byte) error {
+			before++
+			var e entry[T]
+			if err := json.Unmarshal(data, &e); err != nil {
+				return err
+			}
+			if e.Expiration.After(now) {
+				after++
+			} else {
+				outdated = append(outdated, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if after == 0 {
+			// we should not store empty lists
+			return parent.DeleteBucket([]byte(key))
+		}
+		for _, k := range outdated {
+			if err := list.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Str("key", key).Msg("Cannot clean entries")
+	}
+	return before, after
+}
+
+func encodeSequence(sequence uint64) []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, sequence)
+	return encoded
+}
+
+// allItems is a query matching all items.
+var allItems = storage.RangeQuery{}
+
+func readItems[T any](db *bbolt.DB, bucket []byte, key string, query storage.RangeQuery) []storage.Item[T] {
+	items := make([]storage.Item[T], 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		list := tx.Bucket(bucket).Bucket([]byte(key))
+		if list == nil {
+			return nil
+		}
+		now := time.Now()
+		cursor := list.Cursor()
+		k, data := cursor.First()
+		if query.FromSequence > 0 {
+			// entries are ordered by sequence, so the beginning of the range can be found directly
+			k, data = cursor.Seek(encodeSequence(query.FromSequence))
+		}
+		for ; k != nil && !query.Full(len(items)); k, data = cursor.Next() {
+			var e entry[T]
+			if err := json.Unmarshal(data, &e); err != nil {
+				return err
+			}
+			sequence := binary.BigEndian.Uint64(k)
+			if e.Expiration.After(now) && query.Matches(sequence, e.AddedAt) {
+				items = append(items, storage.Item[T]{Sequence: sequence, AddedAt: e.AddedAt, Value: e.Value})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logging.Module("bolt").Err(err).Str("key", key).Msg("Cannot read entries")
+		return []storage.Item[T]{}
+	}
+	return items
+}
@@ -0,0 +1,22 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/storage/storagetest"
+)
+
+func newTestListStorage(t *testing.T) *ListStorage[string] {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	s, err := NewListStorage[string](db, "list")
+	require.NoError(t, err)
+	return s
+}
+
+func TestShouldConformToListSaver(t *testing.T) {
+	storagetest.TestListSaver(t, func(t *testing.T) storagetest.ListStorage { return newTestListStorage(t) })
+}
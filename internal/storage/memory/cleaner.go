@@ -2,41 +2,149 @@ package memory
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/metrics"
+	"github.com/ziollek/etherscription/pkg/storage"
 )
 
-type Cleaner[T any] struct {
-	storage  *ListStorage[T]
+// shardCleanable is a storage which can drop outdated entries shard by shard,
+// so the lock of a shard is taken once per tick instead of once per key.
+type shardCleanable interface {
+	Shards() int
+	CleanShard(index int) CleaningStats
+}
+
+// evictionCounter is a storage which discards entries because of its limits.
+type evictionCounter interface {
+	Evictions() Evictions
+}
+
+// Cleaner drops outdated entries of the storage key by key and runs expirers, storage is optional.
+// The name distinguishes cleaners in metrics.
+type Cleaner struct {
+	name     string
+	storage  storage.Cleanable
 	interval time.Duration
+	// reset notifies the running cleaner that the interval has changed
+	reset    chan struct{}
+	expirers []storage.Expirer
+	last     CleaningStats
+	duration time.Duration
+	// evictions reported by the last cleaning
+	evictions Evictions
+	mutex     sync.RWMutex
 }
 
-func NewCleaner[T any](storage *ListStorage[T], interval time.Duration) *Cleaner[T] {
-	return &Cleaner[T]{
+func NewCleaner(name string, storage storage.Cleanable, interval time.Duration, expirers ...storage.Expirer) *Cleaner {
+	return &Cleaner{
+		name:     name,
 		storage:  storage,
 		interval: interval,
+		reset:    make(chan struct{}, 1),
+		expirers: expirers,
 	}
 }
 
-func (cleaner *Cleaner[_]) Start(ctx context.Context) {
-	ticker := time.NewTicker(cleaner.interval)
+func (cleaner *Cleaner) Start(ctx context.Context) {
+	ticker := time.NewTicker(cleaner.currentInterval())
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			logging.Logger().Warn().
-				Str("module", "memory").Msg("Context done, stopping cleaner")
+			logging.Module("memory").Warn().Msg("Context done, stopping cleaner")
+			return
+		case <-cleaner.reset:
+			ticker.Reset(cleaner.currentInterval())
 		case <-ticker.C:
-			totalBefore, totalAfter := 0, 0
 			start := time.Now()
-			for _, k := range cleaner.storage.GetKeys() {
-				before, after := cleaner.storage.CleanOutdated(k)
-				totalBefore += before
-				totalAfter += after
+			if cleaner.storage != nil {
+				cleaner.clean()
 			}
-			logging.Logger().Info().Str("module", "memory").Dur("duration", time.Since(start)).Msgf(
-				"Cleaned %d entries, left %d", totalBefore-totalAfter, totalAfter,
-			)
+			for _, expirer := range cleaner.expirers {
+				cleaner.expire(expirer)
+			}
+			metrics.CleanerDuration.WithLabelValues(cleaner.name).Observe(time.Since(start).Seconds())
 		}
 	}
 }
+
+// SetInterval changes how often the cleaner runs, a running cleaner applies it immediately.
+func (cleaner *Cleaner) SetInterval(interval time.Duration) {
+	cleaner.mutex.Lock()
+	cleaner.interval = interval
+	cleaner.mutex.Unlock()
+	select {
+	case cleaner.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (cleaner *Cleaner) currentInterval() time.Duration {
+	cleaner.mutex.RLock()
+	defer cleaner.mutex.RUnlock()
+	return cleaner.interval
+}
+
+func (cleaner *Cleaner) Name() string {
+	return cleaner.name
+}
+
+// LastRun returns the cost of the last cleaning of the storage.
+func (cleaner *Cleaner) LastRun() (CleaningStats, time.Duration) {
+	cleaner.mutex.RLock()
+	defer cleaner.mutex.RUnlock()
+	return cleaner.last, cleaner.duration
+}
+
+func (cleaner *Cleaner) clean() {
+	stats := CleaningStats{}
+	start := time.Now()
+	if sharded, ok := cleaner.storage.(shardCleanable); ok {
+		for i := range sharded.Shards() {
+			stats.Add(sharded.CleanShard(i))
+		}
+	} else {
+		for _, k := range cleaner.storage.Keys() {
+			before, after := cleaner.storage.CleanOutdated(k)
+			stats.Add(CleaningStats{Keys: 1, Examined: before, Removed: before - after, Left: after})
+		}
+	}
+	duration := time.Since(start)
+	cleaner.mutex.Lock()
+	cleaner.last, cleaner.duration = stats, duration
+	cleaner.mutex.Unlock()
+	metrics.CleanerRemoved.WithLabelValues(cleaner.name).Add(float64(stats.Removed))
+	logging.Module("memory").Info().Dur("duration", duration).
+		Int("keys", stats.Keys).Int("examined", stats.Examined).Msgf(
+		"Cleaned %d entries, left %d", stats.Removed, stats.Left,
+	)
+	cleaner.reportEvictions()
+}
+
+func (cleaner *Cleaner) expire(expirer storage.Expirer) {
+	start := time.Now()
+	removed := expirer.Expire(start)
+	metrics.CleanerRemoved.WithLabelValues(cleaner.name).Add(float64(removed))
+	logging.Module("memory").Info().Dur("duration", time.Since(start)).Msgf("Expired %d entries", removed)
+}
+
+// reportEvictions warns operators that data was discarded since the previous cleaning.
+func (cleaner *Cleaner) reportEvictions() {
+	counter, ok := cleaner.storage.(evictionCounter)
+	if !ok {
+		return
+	}
+	current := counter.Evictions()
+	evicted, dropped := current.Evicted-cleaner.evictions.Evicted, current.Dropped-cleaner.evictions.Dropped
+	cleaner.evictions = current
+	metrics.StorageEvictions.WithLabelValues(cleaner.name, "evicted").Add(float64(evicted))
+	metrics.StorageEvictions.WithLabelValues(cleaner.name, "dropped").Add(float64(dropped))
+	if evicted > 0 || dropped > 0 {
+		logging.Module("memory").Warn().Uint64("evicted", evicted).Uint64("dropped", dropped).Msg(
+			"Storage limits reached, entries were discarded",
+		)
+	}
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/storage/mock_storage"
+)
+
+func TestShouldReportCostOfLastCleaning(t *testing.T) {
+	s := NewShardedListStorage[string](4)
+	s.Append("first", "outdated", -time.Second)
+	s.Append("first", "active", time.Minute)
+	s.Append("second", "active", time.Minute)
+	cleaner := NewCleaner("test", s, time.Second)
+
+	cleaner.clean()
+	stats, duration := cleaner.LastRun()
+	require.Equal(t, CleaningStats{Keys: 1, Examined: 1, Removed: 1, Left: 2}, stats)
+	require.Positive(t, duration)
+}
+
+func TestShouldCleanAnyStorageKeyByKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mock_storage.NewMockCleanable(ctrl)
+	s.EXPECT().Keys().Return([]string{"first", "second"})
+	s.EXPECT().CleanOutdated("first").Return(3, 1)
+	s.EXPECT().CleanOutdated("second").Return(2, 2)
+	cleaner := NewCleaner("test", s, time.Second)
+
+	cleaner.clean()
+	stats, _ := cleaner.LastRun()
+	require.Equal(t, CleaningStats{Keys: 2, Examined: 5, Removed: 2, Left: 3}, stats)
+}
+
+func TestShouldApplyChangedIntervalWhileRunning(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	expirer := mock_storage.NewMockExpirer(ctrl)
+	expired := make(chan struct{}, 1)
+	expirer.EXPECT().Expire(gomock.Any()).DoAndReturn(func(time.Time) int {
+		select {
+		case expired <- struct{}{}:
+		default:
+		}
+		return 0
+	}).MinTimes(1)
+	cleaner := NewCleaner("test", nil, time.Hour, expirer)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		cleaner.Start(ctx)
+		close(stopped)
+	}()
+
+	cleaner.SetInterval(time.Millisecond)
+	<-expired
+	cancel()
+	<-stopped
+}
@@ -0,0 +1,151 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+)
+
+// EvictionPolicy decides what happens when an entry is appended to a full storage.
+type EvictionPolicy string
+
+const (
+	// OldestFirst evicts the oldest entry of the key when the key is full
+	// or the entry closest to expiration in the shard when the storage is full.
+	OldestFirst EvictionPolicy = "oldest_first"
+	// DropNew discards appended entries as long as the storage or the key is full.
+	DropNew EvictionPolicy = "drop_new"
+	// RingBuffer makes every key a ring buffer, the oldest entry of the key is evicted
+	// in both cases, entries of a new key are discarded when the storage is full.
+	RingBuffer EvictionPolicy = "ring_buffer"
+)
+
+func ParseEvictionPolicy(policy string) (EvictionPolicy, error) {
+	switch EvictionPolicy(policy) {
+	case "":
+		return OldestFirst, nil
+	case OldestFirst, DropNew, RingBuffer:
+		return EvictionPolicy(policy), nil
+	default:
+		return "", fmt.Errorf("unknown eviction policy: %s", policy)
+	}
+}
+
+// ListOptions configures ListStorage, zero limits mean no limit.
+// MaxEntries bounds the number of entries of the whole storage, not the memory used by them.
+type ListOptions struct {
+	Shards           int
+	MaxEntries       int
+	MaxEntriesPerKey int
+	Policy           EvictionPolicy
+}
+
+// Evictions counts entries discarded because of limits, Evicted were removed to make room for new ones
+// and Dropped were never stored.
+type Evictions struct {
+	Evicted uint64
+	Dropped uint64
+}
+
+// makeRoom is called under the lock of the shard before appending to the key, it reserves a slot
+// for the appended entry in the size of the storage or returns false when the entry should be discarded.
+func (storage *ListStorage[T]) makeRoom(s *shard[T], key string) bool {
+	options := storage.options
+	if options.MaxEntriesPerKey > 0 && len(s.entries[key]) >= options.MaxEntriesPerKey {
+		if options.Policy == DropNew {
+			return false
+		}
+		storage.evictHead(s, key)
+	}
+	// a slot freed by eviction can be taken by an append to another shard, then the next entry is evicted
+	for !storage.reserve() {
+		if !storage.evictFor(s, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// reserve increments the size of the storage unless it reached MaxEntries, the check and the increment are atomic,
+// so concurrent appends to different shards cannot exceed the limit.
+func (storage *ListStorage[T]) reserve() bool {
+	limit := int64(storage.options.MaxEntries)
+	for {
+		size := storage.size.Load()
+		if limit > 0 && size >= limit {
+			return false
+		}
+		if storage.size.CompareAndSwap(size, size+1) {
+			return true
+		}
+	}
+}
+
+// evictFor frees a slot of the full storage according to the policy, it returns false when nothing can be evicted.
+func (storage *ListStorage[T]) evictFor(s *shard[T], key string) bool {
+	switch storage.options.Policy {
+	case DropNew:
+		return false
+	case RingBuffer:
+		if len(s.entries[key]) == 0 {
+			return false
+		}
+		storage.evictHead(s, key)
+		return true
+	default:
+		return storage.evictOldest(s) || storage.evictElsewhere(s)
+	}
+}
+
+// evictElsewhere is used when the shard being appended to has nothing to evict, other shards are only tried
+// without waiting for their locks, so appends to different shards cannot deadlock.
+func (storage *ListStorage[T]) evictElsewhere(current *shard[T]) bool {
+	for _, s := range storage.shards {
+		if s == current || !s.mutex.TryLock() {
+			continue
+		}
+		evicted := storage.evictOldest(s)
+		s.mutex.Unlock()
+		if evicted {
+			return true
+		}
+	}
+	return false
+}
+
+// evictOldest evicts the entry closest to expiration, items of already flushed or evicted entries are skipped,
+// so a list refilled after flushing does not lose its entries in place of older ones.
+func (storage *ListStorage[T]) evictOldest(s *shard[T]) bool {
+	for s.index.Len() > 0 {
+		item := s.index.pop()
+		if _, found := s.entries[item.key].find(item.sequence); found {
+			storage.evict(s, item.key, item.sequence)
+			return true
+		}
+	}
+	return false
+}
+
+func (storage *ListStorage[T]) evictHead(s *shard[T], key string) {
+	storage.evict(s, key, s.entries[key][0].Sequence)
+}
+
+func (storage *ListStorage[T]) evict(s *shard[T], key string, sequence uint64) {
+	s.evict(key, sequence)
+	storage.evicted.Add(1)
+	storage.journal.log(record{Op: opEvict, Key: key, Sequence: sequence}, nil)
+}
+
+func (s *shard[T]) evict(key string, sequence uint64) {
+	entries := s.entries[key]
+	i, found := entries.find(sequence)
+	if !found {
+		return
+	}
+	// the head is evicted most often, it is dropped without moving remaining entries
+	if i == 0 {
+		s.store(key, entries[1:])
+	} else {
+		s.store(key, slices.Delete(entries, i, i+1))
+	}
+	s.resize(-1)
+}
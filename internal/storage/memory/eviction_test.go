@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldBoundStorage(t *testing.T) {
+	type appended struct {
+		key   string
+		value string
+		ttl   time.Duration
+	}
+	type testCase struct {
+		name          string
+		options       ListOptions
+		appends       []appended
+		want          map[string][]string
+		wantEvictions Evictions
+	}
+	tests := []testCase{
+		{
+			name:    "should keep everything without limits",
+			options: ListOptions{Shards: 1},
+			appends: []appended{{"a", "1", time.Minute}, {"a", "2", time.Minute}, {"b", "3", time.Minute}},
+			want:    map[string][]string{"a": {"1", "2"}, "b": {"3"}},
+		},
+		{
+			name:          "should evict oldest entry of full key",
+			options:       ListOptions{Shards: 1, MaxEntriesPerKey: 2},
+			appends:       []appended{{"a", "1", time.Minute}, {"a", "2", time.Minute}, {"a", "3", time.Minute}, {"b", "4", time.Minute}},
+			want:          map[string][]string{"a": {"2", "3"}, "b": {"4"}},
+			wantEvictions: Evictions{Evicted: 1},
+		},
+		{
+			name:          "should evict entry closest to expiration of full storage",
+			options:       ListOptions{Shards: 1, MaxEntries: 2},
+			appends:       []appended{{"a", "1", time.Hour}, {"b", "2", time.Minute}, {"a", "3", time.Hour}},
+			want:          map[string][]string{"a": {"1", "3"}, "b": {}},
+			wantEvictions: Evictions{Evicted: 1},
+		},
+		{
+			name:          "should drop new entries of full key",
+			options:       ListOptions{Shards: 1, MaxEntriesPerKey: 1, Policy: DropNew},
+			appends:       []appended{{"a", "1", time.Minute}, {"a", "2", time.Minute}, {"b", "3", time.Minute}},
+			want:          map[string][]string{"a": {"1"}, "b": {"3"}},
+			wantEvictions: Evictions{Dropped: 1},
+		},
+		{
+			name:          "should drop new entries of full storage",
+			options:       ListOptions{Shards: 1, MaxEntries: 2, Policy: DropNew},
+			appends:       []appended{{"a", "1", time.Minute}, {"a", "2", time.Minute}, {"b", "3", time.Minute}},
+			want:          map[string][]string{"a": {"1", "2"}, "b": {}},
+			wantEvictions: Evictions{Dropped: 1},
+		},
+		{
+			name:          "should rotate entries of the same key in full storage",
+			options:       ListOptions{Shards: 1, MaxEntries: 2, Policy: RingBuffer},
+			appends:       []appended{{"a", "1", time.Minute}, {"b", "2", time.Minute}, {"a", "3", time.Minute}, {"c", "4", time.Minute}},
+			want:          map[string][]string{"a": {"3"}, "b": {"2"}, "c": {}},
+			wantEvictions: Evictions{Evicted: 1, Dropped: 1},
+		},
+		{
+			name:          "should rotate entries of full key",
+			options:       ListOptions{Shards: 1, MaxEntriesPerKey: 2, Policy: RingBuffer},
+			appends:       []appended{{"a", "1", time.Minute}, {"a", "2", time.Minute}, {"a", "3", time.Minute}, {"a", "4", time.Minute}},
+			want:          map[string][]string{"a": {"3", "4"}},
+			wantEvictions: Evictions{Evicted: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewListStorageWithOptions[string](tt.options)
+			for _, a := range tt.appends {
+				s.Append(a.key, a.value, a.ttl)
+			}
+			require.Equal(t, tt.wantEvictions, s.Evictions())
+			for key, values := range tt.want {
+				require.Equal(t, values, s.FetchAndFlush(key), key)
+			}
+		})
+	}
+}
+
+func TestShouldBoundAllShardsByGlobalLimit(t *testing.T) {
+	s := NewListStorageWithOptions[int](ListOptions{Shards: 4, MaxEntries: 10})
+	for i := 0; i < 1000; i++ {
+		s.Append(string(rune('a'+i%26)), i, time.Minute)
+	}
+	total := 0
+	for i := range s.Shards() {
+		total += s.CleanShard(i).Left
+	}
+	require.Equal(t, 10, total)
+	require.Equal(t, 10, s.Size())
+	require.Equal(t, uint64(990), s.Evictions().Evicted)
+}
+
+func TestShouldNotExceedGlobalLimitWithConcurrentAppends(t *testing.T) {
+	s := NewListStorageWithOptions[int](ListOptions{Shards: 16, MaxEntries: 10, Policy: DropNew})
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				s.Append(fmt.Sprintf("%d-%d", worker, i), i, time.Minute)
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range s.Shards() {
+		total += s.CleanShard(i).Left
+	}
+	require.Equal(t, 10, total)
+	require.Equal(t, 10, s.Size())
+	require.Equal(t, uint64(1590), s.Evictions().Dropped)
+}
+
+func TestShouldEvictFromOtherShardWhenAppendingToEmptyOne(t *testing.T) {
+	s := NewListStorageWithOptions[int](ListOptions{Shards: 64, MaxEntries: 1})
+	s.Append("first", 1, time.Minute)
+	s.Append("second", 2, time.Minute)
+	require.Empty(t, s.Peek("first"))
+	require.Equal(t, []int{2}, s.Peek("second"))
+	require.Equal(t, 1, s.Size())
+}
+
+func TestShouldKeepExpirationIndexBoundedByMaxEntries(t *testing.T) {
+	s := NewListStorageWithOptions[int](ListOptions{Shards: 2, MaxEntries: 100})
+	for i := 0; i < 100000; i++ {
+		key := string(rune('a' + i%26))
+		s.Append(key, i, time.Hour)
+		if i%3 == 0 {
+			s.FetchAndFlush(key)
+		}
+	}
+	items := 0
+	for _, shard := range s.shards {
+		items += shard.index.Len()
+	}
+	require.LessOrEqual(t, s.Size(), 100)
+	require.LessOrEqual(t, items, 2*100+s.Shards()*minIndexSize)
+}
+
+func TestShouldNotEvictRefilledListInPlaceOfOlderEntry(t *testing.T) {
+	s := NewListStorageWithOptions[string](ListOptions{Shards: 1, MaxEntries: 2})
+	s.Append("a", "flushed", time.Minute)
+	s.FetchAndFlush("a")
+	s.Append("b", "oldest", time.Hour)
+	s.Append("a", "refilled", 2*time.Hour)
+	s.Append("c", "new", 3*time.Hour)
+
+	require.Empty(t, s.Peek("b"))
+	require.Equal(t, []string{"refilled"}, s.Peek("a"))
+	require.Equal(t, []string{"new"}, s.Peek("c"))
+}
+
+func TestShouldRejectUnknownEvictionPolicy(t *testing.T) {
+	policy, err := ParseEvictionPolicy("")
+	require.NoError(t, err)
+	require.Equal(t, OldestFirst, policy)
+	_, err = ParseEvictionPolicy("random")
+	require.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package memory
+
+import "container/heap"
+
+// expiration points to the entry of the list which expires at the given time (unix nanoseconds),
+// the entry is identified by its sequence number.
+type expiration struct {
+	at       int64
+	key      string
+	sequence uint64
+}
+
+// expirationIndex is a min-heap of expirations, so the cleaner can reach expired entries
+// without walking all keys. Items of flushed or evicted entries are left in the index until they expire
+// or the index is compacted, their sequence numbers are no longer found in lists.
+type expirationIndex []expiration
+
+func (index expirationIndex) Len() int           { return len(index) }
+func (index expirationIndex) Less(i, j int) bool { return index[i].at < index[j].at }
+func (index expirationIndex) Swap(i, j int)      { index[i], index[j] = index[j], index[i] }
+
+func (index *expirationIndex) Push(item any) {
+	*index = append(*index, item.(expiration))
+}
+
+func (index *expirationIndex) Pop() any {
+	old := *index
+	item := old[len(old)-1]
+	*index = old[:len(old)-1]
+	return item
+}
+
+func (index *expirationIndex) add(key string, at int64, sequence uint64) {
+	heap.Push(index, expiration{at: at, key: key, sequence: sequence})
+}
+
+func (index *expirationIndex) pop() expiration {
+	return heap.Pop(index).(expiration)
+}
+
+// popExpired removes expirations up to now and returns the number of them per key.
+func (index *expirationIndex) popExpired(now int64) map[string]int {
+	expired := make(map[string]int)
+	for index.Len() > 0 && (*index)[0].at <= now {
+		expired[index.pop().key]++
+	}
+	return expired
+}
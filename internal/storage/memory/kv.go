@@ -1,6 +1,9 @@
 package memory
 
 import (
+	"encoding/json"
+	"fmt"
+	"maps"
 	"sync"
 
 	"github.com/ziollek/etherscription/pkg/storage"
@@ -9,6 +12,7 @@ import (
 type KVStorage[T any] struct {
 	entries map[string]T
 	mutex   sync.RWMutex
+	journal journal
 }
 
 func NewKVStorage[T any]() storage.KVSaver[T] {
@@ -29,4 +33,69 @@ func (storage *KVStorage[T]) Set(key string, value T) {
 	storage.mutex.Lock()
 	defer storage.mutex.Unlock()
 	storage.entries[key] = value
+	storage.journal.log(record{Op: opSet, Key: key}, value)
+}
+
+func (storage *KVStorage[T]) Delete(key string) bool {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	_, found := storage.entries[key]
+	if found {
+		delete(storage.entries, key)
+		storage.journal.log(record{Op: opDelete, Key: key}, nil)
+	}
+	return found
+}
+
+func (storage *KVStorage[_]) Keys() []string {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	keys := make([]string, 0, len(storage.entries))
+	for key := range storage.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (storage *KVStorage[_]) Count() int {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	return len(storage.entries)
+}
+
+func (kv *KVStorage[_]) Scan(cursor string, limit int) ([]string, string) {
+	return storage.Page(kv.Keys(), cursor, limit)
+}
+
+func (storage *KVStorage[_]) lock() {
+	storage.mutex.Lock()
+}
+
+func (storage *KVStorage[_]) unlock() {
+	storage.mutex.Unlock()
+}
+
+func (storage *KVStorage[_]) capture() func() (json.RawMessage, error) {
+	entries := maps.Clone(storage.entries)
+	return func() (json.RawMessage, error) { return json.Marshal(entries) }
+}
+
+func (storage *KVStorage[_]) restore(data json.RawMessage) error {
+	return json.Unmarshal(data, &storage.entries)
+}
+
+func (storage *KVStorage[T]) replay(r record) error {
+	switch r.Op {
+	case opSet:
+		var value T
+		if err := json.Unmarshal(r.Value, &value); err != nil {
+			return err
+		}
+		storage.entries[r.Key] = value
+	case opDelete:
+		delete(storage.entries, r.Key)
+	default:
+		return fmt.Errorf("unsupported operation: %s", r.Op)
+	}
+	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/storage"
 )
 
 func TestShouldStoreAndRetrieveDataFromMemoryByKey(t *testing.T) {
@@ -33,3 +34,57 @@ func TestShouldStoreAndRetrieveDataFromMemoryByKey(t *testing.T) {
 		})
 	}
 }
+
+func TestShouldDeleteKeysAndListRemainingOnes(t *testing.T) {
+	type testCase struct {
+		name    string
+		set     []string
+		delete  map[string]bool
+		wantKey []string
+	}
+	tests := []testCase{
+		{
+			name:    "should list no keys for empty storage",
+			set:     []string{},
+			delete:  map[string]bool{"first": false},
+			wantKey: []string{},
+		},
+		{
+			name:    "should delete existing key",
+			set:     []string{"first", "second"},
+			delete:  map[string]bool{"first": true},
+			wantKey: []string{"second"},
+		},
+		{
+			name:    "should not report deletion of missing key",
+			set:     []string{"first"},
+			delete:  map[string]bool{"second": false},
+			wantKey: []string{"first"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kv := NewKVStorage[string]()
+			for _, key := range tt.set {
+				kv.Set(key, key)
+			}
+			for key, want := range tt.delete {
+				require.Equal(t, want, kv.Delete(key))
+			}
+			require.ElementsMatch(t, tt.wantKey, kv.Keys())
+			require.Equal(t, len(tt.wantKey), kv.(storage.Counter).Count())
+		})
+	}
+}
+
+func TestShouldScanKeysInOrder(t *testing.T) {
+	kv := NewKVStorage[int]()
+	for i, key := range []string{"c", "a", "b"} {
+		kv.Set(key, i)
+	}
+	keys, cursor := kv.Scan("", 2)
+	require.Equal(t, []string{"a", "b"}, keys)
+	keys, cursor = kv.Scan(cursor, 2)
+	require.Equal(t, []string{"c"}, keys)
+	require.Empty(t, cursor)
+}
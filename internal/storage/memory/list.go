@@ -1,13 +1,30 @@
 package memory
 
 import (
+	"cmp"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+const (
+	// DefaultShards is the number of shards used by NewListStorage.
+	DefaultShards = 32
+	// minIndexSize is the number of items of removed entries tolerated in the expiration index of an empty shard
+	minIndexSize = 64
 )
 
 type Entry[T any] struct {
 	Value      T
 	Expiration time.Time
+	Sequence   uint64
+	AddedAt    time.Time
 }
 
 type Entries[T any] []Entry[T]
@@ -22,6 +39,40 @@ func (entries Entries[T]) Expire(now time.Time) Entries[T] {
 	return newEntries
 }
 
+// trim removes expired entries knowing that at most limit of them expired, when all of them
+// form the head of the list, which is the case for a constant ttl, only the head is examined.
+// It returns remaining entries and the number of examined ones.
+func (entries Entries[T]) trim(limit int, now time.Time) (Entries[T], int) {
+	head := 0
+	for head < len(entries) && head < limit && !entries[head].Expiration.After(now) {
+		head++
+	}
+	if head == limit {
+		return entries[head:], head
+	}
+	return entries.Expire(now), len(entries)
+}
+
+func (entries Entries[T]) Items(query storage.RangeQuery) []storage.Item[T] {
+	items := make([]storage.Item[T], 0)
+	for _, entry := range entries {
+		if query.Full(len(items)) {
+			break
+		}
+		if query.Matches(entry.Sequence, entry.AddedAt) {
+			items = append(items, storage.Item[T]{Sequence: entry.Sequence, AddedAt: entry.AddedAt, Value: entry.Value})
+		}
+	}
+	return items
+}
+
+// find returns the position of the entry with the sequence number, entries are ordered by sequence numbers.
+func (entries Entries[T]) find(sequence uint64) (int, bool) {
+	return slices.BinarySearchFunc(entries, sequence, func(entry Entry[T], sequence uint64) int {
+		return cmp.Compare(entry.Sequence, sequence)
+	})
+}
+
 func (entries Entries[T]) Values() []T {
 	values := make([]T, len(entries))
 	for i, entry := range entries {
@@ -30,61 +81,381 @@ func (entries Entries[T]) Values() []T {
 	return values
 }
 
-type ListStorage[T any] struct {
+// CleaningStats describes the cost of cleaning, Examined counts entries checked during cleaning.
+type CleaningStats struct {
+	Keys     int
+	Examined int
+	Removed  int
+	Left     int
+}
+
+func (stats *CleaningStats) Add(other CleaningStats) {
+	stats.Keys += other.Keys
+	stats.Examined += other.Examined
+	stats.Removed += other.Removed
+	stats.Left += other.Left
+}
+
+// shard guards a subset of keys, so operations on keys from different shards do not contend.
+type shard[T any] struct {
 	entries map[string]Entries[T]
-	mutex   sync.RWMutex
+	index   expirationIndex
+	// size is the number of entries in the shard, including not yet cleaned outdated ones,
+	// total is the same number for the whole storage, it is shared by all shards
+	size  int
+	total *atomic.Int64
+	mutex sync.RWMutex
+}
+
+var _ shardCleanable = (*ListStorage[any])(nil)
+
+// ListStorage spreads keys over shards by their hash, every shard has its own lock.
+type ListStorage[T any] struct {
+	shards   []*shard[T]
+	options  ListOptions
+	size     atomic.Int64
+	evicted  atomic.Uint64
+	dropped  atomic.Uint64
+	sequence atomic.Uint64
+	journal  journal
 }
 
 func NewListStorage[T any]() *ListStorage[T] {
-	return &ListStorage[T]{
-		entries: make(map[string]Entries[T]),
-		mutex:   sync.RWMutex{},
+	return NewShardedListStorage[T](DefaultShards)
+}
+
+// NewShardedListStorage creates a storage with the given number of shards, at least one shard is always created.
+func NewShardedListStorage[T any](shards int) *ListStorage[T] {
+	return NewListStorageWithOptions[T](ListOptions{Shards: max(1, shards)})
+}
+
+// NewListStorageWithOptions creates a storage bounded by limits of options, the default number of shards is used
+// when it is not provided, an empty policy means OldestFirst.
+func NewListStorageWithOptions[T any](options ListOptions) *ListStorage[T] {
+	if options.Shards < 1 {
+		options.Shards = DefaultShards
 	}
+	if options.Policy == "" {
+		options.Policy = OldestFirst
+	}
+	storage := &ListStorage[T]{shards: make([]*shard[T], options.Shards), options: options}
+	for i := range storage.shards {
+		storage.shards[i] = &shard[T]{entries: make(map[string]Entries[T]), total: &storage.size}
+	}
+	return storage
 }
 
 func (storage *ListStorage[T]) FetchAndFlush(key string) []T {
 	// this operation must be atomic to not lose any (not expired) data
-	storage.mutex.Lock()
-	defer storage.mutex.Unlock()
-	if list, found := storage.entries[key]; found {
-		delete(storage.entries, key)
+	s := storage.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if list, found := s.entries[key]; found {
+		delete(s.entries, key)
+		s.resize(-len(list))
+		storage.journal.log(record{Op: opFlush, Key: key}, nil)
 		return list.Expire(time.Now()).Values()
 	}
 	return []T{}
 }
 
 func (storage *ListStorage[T]) Append(key string, value T, ttl time.Duration) {
-	storage.mutex.Lock()
-	defer storage.mutex.Unlock()
-	if _, found := storage.entries[key]; !found {
-		storage.entries[key] = Entries[T]{}
+	storage.AppendBounded(key, value, ttl, 0)
+}
+
+func (storage *ListStorage[T]) AppendBounded(key string, value T, ttl time.Duration, limit int) bool {
+	s := storage.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if limit > 0 && len(s.entries[key]) >= limit {
+		return false
+	}
+	storage.append(s, key, value, ttl)
+	return true
+}
+
+// append is called under the lock of the shard.
+func (storage *ListStorage[T]) append(s *shard[T], key string, value T, ttl time.Duration) {
+	if !storage.makeRoom(s, key) {
+		storage.dropped.Add(1)
+		return
 	}
-	storage.entries[key] = append(storage.entries[key], Entry[T]{value, time.Now().Add(ttl)})
+	now := time.Now()
+	entry := Entry[T]{Value: value, Expiration: now.Add(ttl), Sequence: storage.sequence.Add(1), AddedAt: now}
+	// the slot in the size of the storage was reserved by makeRoom
+	s.insert(key, entry)
+	storage.journal.log(
+		record{Op: opAppend, Key: key, Expiration: entry.Expiration, Sequence: entry.Sequence, At: entry.AddedAt}, value,
+	)
 }
 
-func (storage *ListStorage[_]) GetKeys() []string {
-	storage.mutex.RLock()
-	defer storage.mutex.RUnlock()
-	keys := make([]string, 0, len(storage.entries))
-	for key := range storage.entries {
-		keys = append(keys, key)
+func (storage *ListStorage[_]) Len(key string) int {
+	s := storage.shard(key)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	now := time.Now()
+	count := 0
+	for _, entry := range s.entries[key] {
+		if entry.Expiration.After(now) {
+			count++
+		}
+	}
+	return count
+}
+
+func (storage *ListStorage[T]) Peek(key string) []T {
+	s := storage.shard(key)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.entries[key].Expire(time.Now()).Values()
+}
+
+func (storage *ListStorage[T]) Range(key string, query storage.RangeQuery) []storage.Item[T] {
+	s := storage.shard(key)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.entries[key].Expire(time.Now()).Items(query)
+}
+
+func (storage *ListStorage[_]) Delete(key string) bool {
+	s := storage.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list, found := s.entries[key]
+	if found {
+		delete(s.entries, key)
+		s.resize(-len(list))
+		storage.journal.log(record{Op: opFlush, Key: key}, nil)
+	}
+	return found
+}
+
+func (list *ListStorage[_]) Scan(cursor string, limit int) ([]string, string) {
+	return storage.Page(list.Keys(), cursor, limit)
+}
+
+func (storage *ListStorage[_]) Keys() []string {
+	keys := make([]string, 0)
+	for _, s := range storage.shards {
+		s.mutex.RLock()
+		for key := range s.entries {
+			keys = append(keys, key)
+		}
+		s.mutex.RUnlock()
 	}
 	return keys
 }
 
+func (storage *ListStorage[_]) Count() int {
+	count := 0
+	for _, s := range storage.shards {
+		s.mutex.RLock()
+		count += len(s.entries)
+		s.mutex.RUnlock()
+	}
+	return count
+}
+
 func (storage *ListStorage[_]) CleanOutdated(key string) (int, int) {
-	storage.mutex.Lock()
-	defer storage.mutex.Unlock()
-	if entries, found := storage.entries[key]; found {
-		after := entries.Expire(time.Now())
-		if len(after) > 0 {
-			storage.entries[key] = after
-		} else {
-			// we should not store empty lists
-			// because the size of the map will grow indefinitely
-			delete(storage.entries, key)
+	s := storage.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return storage.cleanKey(s, key, time.Now())
+}
+
+// Evictions returns the number of entries discarded because of limits since the storage was created.
+func (storage *ListStorage[_]) Evictions() Evictions {
+	return Evictions{Evicted: storage.evicted.Load(), Dropped: storage.dropped.Load()}
+}
+
+// Shards returns the number of shards, it allows the cleaner to clean the storage shard by shard.
+func (storage *ListStorage[_]) Shards() int {
+	return len(storage.shards)
+}
+
+// Size returns the number of entries of all lists, including outdated ones which are not cleaned yet.
+func (storage *ListStorage[_]) Size() int {
+	return int(storage.size.Load())
+}
+
+// CleanShard removes outdated entries of the shard taking its lock only once,
+// thanks to the expiration index only lists containing expired entries are touched.
+func (storage *ListStorage[_]) CleanShard(index int) CleaningStats {
+	s := storage.shards[index]
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	stats := CleaningStats{}
+	for key, limit := range s.index.popExpired(now.UnixNano()) {
+		entries, found := s.entries[key]
+		if !found {
+			continue
+		}
+		after, examined := entries.trim(limit, now)
+		s.store(key, after)
+		s.resize(len(after) - len(entries))
+		stats.Keys++
+		stats.Examined += examined
+		stats.Removed += len(entries) - len(after)
+		if len(after) < len(entries) {
+			storage.journal.log(record{Op: opClean, Key: key, At: now}, nil)
+		}
+	}
+	stats.Left = s.size
+	return stats
+}
+
+// cleanKey is called under the lock of the shard.
+func (storage *ListStorage[T]) cleanKey(s *shard[T], key string, now time.Time) (int, int) {
+	if entries, found := s.entries[key]; found {
+		after := s.clean(key, entries, now)
+		if len(after) < len(entries) {
+			storage.journal.log(record{Op: opClean, Key: key, At: now}, nil)
 		}
 		return len(entries), len(after)
 	}
 	return 0, 0
 }
+
+func (s *shard[T]) append(key string, entry Entry[T]) {
+	s.total.Add(1)
+	s.insert(key, entry)
+}
+
+// insert does not change the size of the storage, it is used when the slot was already reserved.
+func (s *shard[T]) insert(key string, entry Entry[T]) {
+	s.entries[key] = append(s.entries[key], entry)
+	s.index.add(key, entry.Expiration.UnixNano(), entry.Sequence)
+	s.size++
+}
+
+func (s *shard[T]) resize(delta int) {
+	s.size += delta
+	s.total.Add(int64(delta))
+	if delta < 0 {
+		s.compact()
+	}
+}
+
+// compact rebuilds the expiration index once items of removed entries outnumber stored entries,
+// so the index stays proportional to the size of the shard, the cost is amortized over removals.
+func (s *shard[T]) compact() {
+	if len(s.index) <= 2*s.size+minIndexSize {
+		return
+	}
+	index := make(expirationIndex, 0, s.size)
+	for key, entries := range s.entries {
+		for _, entry := range entries {
+			index = append(index, expiration{at: entry.Expiration.UnixNano(), key: key, sequence: entry.Sequence})
+		}
+	}
+	heap.Init(&index)
+	s.index = index
+}
+
+func (s *shard[T]) clean(key string, entries Entries[T], now time.Time) Entries[T] {
+	after := entries.Expire(now)
+	s.store(key, after)
+	s.resize(len(after) - len(entries))
+	return after
+}
+
+func (s *shard[T]) store(key string, entries Entries[T]) {
+	if len(entries) > 0 {
+		s.entries[key] = entries
+	} else {
+		// we should not store empty lists
+		// because the size of the map will grow indefinitely
+		delete(s.entries, key)
+	}
+}
+
+// shard picks a shard using FNV-1a hash of the key, it is computed inline to avoid allocations.
+func (storage *ListStorage[T]) shard(key string) *shard[T] {
+	const (
+		offset = 2166136261
+		prime  = 16777619
+	)
+	hash := uint32(offset)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= prime
+	}
+	return storage.shards[hash%uint32(len(storage.shards))]
+}
+
+func (storage *ListStorage[_]) lock() {
+	for _, s := range storage.shards {
+		s.mutex.Lock()
+	}
+}
+
+func (storage *ListStorage[_]) unlock() {
+	for _, s := range storage.shards {
+		s.mutex.Unlock()
+	}
+}
+
+func (storage *ListStorage[T]) capture() func() (json.RawMessage, error) {
+	entries := make(map[string]Entries[T])
+	for _, s := range storage.shards {
+		for key, list := range s.entries {
+			entries[key] = slices.Clone(list)
+		}
+	}
+	return func() (json.RawMessage, error) { return json.Marshal(entries) }
+}
+
+func (storage *ListStorage[T]) restore(data json.RawMessage) error {
+	entries := make(map[string]Entries[T])
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for key, list := range entries {
+		s := storage.shard(key)
+		for _, entry := range list {
+			s.append(key, entry)
+			storage.advanceSequence(entry.Sequence)
+		}
+	}
+	return nil
+}
+
+func (storage *ListStorage[T]) replay(r record) error {
+	s := storage.shard(r.Key)
+	switch r.Op {
+	case opAppend:
+		var value T
+		if err := json.Unmarshal(r.Value, &value); err != nil {
+			return err
+		}
+		s.append(r.Key, Entry[T]{Value: value, Expiration: r.Expiration, Sequence: r.Sequence, AddedAt: r.At})
+		storage.advanceSequence(r.Sequence)
+	case opFlush:
+		s.resize(-len(s.entries[r.Key]))
+		delete(s.entries, r.Key)
+	case opClean:
+		if entries, found := s.entries[r.Key]; found {
+			s.clean(r.Key, entries, r.At)
+		}
+	case opEvict:
+		// evictions journaled before entries were identified by sequence numbers always removed the head
+		if entries := s.entries[r.Key]; len(entries) > 0 {
+			sequence := r.Sequence
+			if sequence == 0 {
+				sequence = entries[0].Sequence
+			}
+			s.evict(r.Key, sequence)
+		}
+	default:
+		return fmt.Errorf("unsupported operation: %s", r.Op)
+	}
+	return nil
+}
+
+// advanceSequence makes sure that restored entries are followed by greater sequence numbers.
+func (storage *ListStorage[_]) advanceSequence(sequence uint64) {
+	if storage.sequence.Load() < sequence {
+		storage.sequence.Store(sequence)
+	}
+}
@@ -1,10 +1,15 @@
 package memory
 
 import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/storage/storagetest"
 )
 
 func TestShouldReturnOnlyUpToDateEntries(t *testing.T) {
@@ -114,7 +119,7 @@ func TestShouldAppendNewEntriesForKeyAndFlushThemAfterRead(t *testing.T) {
 			for _, value := range tt.after {
 				s.Append(tt.key, value, time.Second)
 			}
-			require.Equal(t, []string{tt.key}, s.GetKeys())
+			require.Equal(t, []string{tt.key}, s.Keys())
 			require.Equal(t, tt.expected, s.FetchAndFlush(tt.key))
 			require.Equal(t, []string{}, s.FetchAndFlush(tt.key))
 		})
@@ -200,7 +205,183 @@ func TestShouldCleanEmptyLists(t *testing.T) {
 				s.Append(tt.key, value, time.Second)
 			}
 			s.CleanOutdated(tt.key)
-			require.Equal(t, tt.expected, s.GetKeys())
+			require.Equal(t, tt.expected, s.Keys())
 		})
 	}
 }
+
+func TestShouldCountOnlyActiveEntries(t *testing.T) {
+	type testCase struct {
+		name     string
+		outdated []string
+		active   []string
+		expected int
+	}
+	tests := []testCase{
+		{name: "should count nothing for empty storage", outdated: []string{}, active: []string{}, expected: 0},
+		{name: "should count only active", outdated: []string{"first"}, active: []string{"second", "third"}, expected: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewListStorage[string]()
+			for _, value := range tt.outdated {
+				s.Append("key", value, -time.Second)
+			}
+			for _, value := range tt.active {
+				s.Append("key", value, time.Second)
+			}
+			require.Equal(t, tt.expected, s.Len("key"))
+		})
+	}
+}
+
+func TestShouldCleanStorageShardByShard(t *testing.T) {
+	s := NewShardedListStorage[string](4)
+	keys := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		keys = append(keys, key)
+		s.Append(key, "outdated", -time.Second)
+		if i%2 == 0 {
+			s.Append(key, "active", time.Second)
+		}
+	}
+	got := s.Keys()
+	sort.Strings(got)
+	sort.Strings(keys)
+	require.Equal(t, keys, got)
+
+	stats := CleaningStats{}
+	for i := range s.Shards() {
+		stats.Add(s.CleanShard(i))
+	}
+	require.Equal(t, CleaningStats{Keys: 20, Examined: 20, Removed: 20, Left: 10}, stats)
+	require.Len(t, s.Keys(), 10)
+	require.Equal(t, []string{"active"}, s.FetchAndFlush("key-0"))
+}
+
+func TestShouldTouchOnlyExpiredEntriesWhileCleaning(t *testing.T) {
+	s := NewShardedListStorage[string](1)
+	for i := 0; i < 100; i++ {
+		s.Append("active", "value", time.Minute)
+	}
+	s.Append("mixed", "outdated", -time.Second)
+	s.Append("mixed", "active", time.Minute)
+	// expiration is not ordered, so the whole list has to be examined
+	s.Append("unordered", "active", time.Minute)
+	s.Append("unordered", "outdated", -time.Second)
+	// flushed lists are left in the index
+	s.Append("flushed", "outdated", -time.Second)
+	s.FetchAndFlush("flushed")
+
+	require.Equal(t, CleaningStats{Keys: 2, Examined: 3, Removed: 2, Left: 102}, s.CleanShard(0))
+	require.Equal(t, CleaningStats{Left: 102}, s.CleanShard(0))
+	require.Equal(t, []string{"active"}, s.FetchAndFlush("mixed"))
+	require.Equal(t, []string{"active"}, s.FetchAndFlush("unordered"))
+}
+
+func TestShouldTrimOnlyHeadOfOrderedEntries(t *testing.T) {
+	now := time.Now()
+	outdated := Entry[string]{Value: "outdated", Expiration: now.Add(-time.Second)}
+	active := Entry[string]{Value: "active", Expiration: now.Add(time.Second)}
+	type testCase struct {
+		name         string
+		entries      Entries[string]
+		limit        int
+		want         Entries[string]
+		wantExamined int
+	}
+	tests := []testCase{
+		{
+			name:         "should examine only expired head",
+			entries:      Entries[string]{outdated, outdated, active, active},
+			limit:        2,
+			want:         Entries[string]{active, active},
+			wantExamined: 2,
+		},
+		{
+			name:         "should examine all entries when expired ones are not in head",
+			entries:      Entries[string]{outdated, active, outdated},
+			limit:        2,
+			want:         Entries[string]{active},
+			wantExamined: 3,
+		},
+		{
+			name:         "should examine all entries when limit is overestimated",
+			entries:      Entries[string]{outdated, active},
+			limit:        2,
+			want:         Entries[string]{active},
+			wantExamined: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, examined := tt.entries.trim(tt.limit, now)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.wantExamined, examined)
+		})
+	}
+}
+
+func TestShouldCreateAtLeastOneShard(t *testing.T) {
+	s := NewShardedListStorage[string](0)
+	s.Append("key", "value", time.Second)
+	require.Equal(t, 1, s.Shards())
+	require.Equal(t, []string{"value"}, s.FetchAndFlush("key"))
+}
+
+// BenchmarkListStorage runs a mix of appends, reads and fetches from parallel goroutines
+// while a cleaner continuously cleans the storage, compare ns/op across shard counts.
+func BenchmarkListStorage(b *testing.B) {
+	const keys = 1024
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = fmt.Sprintf("0x%040d", i)
+	}
+	for _, shards := range []int{1, 4, 16, DefaultShards, 128} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			s := NewShardedListStorage[int](shards)
+			done := make(chan struct{})
+			cleaning := sync.WaitGroup{}
+			cleaning.Add(1)
+			go func() {
+				defer cleaning.Done()
+				for {
+					select {
+					case <-done:
+						return
+					case <-time.After(time.Millisecond):
+						for i := range s.Shards() {
+							s.CleanShard(i)
+						}
+					}
+				}
+			}()
+			goroutines := atomic.Int64{}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				// every goroutine starts from a different key to not move in lockstep with the others
+				i := int(goroutines.Add(1)) * 7919
+				for pb.Next() {
+					key := names[i%keys]
+					switch i % 10 {
+					case 0:
+						s.FetchAndFlush(key)
+					case 1, 2:
+						s.Len(key)
+					default:
+						s.Append(key, i, time.Minute)
+					}
+					i++
+				}
+			})
+			b.StopTimer()
+			close(done)
+			cleaning.Wait()
+		})
+	}
+}
+
+func TestShouldConformToListSaver(t *testing.T) {
+	storagetest.TestListSaver(t, func(*testing.T) storagetest.ListStorage { return NewListStorage[string]() })
+}
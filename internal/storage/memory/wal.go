@@ -0,0 +1,452 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/metrics"
+)
+
+const (
+	walFile      = "wal.log"
+	snapshotFile = "snapshot.json"
+	dirMode      = 0o700
+	fileMode     = 0o600
+)
+
+const (
+	opSet    = "set"
+	opDelete = "delete"
+	opAppend = "append"
+	opFlush  = "flush"
+	opClean  = "clean"
+	opEvict  = "evict"
+)
+
+// record is a single operation stored in the log, every record gets a sequence number,
+// so records already included in a snapshot are skipped during replay.
+type record struct {
+	Seq        uint64          `json:"seq"`
+	Store      string          `json:"store"`
+	Op         string          `json:"op"`
+	Key        string          `json:"key"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	Expiration time.Time       `json:"expiration"`
+	Sequence   uint64          `json:"sequence,omitempty"`
+	At         time.Time       `json:"at"`
+}
+
+type snapshot struct {
+	Seq    uint64                     `json:"seq"`
+	Stores map[string]json.RawMessage `json:"stores"`
+}
+
+// journaled is implemented by storages persisted by WAL.
+type journaled interface {
+	lock()
+	unlock()
+	// capture is called under the lock, it copies the state and returns a function encoding the copy,
+	// so the storage is not blocked while a snapshot is encoded
+	capture() func() (json.RawMessage, error)
+	restore(data json.RawMessage) error
+	replay(r record) error
+}
+
+// WAL makes memory storages durable: every modification is appended to the log,
+// the log is periodically compacted into a snapshot, both are replayed on startup.
+type WAL struct {
+	dir      string
+	cfg      *config.WALConfig
+	file     *os.File
+	writer   *bufio.Writer
+	mutex    sync.Mutex
+	names    []string
+	storages map[string]journaled
+	// loaded state waiting for storages to be registered
+	snapshot snapshot
+	records  map[string][]record
+	done     chan struct{}
+	stopped  sync.WaitGroup
+	// failure is the last error of writing or syncing the log, records which could not be written are not retried,
+	// so it is cleared only by a snapshot captured after it, failures counts them to tell whether it was
+	failure  error
+	failures uint64
+	// seq is assigned before taking the mutex, records of different keys may be written out of order,
+	// records of the same key are written under the lock of its storage, so they keep their order
+	seq atomic.Uint64
+}
+
+// OpenWAL loads the last snapshot and the log from the configured directory, state is applied to storages
+// when they are registered, so all of them should be created before the storage is used.
+func OpenWAL(cfg *config.WALConfig) (*WAL, error) {
+	if err := os.MkdirAll(cfg.Dir, dirMode); err != nil {
+		return nil, err
+	}
+	wal := &WAL{
+		dir:      cfg.Dir,
+		cfg:      cfg,
+		storages: make(map[string]journaled),
+		records:  make(map[string][]record),
+		snapshot: snapshot{Stores: make(map[string]json.RawMessage)},
+		done:     make(chan struct{}),
+	}
+	if err := wal.load(); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(wal.path(walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	wal.file = file
+	wal.writer = bufio.NewWriter(file)
+	wal.stopped.Add(1)
+	go wal.run()
+	return wal, nil
+}
+
+func NewDurableKVStorage[T any](wal *WAL, name string) (*KVStorage[T], error) {
+	kv := &KVStorage[T]{entries: make(map[string]T)}
+	kv.journal = journal{wal: wal, store: name}
+	return kv, wal.register(name, kv)
+}
+
+func NewDurableListStorage[T any](wal *WAL, name string, options ListOptions) (*ListStorage[T], error) {
+	list := NewListStorageWithOptions[T](options)
+	list.journal = journal{wal: wal, store: name}
+	return list, wal.register(name, list)
+}
+
+// Snapshot writes state of all storages to a new snapshot and removes records included in it from the log.
+// Storages are locked only to copy their state, they are modified and logged while the copy is encoded.
+func (wal *WAL) Snapshot() error {
+	seq, encoders, offset, failures, err := wal.capture()
+	if err != nil {
+		return err
+	}
+	current := snapshot{Seq: seq, Stores: make(map[string]json.RawMessage, len(wal.names))}
+	for i, name := range wal.names {
+		data, err := encoders[i]()
+		if err != nil {
+			return fmt.Errorf("cannot dump %s: %w", name, err)
+		}
+		current.Stores[name] = data
+	}
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	if err := writeFileSync(wal.path(snapshotFile), data); err != nil {
+		return err
+	}
+	// records are already in the snapshot, a crash before compacting is harmless because of sequence numbers
+	return wal.compact(offset, failures)
+}
+
+// Err returns the last failure of writing the log, modifications made since then may be lost on restart.
+func (wal *WAL) Err() error {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	if wal.failure != nil {
+		return fmt.Errorf("write-ahead log is failing: %w", wal.failure)
+	}
+	return nil
+}
+
+// capture copies state of all storages at the same sequence number, it returns the size of the log
+// containing exactly the records included in the copy and the number of failures so far.
+func (wal *WAL) capture() (uint64, []func() (json.RawMessage, error), int64, uint64, error) {
+	for _, name := range wal.names {
+		wal.storages[name].lock()
+		defer wal.storages[name].unlock()
+	}
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	if err := wal.sync(); err != nil {
+		return 0, nil, 0, 0, err
+	}
+	info, err := wal.file.Stat()
+	if err != nil {
+		return 0, nil, 0, 0, err
+	}
+	encoders := make([]func() (json.RawMessage, error), len(wal.names))
+	for i, name := range wal.names {
+		encoders[i] = wal.storages[name].capture()
+	}
+	return wal.seq.Load(), encoders, info.Size(), wal.failures, nil
+}
+
+// compact removes the first offset bytes of the log, records written after the snapshot was captured
+// are moved to a new log file replacing the current one. Failures which happened before the snapshot
+// was captured are cleared, their modifications are included in the snapshot.
+func (wal *WAL) compact(offset int64, failures uint64) error {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	if err := wal.sync(); err != nil {
+		return err
+	}
+	if wal.failures == failures {
+		wal.failure = nil
+	}
+	info, err := wal.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == offset {
+		return wal.file.Truncate(0)
+	}
+	source, err := os.Open(wal.path(walFile))
+	if err != nil {
+		return err
+	}
+	tail, err := io.ReadAll(io.NewSectionReader(source, offset, info.Size()-offset))
+	_ = source.Close()
+	if err != nil {
+		return err
+	}
+	if err := writeFileSync(wal.path(walFile), tail); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(wal.path(walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, fileMode)
+	if err != nil {
+		return err
+	}
+	_ = wal.file.Close()
+	wal.file = file
+	wal.writer.Reset(file)
+	return nil
+}
+
+// Close stops background syncing, compacts the log into a snapshot and closes the log file.
+func (wal *WAL) Close() error {
+	close(wal.done)
+	wal.stopped.Wait()
+	err := wal.Snapshot()
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	if flushErr := wal.writer.Flush(); flushErr != nil && err == nil {
+		err = flushErr
+	}
+	if closeErr := wal.file.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Discard stops background syncing and closes the log without compacting it,
+// it should be used when storages could not be restored, so the snapshot would be incomplete.
+func (wal *WAL) Discard() error {
+	close(wal.done)
+	wal.stopped.Wait()
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	return wal.file.Close()
+}
+
+func (wal *WAL) run() {
+	defer wal.stopped.Done()
+	syncTicker := newTicker(wal.cfg.SyncInterval)
+	defer syncTicker.Stop()
+	snapshotTicker := newTicker(wal.cfg.SnapshotInterval)
+	defer snapshotTicker.Stop()
+	for {
+		select {
+		case <-wal.done:
+			return
+		case <-syncTicker.C:
+			wal.mutex.Lock()
+			err := wal.sync()
+			wal.mutex.Unlock()
+			if err != nil {
+				logging.Module("memory").Err(err).Msg("Cannot sync write-ahead log")
+			}
+		case <-snapshotTicker.C:
+			start := time.Now()
+			if err := wal.Snapshot(); err != nil {
+				logging.Module("memory").Err(err).Msg("Cannot write snapshot")
+				continue
+			}
+			logging.Module("memory").Info().Dur("duration", time.Since(start)).Msg("Written snapshot")
+		}
+	}
+}
+
+func (wal *WAL) register(name string, storage journaled) error {
+	if _, found := wal.storages[name]; found {
+		return fmt.Errorf("storage %s is already registered", name)
+	}
+	if data, found := wal.snapshot.Stores[name]; found {
+		if err := storage.restore(data); err != nil {
+			return fmt.Errorf("cannot restore %s: %w", name, err)
+		}
+	}
+	for _, r := range wal.records[name] {
+		if err := storage.replay(r); err != nil {
+			return fmt.Errorf("cannot replay %s: %w", name, err)
+		}
+	}
+	delete(wal.records, name)
+	wal.names = append(wal.names, name)
+	wal.storages[name] = storage
+	return nil
+}
+
+// write is called by storages under their lock, so the order of records matches the order of modifications.
+// The record is numbered and encoded before taking the mutex, so storages wait for each other only while
+// the encoded record is buffered, or synced when SyncInterval is zero.
+func (wal *WAL) write(r record) {
+	r.Seq = wal.seq.Add(1)
+	data, err := json.Marshal(r)
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	if err == nil {
+		_, err = wal.writer.Write(append(data, '\n'))
+	}
+	if err != nil {
+		wal.fail(err)
+	} else if wal.cfg.SyncInterval <= 0 {
+		err = wal.sync()
+	}
+	if err != nil {
+		logging.Module("memory").Err(err).Str("store", r.Store).Str("key", r.Key).Msg("Cannot write to write-ahead log")
+	}
+}
+
+// sync is called under the mutex, failures are recorded, so they are reported by Err.
+func (wal *WAL) sync() error {
+	err := wal.writer.Flush()
+	if err == nil {
+		err = wal.file.Sync()
+	}
+	if err != nil {
+		wal.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (wal *WAL) fail(err error) {
+	wal.failure = err
+	wal.failures++
+	metrics.WALFailures.Inc()
+}
+
+func (wal *WAL) load() error {
+	data, err := os.ReadFile(wal.path(snapshotFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &wal.snapshot); err != nil {
+			return fmt.Errorf("cannot decode snapshot: %w", err)
+		}
+	}
+	wal.seq.Store(wal.snapshot.Seq)
+	file, err := os.Open(wal.path(walFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	complete, torn, err := wal.loadRecords(file)
+	if err != nil || !torn {
+		return err
+	}
+	// a line without the trailing new line was not fully written before a crash,
+	// it has to be removed, otherwise following records would be appended to it
+	return os.Truncate(wal.path(walFile), complete)
+}
+
+// loadRecords returns the size of fully written records and whether there is a partially written one.
+func (wal *WAL) loadRecords(reader io.Reader) (int64, bool, error) {
+	lines := bufio.NewReader(reader)
+	complete := int64(0)
+	for {
+		line, err := lines.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return complete, len(line) > 0, nil
+		}
+		if err != nil {
+			return complete, false, err
+		}
+		var r record
+		if err := json.Unmarshal(bytes.TrimSpace(line), &r); err != nil {
+			return complete, false, fmt.Errorf("cannot decode write-ahead log record after %d: %w", wal.seq.Load(), err)
+		}
+		complete += int64(len(line))
+		if r.Seq <= wal.snapshot.Seq {
+			continue
+		}
+		wal.seq.Store(max(wal.seq.Load(), r.Seq))
+		wal.records[r.Store] = append(wal.records[r.Store], r)
+	}
+}
+
+func (wal *WAL) path(name string) string {
+	return filepath.Join(wal.dir, name)
+}
+
+// journal is embedded in storages, zero value means that the storage is not durable.
+type journal struct {
+	wal   *WAL
+	store string
+}
+
+// log records the operation, value is encoded only when it is provided.
+func (j journal) log(r record, value any) {
+	if j.wal == nil {
+		return
+	}
+	r.Store = j.store
+	if value != nil {
+		data, err := json.Marshal(value)
+		if err != nil {
+			logging.Module("memory").Err(err).Str("store", j.store).Str("key", r.Key).Msg("Cannot encode value")
+			return
+		}
+		r.Value = data
+	}
+	j.wal.write(r)
+}
+
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// newTicker returns a ticker which never fires for non-positive intervals.
+func newTicker(interval time.Duration) *time.Ticker {
+	if interval <= 0 {
+		ticker := time.NewTicker(time.Hour)
+		ticker.Stop()
+		return ticker
+	}
+	return time.NewTicker(interval)
+}
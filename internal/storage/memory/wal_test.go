@@ -0,0 +1,195 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+)
+
+type durableStorages struct {
+	wal  *WAL
+	kv   *KVStorage[int]
+	list *ListStorage[string]
+}
+
+func openDurable(t *testing.T, cfg *config.WALConfig) durableStorages {
+	wal, err := OpenWAL(cfg)
+	require.NoError(t, err)
+	kv, err := NewDurableKVStorage[int](wal, "state")
+	require.NoError(t, err)
+	list, err := NewDurableListStorage[string](wal, "transactions", ListOptions{MaxEntriesPerKey: 10})
+	require.NoError(t, err)
+	return durableStorages{wal: wal, kv: kv, list: list}
+}
+
+func modify(s durableStorages) {
+	s.kv.Set("first", 1)
+	s.kv.Set("second", 2)
+	s.kv.Delete("second")
+	s.list.Append("flushed", "value", time.Minute)
+	s.list.FetchAndFlush("flushed")
+	s.list.Append("key", "outdated", -time.Second)
+	s.list.Append("key", "first", time.Minute)
+	s.list.CleanOutdated("key")
+	s.list.Append("key", "second", time.Minute)
+}
+
+func requireRestored(t *testing.T, s durableStorages) {
+	require.Equal(t, []string{"first"}, s.kv.Keys())
+	value, _ := s.kv.Get("first")
+	require.Equal(t, 1, value)
+	require.Equal(t, []string{"key"}, s.list.Keys())
+	before, after := s.list.CleanOutdated("key")
+	require.Equal(t, 2, before)
+	require.Equal(t, 2, after)
+	require.Equal(t, []string{"first", "second"}, s.list.FetchAndFlush("key"))
+}
+
+func TestShouldReplayLogAfterCrash(t *testing.T) {
+	cfg := &config.WALConfig{Dir: t.TempDir()}
+	s := openDurable(t, cfg)
+	modify(s)
+	// discarding skips compaction, so only the log is available like after a crash
+	require.NoError(t, s.wal.Discard())
+
+	s = openDurable(t, cfg)
+	defer s.wal.Discard()
+	requireRestored(t, s)
+}
+
+func TestShouldRestoreSnapshotAndFollowingRecords(t *testing.T) {
+	cfg := &config.WALConfig{Dir: t.TempDir()}
+	s := openDurable(t, cfg)
+	modify(s)
+	require.NoError(t, s.wal.Snapshot())
+	log, err := os.ReadFile(filepath.Join(cfg.Dir, walFile))
+	require.NoError(t, err)
+	require.Empty(t, log)
+	s.kv.Set("third", 3)
+	require.NoError(t, s.wal.Discard())
+
+	s = openDurable(t, cfg)
+	value, found := s.kv.Get("third")
+	require.True(t, found)
+	require.Equal(t, 3, value)
+	s.kv.Delete("third")
+	requireRestored(t, s)
+	require.NoError(t, s.wal.Close())
+
+	s = openDurable(t, cfg)
+	defer s.wal.Discard()
+	require.Equal(t, []string{"first"}, s.kv.Keys())
+	require.Equal(t, []string{}, s.list.Keys())
+}
+
+func TestShouldSkipRecordsIncludedInSnapshot(t *testing.T) {
+	cfg := &config.WALConfig{Dir: t.TempDir()}
+	s := openDurable(t, cfg)
+	modify(s)
+	log, err := os.ReadFile(filepath.Join(cfg.Dir, walFile))
+	require.NoError(t, err)
+	require.NoError(t, s.wal.Snapshot())
+	require.NoError(t, s.wal.Discard())
+	// crash between writing the snapshot and truncating the log
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, walFile), log, fileMode))
+
+	s = openDurable(t, cfg)
+	defer s.wal.Discard()
+	requireRestored(t, s)
+}
+
+func TestShouldIgnoreTornRecord(t *testing.T) {
+	cfg := &config.WALConfig{Dir: t.TempDir()}
+	s := openDurable(t, cfg)
+	modify(s)
+	require.NoError(t, s.wal.Discard())
+	file, err := os.OpenFile(filepath.Join(cfg.Dir, walFile), os.O_WRONLY|os.O_APPEND, fileMode)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"seq":100,"store":"state","op":"se`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	s = openDurable(t, cfg)
+	s.kv.Set("third", 3)
+	require.NoError(t, s.wal.Discard())
+
+	s = openDurable(t, cfg)
+	defer s.wal.Discard()
+	require.True(t, s.kv.Delete("third"))
+	requireRestored(t, s)
+}
+
+func TestShouldContinueNumberingAfterRecordsWrittenOutOfOrder(t *testing.T) {
+	cfg := &config.WALConfig{Dir: t.TempDir()}
+	// shards number records before writing them, so records of different keys can be written out of order
+	log := `{"seq":2,"store":"state","op":"set","key":"second","value":2}` + "\n" +
+		`{"seq":1,"store":"state","op":"set","key":"first","value":1}` + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, walFile), []byte(log), fileMode))
+
+	s := openDurable(t, cfg)
+	require.Equal(t, uint64(2), s.wal.seq.Load())
+	require.ElementsMatch(t, []string{"first", "second"}, s.kv.Keys())
+	s.kv.Set("third", 3)
+	require.NoError(t, s.wal.Discard())
+
+	s = openDurable(t, cfg)
+	defer s.wal.Discard()
+	require.Equal(t, uint64(3), s.wal.seq.Load())
+	require.ElementsMatch(t, []string{"first", "second", "third"}, s.kv.Keys())
+}
+
+func TestShouldReplayEvictions(t *testing.T) {
+	cfg := &config.WALConfig{Dir: t.TempDir()}
+	s := openDurable(t, cfg)
+	for _, value := range []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"} {
+		s.list.Append("key", value, time.Minute)
+	}
+	require.NoError(t, s.wal.Discard())
+
+	s = openDurable(t, cfg)
+	defer s.wal.Discard()
+	require.Equal(t, []string{"3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}, s.list.FetchAndFlush("key"))
+}
+
+func TestShouldRejectDuplicatedStorage(t *testing.T) {
+	s := openDurable(t, &config.WALConfig{Dir: t.TempDir()})
+	defer s.wal.Discard()
+	_, err := NewDurableKVStorage[int](s.wal, "state")
+	require.Error(t, err)
+}
+
+func TestShouldKeepRecordsWrittenWhileSnapshotIsEncoded(t *testing.T) {
+	cfg := &config.WALConfig{Dir: t.TempDir()}
+	s := openDurable(t, cfg)
+	defer s.wal.Discard()
+	modify(s)
+	_, _, offset, failures, err := s.wal.capture()
+	require.NoError(t, err)
+	s.kv.Set("third", 3)
+	require.NoError(t, s.wal.compact(offset, failures))
+
+	log, err := os.ReadFile(filepath.Join(cfg.Dir, walFile))
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(log), "\n"))
+	require.Contains(t, string(log), `"key":"third"`)
+}
+
+func TestShouldReportFailuresUntilSnapshot(t *testing.T) {
+	s := openDurable(t, &config.WALConfig{Dir: t.TempDir()})
+	defer s.wal.Discard()
+	require.NoError(t, s.wal.Err())
+	file := s.wal.file
+	require.NoError(t, file.Close())
+	s.kv.Set("third", 3)
+	require.ErrorIs(t, s.wal.Err(), os.ErrClosed)
+
+	s.wal.file, _ = os.OpenFile(file.Name(), os.O_WRONLY|os.O_APPEND, fileMode)
+	s.wal.writer.Reset(s.wal.file)
+	require.NoError(t, s.wal.Snapshot())
+	require.NoError(t, s.wal.Err())
+}
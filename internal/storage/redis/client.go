@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/ziollek/etherscription/pkg/config"
+)
+
+const defaultTimeout = time.Second
+
+// Open connects to the redis server and verifies that it responds.
+func Open(cfg *config.RedisConfig) (*goredis.Client, error) {
+	client := goredis.NewClient(&goredis.Options{
+		Addr:     cfg.Address,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	if err := Ping(client, cfg); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Ping verifies that the server responds within the configured timeout.
+func Ping(client *goredis.Client, cfg *config.RedisConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout(cfg))
+	defer cancel()
+	return client.Ping(ctx).Err()
+}
+
+// store is shared by all storages, every key is prefixed with the configured prefix and the storage name.
+type store struct {
+	client  *goredis.Client
+	name    string
+	timeout time.Duration
+}
+
+func newStore(client *goredis.Client, cfg *config.RedisConfig, name string) store {
+	if cfg.Prefix != "" {
+		name = cfg.Prefix + ":" + name
+	}
+	return store{client: client, name: name, timeout: timeout(cfg)}
+}
+
+func (s store) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+func timeout(cfg *config.RedisConfig) time.Duration {
+	if cfg.Timeout > 0 {
+		return cfg.Timeout
+	}
+	return defaultTimeout
+}
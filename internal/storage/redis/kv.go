@@ -0,0 +1,195 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+// KVStorage keeps JSON encoded values in a single redis hash, names of fields are also kept in a sorted set
+// with equal scores, so fields starting with a prefix are counted without reading the whole hash.
+type KVStorage[T any] struct {
+	store
+}
+
+func NewKVStorage[T any](client *goredis.Client, cfg *config.RedisConfig, name string) storage.KVSaver[T] {
+	kv := &KVStorage[T]{store: newStore(client, cfg, name)}
+	kv.reindex()
+	return kv
+}
+
+// reindexScript adds fields written before the index was introduced, it reads the whole hash only when
+// the index and the hash differ in size.
+var reindexScript = goredis.NewScript(`
+if redis.call('ZCARD', KEYS[2]) == redis.call('HLEN', KEYS[1]) then
+	return 0
+end
+local fields = redis.call('HKEYS', KEYS[1])
+for _, field in ipairs(fields) do
+	redis.call('ZADD', KEYS[2], 0, field)
+end
+return #fields
+`)
+
+func (storage *KVStorage[_]) reindex() {
+	ctx, cancel := storage.context()
+	defer cancel()
+	indexed, err := reindexScript.Run(ctx, storage.client, []string{storage.name, storage.indexKey()}).Int()
+	if err != nil {
+		logging.Module("redis").Err(err).Msg("Cannot index keys")
+		return
+	}
+	if indexed > 0 {
+		logging.Module("redis").Info().Str("name", storage.name).Msgf("Indexed %d keys", indexed)
+	}
+}
+
+func (storage *KVStorage[T]) Get(key string) (T, bool) {
+	var value T
+	ctx, cancel := storage.context()
+	defer cancel()
+	data, err := storage.client.HGet(ctx, storage.name, key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return value, false
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &value)
+	}
+	if err != nil {
+		logging.Module("redis").Err(err).Str("key", key).Msg("Cannot read value")
+		return value, false
+	}
+	return value, true
+}
+
+func (storage *KVStorage[T]) Set(key string, value T) {
+	data, err := json.Marshal(value)
+	if err == nil {
+		ctx, cancel := storage.context()
+		defer cancel()
+		_, err = storage.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.HSet(ctx, storage.name, key, data)
+			pipe.ZAdd(ctx, storage.indexKey(), goredis.Z{Member: key})
+			return nil
+		})
+	}
+	if err != nil {
+		logging.Module("redis").Err(err).Str("key", key).Msg("Cannot write value")
+	}
+}
+
+func (storage *KVStorage[T]) Delete(key string) bool {
+	ctx, cancel := storage.context()
+	defer cancel()
+	var deleted *goredis.IntCmd
+	_, err := storage.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		deleted = pipe.HDel(ctx, storage.name, key)
+		pipe.ZRem(ctx, storage.indexKey(), key)
+		return nil
+	})
+	if err != nil {
+		logging.Module("redis").Err(err).Str("key", key).Msg("Cannot delete value")
+		return false
+	}
+	return deleted.Val() > 0
+}
+
+func (storage *KVStorage[_]) Keys() []string {
+	ctx, cancel := storage.context()
+	defer cancel()
+	keys, err := storage.client.HKeys(ctx, storage.name).Result()
+	if err != nil {
+		logging.Module("redis").Err(err).Msg("Cannot list keys")
+		return []string{}
+	}
+	return keys
+}
+
+func (storage *KVStorage[_]) Count() int {
+	ctx, cancel := storage.context()
+	defer cancel()
+	count, err := storage.client.HLen(ctx, storage.name).Result()
+	if err != nil {
+		logging.Module("redis").Err(err).Msg("Cannot count keys")
+	}
+	return int(count)
+}
+
+func (kv *KVStorage[_]) Scan(cursor string, limit int) ([]string, string) {
+	return storage.Page(kv.Keys(), cursor, limit)
+}
+
+// setWithinLimitScript counts indexed fields between the bounds of the prefix once and sets new fields while
+// the count is below the limit, the script runs atomically, so replicas cannot exceed the limit together.
+var setWithinLimitScript = goredis.NewScript(`
+local limit = tonumber(ARGV[3])
+local count = 0
+if limit > 0 then
+	count = redis.call('ZLEXCOUNT', KEYS[2], ARGV[1], ARGV[2])
+end
+local results = {}
+for i = 4, #ARGV, 2 do
+	local set = 1
+	if redis.call('HEXISTS', KEYS[1], ARGV[i]) == 0 then
+		if limit > 0 and count >= limit then
+			set = 0
+		else
+			count = count + 1
+		end
+	end
+	if set == 1 then
+		redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+		redis.call('ZADD', KEYS[2], 0, ARGV[i])
+	end
+	results[#results + 1] = set
+end
+return results
+`)
+
+func (storage *KVStorage[T]) SetWithinLimit(prefix string, limit int, keys []string, values []T) []bool {
+	results := make([]bool, len(keys))
+	args := make([]any, 0, 3+2*len(keys))
+	from, to := prefixRange(prefix)
+	args = append(args, from, to, limit)
+	for i, key := range keys {
+		data, err := json.Marshal(values[i])
+		if err != nil {
+			logging.Module("redis").Err(err).Str("key", key).Msg("Cannot write value")
+			return results
+		}
+		args = append(args, key, data)
+	}
+	ctx, cancel := storage.context()
+	defer cancel()
+	set, err := setWithinLimitScript.Run(ctx, storage.client, []string{storage.name, storage.indexKey()}, args...).Int64Slice()
+	if err != nil {
+		logging.Module("redis").Err(err).Str("prefix", prefix).Msg("Cannot write values")
+		return results
+	}
+	for i := range min(len(set), len(results)) {
+		results[i] = set[i] == 1
+	}
+	return results
+}
+
+func (storage *KVStorage[_]) indexKey() string {
+	return storage.name + ":index"
+}
+
+// prefixRange returns bounds of ZLEXCOUNT matching members which start with the prefix, the upper bound
+// is the prefix with its last byte incremented, trailing 0xff bytes cannot be incremented and are dropped.
+func prefixRange(prefix string) (string, string) {
+	upper := []byte(prefix)
+	for len(upper) > 0 && upper[len(upper)-1] == 0xff {
+		upper = upper[:len(upper)-1]
+	}
+	if len(upper) == 0 {
+		return "[" + prefix, "+"
+	}
+	upper[len(upper)-1]++
+	return "[" + prefix, "(" + string(upper)
+}
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+func newTestConfig(t *testing.T) *config.RedisConfig {
+	server := miniredis.RunT(t)
+	return &config.RedisConfig{Address: server.Addr(), Prefix: "test"}
+}
+
+func TestShouldShareValuesBetweenReplicas(t *testing.T) {
+	cfg := newTestConfig(t)
+	first, err := Open(cfg)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := Open(cfg)
+	require.NoError(t, err)
+	defer second.Close()
+
+	writer := NewKVStorage[int](first, cfg, "state")
+	reader := NewKVStorage[int](second, cfg, "state")
+	writer.Set("first", 1)
+	writer.Set("second", 2)
+	require.True(t, reader.Delete("second"))
+	require.False(t, writer.Delete("second"))
+
+	value, found := reader.Get("first")
+	require.True(t, found)
+	require.Equal(t, 1, value)
+	_, found = reader.Get("second")
+	require.False(t, found)
+	require.Equal(t, []string{"first"}, reader.Keys())
+	require.Equal(t, 1, reader.(storage.Counter).Count())
+}
+
+func TestShouldFailToOpenWhenServerIsUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := &config.RedisConfig{Address: server.Addr()}
+	server.Close()
+	_, err := Open(cfg)
+	require.Error(t, err)
+}
+
+func TestShouldNotExceedLimitOfKeysAcrossReplicas(t *testing.T) {
+	cfg := newTestConfig(t)
+	first, err := Open(cfg)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := Open(cfg)
+	require.NoError(t, err)
+	defer second.Close()
+
+	replicas := []*KVStorage[int]{
+		NewKVStorage[int](first, cfg, "subscriptions").(*KVStorage[int]),
+		NewKVStorage[int](second, cfg, "subscriptions").(*KVStorage[int]),
+	}
+	replicas[0].Set("other/0x1", 1)
+	require.Equal(t, []bool{true, true}, replicas[0].SetWithinLimit("tenant/", 3, []string{"tenant/0x1", "tenant/0x2"}, []int{1, 2}))
+	// existing keys are overwritten even if the limit is reached
+	require.Equal(t,
+		[]bool{true, true, false},
+		replicas[1].SetWithinLimit("tenant/", 3, []string{"tenant/0x3", "tenant/0x1", "tenant/0x4"}, []int{3, 10, 4}),
+	)
+	require.Equal(t, []bool{true}, replicas[1].SetWithinLimit("tenant/", 0, []string{"tenant/0x4"}, []int{4}))
+
+	value, _ := replicas[0].Get("tenant/0x1")
+	require.Equal(t, 10, value)
+	require.ElementsMatch(t, []string{"other/0x1", "tenant/0x1", "tenant/0x2", "tenant/0x3", "tenant/0x4"}, replicas[0].Keys())
+}
+
+func TestShouldCountKeysWrittenBeforeIndexing(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := &config.RedisConfig{Address: server.Addr(), Prefix: "test"}
+	client, err := Open(cfg)
+	require.NoError(t, err)
+	defer client.Close()
+	server.HSet("test:subscriptions", "tenant/0x1", "1")
+	server.HSet("test:subscriptions", "tenant/0x2", "2")
+	server.HSet("test:subscriptions", "tenantless/0x1", "1")
+
+	kv := NewKVStorage[int](client, cfg, "subscriptions").(*KVStorage[int])
+	require.Equal(t, []bool{true, false}, kv.SetWithinLimit("tenant/", 3, []string{"tenant/0x3", "tenant/0x4"}, []int{3, 4}))
+	require.True(t, kv.Delete("tenant/0x1"))
+	require.Equal(t, []bool{true}, kv.SetWithinLimit("tenant/", 3, []string{"tenant/0x4"}, []int{4}))
+	members, err := server.ZMembers("test:subscriptions:index")
+	require.NoError(t, err)
+	require.Equal(t, []string{"tenant/0x2", "tenant/0x3", "tenant/0x4", "tenantless/0x1"}, members)
+}
+
+func TestShouldMatchMembersStartingWithPrefix(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		wantFrom string
+		wantTo   string
+	}{
+		{"tenant/", "[tenant/", "(tenant0"},
+		{"a\xff\xff", "[a\xff\xff", "(b"},
+		{"", "[", "+"},
+	}
+	for _, tt := range tests {
+		from, to := prefixRange(tt.prefix)
+		require.Equal(t, tt.wantFrom, from)
+		require.Equal(t, tt.wantTo, to)
+	}
+}
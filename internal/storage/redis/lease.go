@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/ziollek/etherscription/pkg/config"
+)
+
+// acquireScript extends the lease held by the caller or takes it over when nobody holds it.
+var acquireScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// releaseScript removes the lease only when it is still held by the caller.
+var releaseScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Lease is a lock with a TTL shared by replicas, the holder is identified by a random token generated per instance,
+// so a lease which expired and was taken over by another replica is not extended nor released by the previous holder.
+type Lease struct {
+	store
+	token string
+	ttl   time.Duration
+}
+
+func NewLease(client *goredis.Client, cfg *config.RedisConfig, name string) *Lease {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return &Lease{store: newStore(client, cfg, name), token: hex.EncodeToString(token), ttl: cfg.LeaseTTL}
+}
+
+func (lease *Lease) Acquire(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, lease.timeout)
+	defer cancel()
+	acquired, err := acquireScript.Run(ctx, lease.client, []string{lease.name}, lease.token, lease.ttl.Milliseconds()).Int()
+	return acquired == 1, err
+}
+
+func (lease *Lease) Release(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, lease.timeout)
+	defer cancel()
+	return releaseScript.Run(ctx, lease.client, []string{lease.name}, lease.token).Err()
+}
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+)
+
+func TestShouldGrantLeaseToSingleReplica(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := &config.RedisConfig{Address: server.Addr(), Prefix: "test", LeaseTTL: time.Second}
+	client, err := Open(cfg)
+	require.NoError(t, err)
+	defer client.Close()
+	first, second := NewLease(client, cfg, "ingestion"), NewLease(client, cfg, "ingestion")
+	ctx := context.Background()
+
+	mustAcquire := func(lease *Lease, want bool) {
+		t.Helper()
+		acquired, err := lease.Acquire(ctx)
+		require.NoError(t, err)
+		require.Equal(t, want, acquired)
+	}
+	mustAcquire(first, true)
+	mustAcquire(second, false)
+	// the holder extends the lease
+	server.FastForward(time.Millisecond * 600)
+	mustAcquire(first, true)
+	server.FastForward(time.Millisecond * 600)
+	mustAcquire(second, false)
+
+	// a replica which stopped renewing loses the lease
+	server.FastForward(time.Second)
+	mustAcquire(second, true)
+	require.NoError(t, first.Release(ctx))
+	mustAcquire(first, false)
+
+	require.NoError(t, second.Release(ctx))
+	mustAcquire(first, true)
+}
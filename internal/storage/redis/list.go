@@ -0,0 +1,281 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+const cleanRetries = 3
+
+// appendScript numbers an entry, pushes it and extends expiration of the whole list, so redis drops lists nobody reads.
+// The sequence prefixes the encoded entry, this way the number is assigned atomically with the push.
+// Nothing is pushed and 0 is returned when the list already has as many entries as the positive limit.
+// The list, the index and the sequence are separate keys without a common hash tag, the storage needs a standalone server.
+var appendScript = goredis.NewScript(`
+local limit = tonumber(ARGV[4])
+if limit > 0 and redis.call('LLEN', KEYS[1]) >= limit then
+	return 0
+end
+local sequence = redis.call('INCR', KEYS[3])
+redis.call('RPUSH', KEYS[1], sequence .. ':' .. ARGV[1])
+redis.call('SADD', KEYS[2], ARGV[2])
+local ttl = tonumber(ARGV[3])
+if ttl > 0 and redis.call('PTTL', KEYS[1]) < ttl then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return sequence
+`)
+
+type entry[T any] struct {
+	Value      T         `json:"value"`
+	Expiration time.Time `json:"expiration"`
+	AddedAt    time.Time `json:"added_at"`
+	Sequence   uint64    `json:"-"`
+}
+
+// ListStorage keeps every list as a redis list of JSON encoded entries,
+// names of lists are tracked in a separate set to avoid scanning the whole keyspace.
+type ListStorage[T any] struct {
+	store
+}
+
+func NewListStorage[T any](client *goredis.Client, cfg *config.RedisConfig, name string) *ListStorage[T] {
+	return &ListStorage[T]{store: newStore(client, cfg, name)}
+}
+
+func (storage *ListStorage[T]) FetchAndFlush(key string) []T {
+	ctx, cancel := storage.context()
+	defer cancel()
+	var items *goredis.StringSliceCmd
+	_, err := storage.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		items = pipe.LRange(ctx, storage.listKey(key), 0, -1)
+		pipe.Del(ctx, storage.listKey(key))
+		pipe.SRem(ctx, storage.indexKey(), key)
+		return nil
+	})
+	if err != nil {
+		logging.Module("redis").Err(err).Str("key", key).Msg("Cannot fetch entries")
+		return []T{}
+	}
+	active, _ := storage.split(key, items.Val(), time.Now())
+	values := make([]T, 0, len(active))
+	for _, e := range active {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+func (storage *ListStorage[T]) Append(key string, value T, ttl time.Duration) {
+	storage.AppendBounded(key, value, ttl, 0)
+}
+
+// AppendBounded compares the limit with LLEN inside the append script, so the check costs the same for any length.
+func (storage *ListStorage[T]) AppendBounded(key string, value T, ttl time.Duration, limit int) bool {
+	now := time.Now()
+	data, err := json.Marshal(entry[T]{Value: value, Expiration: now.Add(ttl), AddedAt: now})
+	sequence := int64(0)
+	if err == nil {
+		ctx, cancel := storage.context()
+		defer cancel()
+		sequence, err = appendScript.Run(
+			ctx, storage.client, []string{storage.listKey(key), storage.indexKey(), storage.sequenceKey()},
+			data, key, ttl.Milliseconds(), limit,
+		).Int64()
+	}
+	if err != nil {
+		logging.Module("redis").Err(err).Str("key", key).Msg("Cannot append entry")
+		return true
+	}
+	return sequence > 0
+}
+
+func (storage *ListStorage[T]) Len(key string) int {
+	return len(storage.active(key))
+}
+
+func (storage *ListStorage[T]) Peek(key string) []T {
+	active := storage.active(key)
+	values := make([]T, 0, len(active))
+	for _, e := range active {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+func (storage *ListStorage[T]) Range(key string, query storage.RangeQuery) []storage.Item[T] {
+	return rangeItems(storage.active(key), query)
+}
+
+func (storage *ListStorage[T]) Delete(key string) bool {
+	ctx, cancel := storage.context()
+	defer cancel()
+	var deleted *goredis.IntCmd
+	_, err := storage.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		deleted = pipe.Del(ctx, storage.listKey(key))
+		pipe.SRem(ctx, storage.indexKey(), key)
+		return nil
+	})
+	if err != nil {
+		logging.Module("redis").Err(err).Str("key", key).Msg("Cannot delete entries")
+		return false
+	}
+	return deleted.Val() > 0
+}
+
+func (storage *ListStorage[_]) Keys() []string {
+	ctx, cancel := storage.context()
+	defer cancel()
+	keys, err := storage.client.SMembers(ctx, storage.indexKey()).Result()
+	if err != nil {
+		logging.Module("redis").Err(err).Msg("Cannot list keys")
+		return []string{}
+	}
+	return keys
+}
+
+func (storage *ListStorage[_]) Count() int {
+	ctx, cancel := storage.context()
+	defer cancel()
+	count, err := storage.client.SCard(ctx, storage.indexKey()).Result()
+	if err != nil {
+		logging.Module("redis").Err(err).Msg("Cannot count keys")
+	}
+	return int(count)
+}
+
+// Scan pages over the index set, redis sets are not ordered so the whole set is read and sorted.
+func (list *ListStorage[_]) Scan(cursor string, limit int) ([]string, string) {
+	return storage.Page(list.Keys(), cursor, limit)
+}
+
+// CleanOutdated rewrites the list without outdated entries, the list is watched,
+// so the rewrite is retried when another replica modifies it in the meantime.
+func (storage *ListStorage[T]) CleanOutdated(key string) (int, int) {
+	ctx, cancel := storage.context()
+	defer cancel()
+	var err error
+	for range cleanRetries {
+		var before, after int
+		err = storage.client.Watch(ctx, func(tx *goredis.Tx) error {
+			var err error
+			before, after, err = storage.clean(ctx, tx, key)
+			return err
+		}, storage.listKey(key))
+		if err == nil {
+			return before, after
+		}
+		if !errors.Is(err, goredis.TxFailedErr) {
+			break
+		}
+	}
+	logging.Module("redis").Err(err).Str("key", key).Msg("Cannot clean entries")
+	return 0, 0
+}
+
+func (storage *ListStorage[T]) clean(ctx context.Context, tx *goredis.Tx, key string) (int, int, error) {
+	items, err := tx.LRange(ctx, storage.listKey(key), 0, -1).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	ttl, err := tx.PTTL(ctx, storage.listKey(key)).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	_, active := storage.split(key, items, time.Now())
+	if len(active) == len(items) && len(items) > 0 {
+		return len(items), len(items), nil
+	}
+	_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Del(ctx, storage.listKey(key))
+		if len(active) == 0 {
+			// we should not store empty lists
+			pipe.SRem(ctx, storage.indexKey(), key)
+			return nil
+		}
+		pipe.RPush(ctx, storage.listKey(key), active...)
+		if ttl > 0 {
+			pipe.PExpire(ctx, storage.listKey(key), ttl)
+		}
+		return nil
+	})
+	return len(items), len(active), err
+}
+
+// active reads entries which are not expired, errors are logged and treated as an empty list.
+func (storage *ListStorage[T]) active(key string) []entry[T] {
+	ctx, cancel := storage.context()
+	defer cancel()
+	items, err := storage.client.LRange(ctx, storage.listKey(key), 0, -1).Result()
+	if err != nil {
+		logging.Module("redis").Err(err).Str("key", key).Msg("Cannot read entries")
+		return nil
+	}
+	active, _ := storage.split(key, items, time.Now())
+	return active
+}
+
+// split decodes entries which are not expired, raw items are returned as well to allow rewriting the list.
+func (storage *ListStorage[T]) split(key string, items []string, now time.Time) ([]entry[T], []any) {
+	entries := make([]entry[T], 0, len(items))
+	raw := make([]any, 0, len(items))
+	for _, item := range items {
+		e, err := decode[T](item)
+		if err != nil {
+			logging.Module("redis").Err(err).Str("key", key).Msg("Skipping malformed entry")
+			continue
+		}
+		if e.Expiration.After(now) {
+			entries = append(entries, e)
+			raw = append(raw, item)
+		}
+	}
+	return entries, raw
+}
+
+func (storage *ListStorage[_]) listKey(key string) string {
+	return storage.name + ":list:" + key
+}
+
+func (storage *ListStorage[_]) indexKey() string {
+	return storage.name + ":keys"
+}
+
+func (storage *ListStorage[_]) sequenceKey() string {
+	return storage.name + ":sequence"
+}
+
+// decode parses an item pushed by appendScript, items without the sequence prefix are decoded with sequence 0.
+func decode[T any](item string) (entry[T], error) {
+	var e entry[T]
+	if sequence, data, found := strings.Cut(item, ":"); found && !strings.HasPrefix(item, "{") {
+		parsed, err := strconv.ParseUint(sequence, 10, 64)
+		if err != nil {
+			return e, err
+		}
+		e.Sequence, item = parsed, data
+	}
+	err := json.Unmarshal([]byte(item), &e)
+	return e, err
+}
+
+func rangeItems[T any](entries []entry[T], query storage.RangeQuery) []storage.Item[T] {
+	items := make([]storage.Item[T], 0, len(entries))
+	for _, e := range entries {
+		if query.Full(len(items)) {
+			break
+		}
+		if query.Matches(e.Sequence, e.AddedAt) {
+			items = append(items, storage.Item[T]{Sequence: e.Sequence, AddedAt: e.AddedAt, Value: e.Value})
+		}
+	}
+	return items
+}
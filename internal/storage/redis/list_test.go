@@ -0,0 +1,42 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/storage/storagetest"
+)
+
+func newTestListStorage(t *testing.T) (*ListStorage[string], *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	cfg := &config.RedisConfig{Address: server.Addr(), Prefix: "test"}
+	client, err := Open(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return NewListStorage[string](client, cfg, "list"), server
+}
+
+func TestShouldExpireWholeListInRedis(t *testing.T) {
+	s, server := newTestListStorage(t)
+	s.Append("key", "first", time.Second)
+	s.Append("key", "second", time.Minute)
+	require.Equal(t, time.Minute, server.TTL("test:list:list:key"))
+
+	server.FastForward(time.Minute)
+	require.Equal(t, 0, s.Len("key"))
+	require.Equal(t, []string{"key"}, s.Keys())
+	before, after := s.CleanOutdated("key")
+	require.Equal(t, 0, before)
+	require.Equal(t, 0, after)
+	require.Equal(t, []string{}, s.Keys())
+}
+
+func TestShouldConformToListSaver(t *testing.T) {
+	storagetest.TestListSaver(t, func(t *testing.T) storagetest.ListStorage {
+		s, _ := newTestListStorage(t)
+		return s
+	})
+}
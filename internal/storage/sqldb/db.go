@@ -0,0 +1,82 @@
+package sqldb
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// registers postgres driver
+	_ "github.com/lib/pq"
+	// registers sqlite driver
+	_ "modernc.org/sqlite"
+)
+
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+type dialect struct {
+	driver string
+	// serial is a definition of auto incremented primary key
+	serial string
+	// numbered placeholders ($1, $2...) are used instead of question marks
+	numbered bool
+}
+
+var dialects = map[string]dialect{
+	SQLite:   {driver: "sqlite", serial: "INTEGER PRIMARY KEY AUTOINCREMENT"},
+	Postgres: {driver: "postgres", serial: "BIGSERIAL PRIMARY KEY", numbered: true},
+}
+
+// DB is a database connection which rewrites queries to the dialect of the database.
+type DB struct {
+	*sql.DB
+	dialect dialect
+}
+
+// Open connects to the database and applies missing migrations.
+func Open(name, dsn string) (*DB, error) {
+	d, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sql dialect: %s", name)
+	}
+	conn, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if name == SQLite {
+		// sqlite allows a single writer, sharing one connection avoids "database is locked" errors
+		conn.SetMaxOpenConns(1)
+	}
+	db := &DB{DB: conn, dialect: d}
+	if err := db.migrate(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("cannot migrate database: %w", err)
+	}
+	return db, nil
+}
+
+// rebind replaces question marks with numbered placeholders when the dialect requires them.
+func (db *DB) rebind(query string) string {
+	if !db.dialect.numbered {
+		return query
+	}
+	var builder strings.Builder
+	position := 0
+	for _, char := range query {
+		if char == '?' {
+			position++
+			builder.WriteString("$" + strconv.Itoa(position))
+			continue
+		}
+		builder.WriteRune(char)
+	}
+	return builder.String()
+}
+
+func timestamp(t time.Time) int64 {
+	return t.UnixMilli()
+}
@@ -0,0 +1,42 @@
+package sqldb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T) *DB {
+	db, err := Open(SQLite, filepath.Join(t.TempDir(), "test.sqlite"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestShouldApplyMigrationsOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sqlite")
+	for range 2 {
+		db, err := Open(SQLite, path)
+		require.NoError(t, err)
+		version := 0
+		require.NoError(t, db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+		require.Equal(t, len(migrations), version)
+		require.NoError(t, db.Close())
+	}
+}
+
+func TestShouldRejectUnknownDialect(t *testing.T) {
+	_, err := Open("oracle", "")
+	require.Error(t, err)
+}
+
+func TestShouldRebindPlaceholders(t *testing.T) {
+	query := `SELECT value FROM kv WHERE store = ? AND key = ?`
+	require.Equal(t, query, (&DB{dialect: dialects[SQLite]}).rebind(query))
+	require.Equal(
+		t,
+		`SELECT value FROM kv WHERE store = $1 AND key = $2`,
+		(&DB{dialect: dialects[Postgres]}).rebind(query),
+	)
+}
@@ -0,0 +1,157 @@
+package sqldb
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+const defaultHistoryLimit = 100
+
+// History records every consumed transaction in transactions table, addresses are stored in lower case.
+// Values above math.MaxInt64 wei are stored in exact_value column as decimal text.
+type History struct {
+	db *DB
+}
+
+func NewHistory(db *DB) *History {
+	return &History{db: db}
+}
+
+func (history *History) Consume(transaction model.TracedTransaction) {
+	success := sql.NullBool{}
+	if transaction.Success != nil {
+		success = sql.NullBool{Bool: *transaction.Success, Valid: true}
+	}
+	exactValue := sql.NullString{}
+	if transaction.ExactValue != nil {
+		exactValue = sql.NullString{String: transaction.ExactValue.String(), Valid: true}
+	}
+	_, err := history.db.Exec(
+		history.db.rebind(`INSERT INTO transactions
+			(hash, block_number, from_address, to_address, value, exact_value, method_selector, success, seen_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		strings.ToLower(transaction.Hash),
+		transaction.BlockNumber,
+		strings.ToLower(transaction.From),
+		strings.ToLower(transaction.To),
+		transaction.Value,
+		exactValue,
+		transaction.MethodSelector,
+		success,
+		timestamp(time.Now()),
+	)
+	if err != nil {
+		logging.Module("sql").Err(err).Str("hash", transaction.Hash).Msg("Cannot record transaction")
+	}
+}
+
+func (history *History) Query(query storage.HistoryQuery) ([]model.Transaction, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	if query.Address != "" {
+		conditions = append(conditions, "(from_address = ? OR to_address = ?)")
+		args = append(args, strings.ToLower(query.Address), strings.ToLower(query.Address))
+	}
+	if query.Hash != "" {
+		conditions = append(conditions, "hash = ?")
+		args = append(args, strings.ToLower(query.Hash))
+	}
+	if query.FromBlock > 0 {
+		conditions = append(conditions, "block_number >= ?")
+		args = append(args, query.FromBlock)
+	}
+	if query.ToBlock > 0 {
+		conditions = append(conditions, "block_number <= ?")
+		args = append(args, query.ToBlock)
+	}
+	statement := `SELECT hash, block_number, from_address, to_address, value, exact_value, method_selector, success FROM transactions`
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	statement += " ORDER BY block_number, id LIMIT ? OFFSET ?"
+	args = append(args, limit, query.Offset)
+
+	rows, err := history.db.Query(history.db.rebind(statement), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	transactions := make([]model.Transaction, 0)
+	for rows.Next() {
+		var transaction model.Transaction
+		var success sql.NullBool
+		var exactValue sql.NullString
+		err := rows.Scan(
+			&transaction.Hash,
+			&transaction.BlockNumber,
+			&transaction.From,
+			&transaction.To,
+			&transaction.Value,
+			&exactValue,
+			&transaction.MethodSelector,
+			&success,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if success.Valid {
+			transaction.Success = &success.Bool
+		}
+		if exactValue.Valid {
+			value, ok := new(big.Int).SetString(exactValue.String, 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid exact value %q of transaction %s", exactValue.String, transaction.Hash)
+			}
+			transaction.ExactValue = value
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// Pruner replaces per key cleaning, outdated list entries and transactions older than the retention
+// are removed with a single statement each.
+type Pruner struct {
+	db        *DB
+	retention time.Duration
+}
+
+func NewPruner(db *DB, retention time.Duration) *Pruner {
+	return &Pruner{db: db, retention: retention}
+}
+
+func (pruner *Pruner) Expire(now time.Time) int {
+	removed, err := affectedRows(pruner.db, `DELETE FROM list_entries WHERE expires_at <= ?`, timestamp(now))
+	if err != nil {
+		logging.Module("sql").Err(err).Msg("Cannot prune list entries")
+	}
+	if pruner.retention <= 0 {
+		return removed
+	}
+	pruned, err := affectedRows(pruner.db, `DELETE FROM transactions WHERE seen_at < ?`, timestamp(now.Add(-pruner.retention)))
+	if err != nil {
+		logging.Module("sql").Err(err).Msg("Cannot prune transaction history")
+	}
+	return removed + pruned
+}
+
+// affectedRows runs the statement and returns the number of rows it changed, remaining rows are not counted.
+func affectedRows(db *DB, statement string, args ...any) (int, error) {
+	result, err := db.Exec(db.rebind(statement), args...)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
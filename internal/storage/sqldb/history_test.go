@@ -0,0 +1,84 @@
+package sqldb
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+const (
+	alice = "0x00000000000000000000000000000000000000a1"
+	bob   = "0x00000000000000000000000000000000000000b2"
+	carol = "0x00000000000000000000000000000000000000c3"
+)
+
+func TestShouldQueryTransactionHistory(t *testing.T) {
+	history := NewHistory(newTestDB(t))
+	success := true
+	transactions := []model.Transaction{
+		{Hash: "0x01", BlockNumber: 10, From: alice, To: bob, Value: 100, Success: &success},
+		{Hash: "0x02", BlockNumber: 11, From: bob, To: carol, Value: 200, MethodSelector: "0xa9059cbb"},
+		{Hash: "0x03", BlockNumber: 12, From: carol, To: alice, Value: 300},
+	}
+	for _, transaction := range transactions {
+		history.Consume(model.TracedTransaction{Transaction: transaction})
+	}
+
+	type testCase struct {
+		name  string
+		query storage.HistoryQuery
+		want  []model.Transaction
+	}
+	tests := []testCase{
+		{name: "should return all transactions", want: transactions},
+		{
+			name:  "should match sender and receiver ignoring case",
+			query: storage.HistoryQuery{Address: "0x00000000000000000000000000000000000000A1"},
+			want:  []model.Transaction{transactions[0], transactions[2]},
+		},
+		{name: "should match hash", query: storage.HistoryQuery{Hash: "0x02"}, want: transactions[1:2]},
+		{name: "should match block range", query: storage.HistoryQuery{FromBlock: 11, ToBlock: 11}, want: transactions[1:2]},
+		{name: "should paginate", query: storage.HistoryQuery{Offset: 1, Limit: 1}, want: transactions[1:2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := history.Query(tt.query)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestShouldKeepExactValueAboveInt64(t *testing.T) {
+	history := NewHistory(newTestDB(t))
+	value, _ := new(big.Int).SetString("12345678901234567890123", 10)
+	transaction := model.Transaction{Hash: "0x01", BlockNumber: 10, From: alice, To: bob, Value: value.Int64(), ExactValue: value}
+	history.Consume(model.TracedTransaction{Transaction: transaction})
+
+	got, err := history.Query(storage.HistoryQuery{Hash: "0x01"})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, transaction, got[0])
+	require.Equal(t, value, got[0].Amount())
+}
+
+func TestShouldPruneOutdatedEntriesAndHistory(t *testing.T) {
+	db := newTestDB(t)
+	history := NewHistory(db)
+	transactions := NewListStorage[string](db, "transactions")
+	deadLetters := NewListStorage[string](db, "dead_letters")
+	transactions.Append("first", "active", time.Minute)
+	transactions.Append("first", "outdated", -time.Second)
+	deadLetters.Append("second", "outdated", -time.Second)
+	history.Consume(model.TracedTransaction{Transaction: model.Transaction{Hash: "0x01", From: alice, To: bob}})
+
+	require.Equal(t, 2, NewPruner(db, time.Hour).Expire(time.Now()))
+	require.Equal(t, []string{"first"}, transactions.Keys())
+	require.Equal(t, []string{}, deadLetters.Keys())
+
+	require.Equal(t, 2, NewPruner(db, time.Hour).Expire(time.Now().Add(2*time.Hour)))
+}
@@ -0,0 +1,125 @@
+package sqldb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+// KVStorage keeps JSON encoded values in kv table, rows of different storages are distinguished by store column.
+type KVStorage[T any] struct {
+	db    *DB
+	store string
+}
+
+func NewKVStorage[T any](db *DB, store string) storage.KVSaver[T] {
+	return &KVStorage[T]{db: db, store: store}
+}
+
+func (storage *KVStorage[T]) Get(key string) (T, bool) {
+	var value T
+	var data string
+	err := storage.db.QueryRow(
+		storage.db.rebind(`SELECT value FROM kv WHERE store = ? AND key = ?`), storage.store, key,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return value, false
+	}
+	if err == nil {
+		err = json.Unmarshal([]byte(data), &value)
+	}
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot read value")
+		return value, false
+	}
+	return value, true
+}
+
+func (storage *KVStorage[T]) Set(key string, value T) {
+	data, err := json.Marshal(value)
+	if err == nil {
+		_, err = storage.db.Exec(
+			storage.db.rebind(
+				`INSERT INTO kv (store, key, value) VALUES (?, ?, ?) ON CONFLICT (store, key) DO UPDATE SET value = excluded.value`,
+			),
+			storage.store, key, string(data),
+		)
+	}
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot write value")
+	}
+}
+
+func (storage *KVStorage[T]) Delete(key string) bool {
+	result, err := storage.db.Exec(storage.db.rebind(`DELETE FROM kv WHERE store = ? AND key = ?`), storage.store, key)
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot delete value")
+		return false
+	}
+	deleted, err := result.RowsAffected()
+	return err == nil && deleted > 0
+}
+
+func (storage *KVStorage[_]) Keys() []string {
+	keys, err := queryStrings(storage.db, `SELECT key FROM kv WHERE store = ? ORDER BY key`, storage.store)
+	if err != nil {
+		logging.Module("sql").Err(err).Msg("Cannot list keys")
+		return []string{}
+	}
+	return keys
+}
+
+func (storage *KVStorage[_]) Count() int {
+	return queryCount(storage.db, `SELECT COUNT(*) FROM kv WHERE store = ?`, storage.store)
+}
+
+func (storage *KVStorage[_]) Scan(cursor string, limit int) ([]string, string) {
+	return scanKeys(storage.db, `SELECT key FROM kv WHERE store = ? AND key > ? ORDER BY key`, storage.store, cursor, limit)
+}
+
+// scanKeys runs a query selecting ordered keys of a store following the cursor, one additional row is requested
+// to know whether the next page exists. Errors are logged and reported as an empty page.
+func scanKeys(db *DB, query, store, cursor string, limit int) ([]string, string) {
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit+1)
+	}
+	keys, err := queryStrings(db, query, store, cursor)
+	if err != nil {
+		logging.Module("sql").Err(err).Msg("Cannot scan keys")
+		return []string{}, ""
+	}
+	if limit <= 0 || len(keys) <= limit {
+		return keys, ""
+	}
+	return keys[:limit], keys[limit-1]
+}
+
+// queryCount runs a query counting rows, errors are logged and reported as zero.
+func queryCount(db *DB, query string, args ...any) int {
+	count := 0
+	if err := db.QueryRow(db.rebind(query), args...).Scan(&count); err != nil {
+		logging.Module("sql").Err(err).Msg("Cannot count keys")
+	}
+	return count
+}
+
+func queryStrings(db *DB, query string, args ...any) ([]string, error) {
+	rows, err := db.Query(db.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make([]string, 0)
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,41 @@
+package sqldb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+func TestShouldStoreValuesSeparatelyPerStore(t *testing.T) {
+	db := newTestDB(t)
+	kv := NewKVStorage[int](db, "state")
+	other := NewKVStorage[int](db, "other")
+	kv.Set("first", 1)
+	kv.Set("first", 2)
+	kv.Set("second", 3)
+	other.Set("third", 4)
+	require.True(t, kv.Delete("second"))
+	require.False(t, kv.Delete("second"))
+
+	value, found := kv.Get("first")
+	require.True(t, found)
+	require.Equal(t, 2, value)
+	_, found = kv.Get("third")
+	require.False(t, found)
+	require.Equal(t, []string{"first"}, kv.Keys())
+	require.Equal(t, []string{"third"}, other.Keys())
+	require.Equal(t, 1, kv.(storage.Counter).Count())
+}
+
+func TestShouldScanKeysInOrder(t *testing.T) {
+	kv := NewKVStorage[int](newTestDB(t), "state")
+	for i, key := range []string{"c", "a", "b"} {
+		kv.Set(key, i)
+	}
+	keys, cursor := kv.Scan("", 2)
+	require.Equal(t, []string{"a", "b"}, keys)
+	keys, cursor = kv.Scan(cursor, 2)
+	require.Equal(t, []string{"c"}, keys)
+	require.Empty(t, cursor)
+}
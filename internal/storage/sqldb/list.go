@@ -0,0 +1,256 @@
+package sqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+var allItems = storage.RangeQuery{}
+
+// ListStorage keeps entries in list_entries table, they are ordered by an auto incremented id.
+type ListStorage[T any] struct {
+	db    *DB
+	store string
+}
+
+func NewListStorage[T any](db *DB, store string) *ListStorage[T] {
+	return &ListStorage[T]{db: db, store: store}
+}
+
+func (storage *ListStorage[T]) FetchAndFlush(key string) []T {
+	type row struct {
+		id    int64
+		value T
+	}
+	// a single statement is atomic, so concurrent readers never receive the same entry twice
+	rows, err := storage.db.Query(
+		storage.db.rebind(`DELETE FROM list_entries WHERE store = ? AND key = ? RETURNING id, value, expires_at`),
+		storage.store, key,
+	)
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot fetch entries")
+		return []T{}
+	}
+	defer rows.Close()
+	now := timestamp(time.Now())
+	fetched := make([]row, 0)
+	for rows.Next() {
+		var r row
+		var data string
+		var expiresAt int64
+		if err := rows.Scan(&r.id, &data, &expiresAt); err != nil {
+			logging.Module("sql").Err(err).Str("key", key).Msg("Cannot fetch entries")
+			return []T{}
+		}
+		if expiresAt <= now {
+			continue
+		}
+		if err := json.Unmarshal([]byte(data), &r.value); err != nil {
+			logging.Module("sql").Err(err).Str("key", key).Msg("Skipping malformed entry")
+			continue
+		}
+		fetched = append(fetched, r)
+	}
+	if err := rows.Err(); err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot fetch entries")
+	}
+	// order of returned rows is not guaranteed
+	sort.Slice(fetched, func(i, j int) bool { return fetched[i].id < fetched[j].id })
+	values := make([]T, len(fetched))
+	for i, r := range fetched {
+		values[i] = r.value
+	}
+	return values
+}
+
+func (storage *ListStorage[T]) Append(key string, value T, ttl time.Duration) {
+	now := time.Now()
+	data, err := json.Marshal(value)
+	if err == nil {
+		_, err = storage.db.Exec(
+			storage.db.rebind(`INSERT INTO list_entries (store, key, value, expires_at, added_at) VALUES (?, ?, ?, ?, ?)`),
+			storage.store, key, string(data), timestamp(now.Add(ttl)), timestamp(now),
+		)
+	}
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot append entry")
+	}
+}
+
+// AppendBounded inserts the entry only if the list is shorter than the limit, the list is counted by the index
+// of store and key within the same statement.
+func (storage *ListStorage[T]) AppendBounded(key string, value T, ttl time.Duration, limit int) bool {
+	if limit <= 0 {
+		storage.Append(key, value, ttl)
+		return true
+	}
+	now := time.Now()
+	data, err := json.Marshal(value)
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot append entry")
+		return true
+	}
+	inserted, err := affectedRows(
+		storage.db,
+		`INSERT INTO list_entries (store, key, value, expires_at, added_at) SELECT ?, ?, ?, ?, ?
+			WHERE (SELECT COUNT(*) FROM list_entries WHERE store = ? AND key = ?) < ?`,
+		storage.store, key, string(data), timestamp(now.Add(ttl)), timestamp(now), storage.store, key, limit,
+	)
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot append entry")
+		return true
+	}
+	return inserted > 0
+}
+
+func (storage *ListStorage[T]) Len(key string) int {
+	count := 0
+	err := storage.db.QueryRow(
+		storage.db.rebind(`SELECT COUNT(*) FROM list_entries WHERE store = ? AND key = ? AND expires_at > ?`),
+		storage.store, key, timestamp(time.Now()),
+	).Scan(&count)
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot count entries")
+	}
+	return count
+}
+
+func (storage *ListStorage[T]) Peek(key string) []T {
+	items := storage.Range(key, allItems)
+	values := make([]T, len(items))
+	for i, item := range items {
+		values[i] = item.Value
+	}
+	return values
+}
+
+// Range uses ids as sequences, they are unique across all lists, so they never start over.
+func (storage *ListStorage[T]) Range(key string, query storage.RangeQuery) []storage.Item[T] {
+	items, err := queryItems[T](storage.db, storage.store, key, query)
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot read entries")
+		return nil
+	}
+	return items
+}
+
+func (storage *ListStorage[T]) Delete(key string) bool {
+	result, err := storage.db.Exec(
+		storage.db.rebind(`DELETE FROM list_entries WHERE store = ? AND key = ?`), storage.store, key,
+	)
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot delete entries")
+		return false
+	}
+	deleted, err := result.RowsAffected()
+	return err == nil && deleted > 0
+}
+
+func (storage *ListStorage[_]) Keys() []string {
+	keys, err := queryStrings(storage.db, `SELECT DISTINCT key FROM list_entries WHERE store = ? ORDER BY key`, storage.store)
+	if err != nil {
+		logging.Module("sql").Err(err).Msg("Cannot list keys")
+		return []string{}
+	}
+	return keys
+}
+
+func (storage *ListStorage[_]) Count() int {
+	return queryCount(storage.db, `SELECT COUNT(DISTINCT key) FROM list_entries WHERE store = ?`, storage.store)
+}
+
+func (storage *ListStorage[_]) Scan(cursor string, limit int) ([]string, string) {
+	return scanKeys(
+		storage.db, `SELECT DISTINCT key FROM list_entries WHERE store = ? AND key > ? ORDER BY key`, storage.store, cursor, limit,
+	)
+}
+
+func (storage *ListStorage[T]) CleanOutdated(key string) (int, int) {
+	before, deleted, err := countAndDelete(
+		storage.db,
+		`SELECT COUNT(*) FROM list_entries WHERE store = ? AND key = ?`,
+		`DELETE FROM list_entries WHERE store = ? AND key = ? AND expires_at <= ?`,
+		[]any{storage.store, key},
+		timestamp(time.Now()),
+	)
+	if err != nil {
+		logging.Module("sql").Err(err).Str("key", key).Msg("Cannot clean entries")
+		return 0, 0
+	}
+	return before, before - deleted
+}
+
+// countAndDelete counts rows and deletes outdated ones in a single transaction,
+// the delete statement takes the same arguments as the count one followed by the threshold.
+func countAndDelete(db *DB, count, remove string, args []any, threshold int64) (int, int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	before := 0
+	if err := tx.QueryRow(db.rebind(count), args...).Scan(&before); err != nil {
+		return 0, 0, err
+	}
+	result, err := tx.Exec(db.rebind(remove), append(args, threshold)...)
+	if err != nil {
+		return 0, 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	return before, int(deleted), tx.Commit()
+}
+
+// queryItems translates bounds of the query into conditions, so only matching rows are read.
+func queryItems[T any](db *DB, store, key string, query storage.RangeQuery) ([]storage.Item[T], error) {
+	statement := `SELECT id, added_at, value FROM list_entries WHERE store = ? AND key = ? AND expires_at > ?`
+	args := []any{store, key, timestamp(time.Now())}
+	if query.FromSequence > 0 {
+		statement += ` AND id >= ?`
+		args = append(args, query.FromSequence)
+	}
+	if query.ToSequence > 0 {
+		statement += ` AND id <= ?`
+		args = append(args, query.ToSequence)
+	}
+	if !query.From.IsZero() {
+		statement += ` AND added_at >= ?`
+		args = append(args, timestamp(query.From))
+	}
+	if !query.To.IsZero() {
+		statement += ` AND added_at <= ?`
+		args = append(args, timestamp(query.To))
+	}
+	statement += ` ORDER BY id`
+	if query.Limit > 0 {
+		statement += fmt.Sprintf(` LIMIT %d`, query.Limit)
+	}
+	rows, err := db.Query(db.rebind(statement), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]storage.Item[T], 0)
+	for rows.Next() {
+		var item storage.Item[T]
+		var addedAt int64
+		var data string
+		if err := rows.Scan(&item.Sequence, &addedAt, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &item.Value); err != nil {
+			logging.Module("sql").Err(err).Str("key", key).Msg("Skipping malformed entry")
+			continue
+		}
+		item.AddedAt = time.UnixMilli(addedAt)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
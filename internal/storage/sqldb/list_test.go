@@ -0,0 +1,13 @@
+package sqldb
+
+import (
+	"testing"
+
+	"github.com/ziollek/etherscription/pkg/storage/storagetest"
+)
+
+func TestShouldConformToListSaver(t *testing.T) {
+	storagetest.TestListSaver(t, func(t *testing.T) storagetest.ListStorage {
+		return NewListStorage[string](newTestDB(t), "list")
+	})
+}
@@ -0,0 +1,104 @@
+package sqldb
+
+import "fmt"
+
+type migration struct {
+	version    int
+	statements func(d dialect) []string
+}
+
+// migrations are applied in order, already applied ones are recorded in schema_migrations table.
+// Never change an existing migration, add a new one instead.
+var migrations = []migration{
+	{
+		version: 1,
+		statements: func(d dialect) []string {
+			return []string{
+				`CREATE TABLE kv (
+					store VARCHAR(64) NOT NULL,
+					key VARCHAR(255) NOT NULL,
+					value TEXT NOT NULL,
+					PRIMARY KEY (store, key)
+				)`,
+				fmt.Sprintf(`CREATE TABLE list_entries (
+					id %s,
+					store VARCHAR(64) NOT NULL,
+					key VARCHAR(255) NOT NULL,
+					value TEXT NOT NULL,
+					expires_at BIGINT NOT NULL
+				)`, d.serial),
+				`CREATE INDEX list_entries_store_key ON list_entries (store, key)`,
+				`CREATE INDEX list_entries_expires_at ON list_entries (expires_at)`,
+				fmt.Sprintf(`CREATE TABLE transactions (
+					id %s,
+					hash VARCHAR(66) NOT NULL,
+					block_number BIGINT NOT NULL,
+					from_address VARCHAR(42) NOT NULL,
+					to_address VARCHAR(42) NOT NULL,
+					value BIGINT NOT NULL,
+					method_selector VARCHAR(10) NOT NULL,
+					success BOOLEAN,
+					seen_at BIGINT NOT NULL
+				)`, d.serial),
+				`CREATE INDEX transactions_hash ON transactions (hash)`,
+				`CREATE INDEX transactions_block_number ON transactions (block_number)`,
+				`CREATE INDEX transactions_from_address ON transactions (from_address, block_number)`,
+				`CREATE INDEX transactions_to_address ON transactions (to_address, block_number)`,
+				`CREATE INDEX transactions_seen_at ON transactions (seen_at)`,
+			}
+		},
+	},
+	{
+		version: 2,
+		statements: func(_ dialect) []string {
+			return []string{
+				`ALTER TABLE list_entries ADD COLUMN added_at BIGINT NOT NULL DEFAULT 0`,
+			}
+		},
+	},
+	{
+		version: 3,
+		statements: func(_ dialect) []string {
+			return []string{
+				// value keeps only amounts which fit into BIGINT, larger ones are kept as decimal text
+				`ALTER TABLE transactions ADD COLUMN exact_value TEXT`,
+			}
+		},
+	},
+}
+
+func (db *DB) migrate() error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+	current := 0
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := db.apply(m); err != nil {
+			return fmt.Errorf("migration %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) apply(m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, statement := range m.statements(db.dialect) {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(db.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
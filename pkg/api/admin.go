@@ -0,0 +1,129 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/parser"
+)
+
+const (
+	apiKeyLength       = 32
+	visibleAPIKeyChars = 4
+)
+
+// AdminHandler serves operational endpoints, all of them are guarded by Authenticator.Admin.
+type AdminHandler struct {
+	parser   parser.Parser
+	tenants  parser.Tenants
+	limiter  *RateLimiter
+	reloader *config.Reloader
+}
+
+func NewAdminHandler(parser parser.Parser, tenants parser.Tenants, limiter *RateLimiter, reloader *config.Reloader) *AdminHandler {
+	return &AdminHandler{parser: parser, tenants: tenants, limiter: limiter, reloader: reloader}
+}
+
+func (h *AdminHandler) GetTenants(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	tenants := h.tenants.GetTenants()
+	response := GetTenantsResponse{Tenants: make([]TenantResponse, len(tenants))}
+	for i, tenant := range tenants {
+		response.Tenants[i] = TenantResponse{Name: tenant.Name, APIKey: maskAPIKey(tenant.APIKey)}
+	}
+	Response(w, http.StatusOK, response)
+}
+
+func (h *AdminHandler) CreateTenant(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !h.tenants.IsEnabled() {
+		ErrorResponse(http.StatusBadRequest, "Tenants are available only with enabled authentication", w)
+		return
+	}
+	var entry CreateTenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		ErrorResponse(http.StatusBadRequest, "Invalid request body", w)
+		return
+	}
+	if !model.IsValidTenantName(entry.Name) {
+		ErrorResponse(http.StatusBadRequest, "Invalid tenant name", w)
+		return
+	}
+	if entry.APIKey == "" {
+		key, err := generateAPIKey()
+		if err != nil {
+			ErrorResponse(http.StatusInternalServerError, "Cannot generate API key", w)
+			return
+		}
+		entry.APIKey = key
+	}
+	if !h.tenants.CreateTenant(model.Tenant{Name: entry.Name, APIKey: entry.APIKey}) {
+		ErrorResponse(http.StatusConflict, "Tenant name or API key is already taken", w)
+		return
+	}
+	// this is the only moment when the whole key is returned
+	Response(w, http.StatusCreated, TenantResponse{Name: entry.Name, APIKey: entry.APIKey})
+}
+
+func (h *AdminHandler) DeleteTenant(w http.ResponseWriter, _ *http.Request, params httprouter.Params) {
+	if !h.tenants.DeleteTenant(params.ByName("name")) {
+		ErrorResponse(http.StatusNotFound, "There is no such tenant", w)
+		return
+	}
+	h.limiter.Forget(params.ByName("name"))
+	Response(w, http.StatusOK, DeleteTenantResponse{
+		Status:               true,
+		RemovedSubscriptions: h.parser.UnsubscribeAll(params.ByName("name")),
+	})
+}
+
+func (h *AdminHandler) GetLogLevels(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	level, modules := logging.Levels()
+	Response(w, http.StatusOK, LogLevelsResponse{Level: level, Modules: modules})
+}
+
+func (h *AdminHandler) SetLogLevel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var entry SetLogLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		ErrorResponse(http.StatusBadRequest, "Invalid request body", w)
+		return
+	}
+	if err := logging.SetLevel(entry.Module, entry.Level); err != nil {
+		ErrorResponse(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+	logging.Module("api").Warn().Str("target", entry.Module).Str("level", entry.Level).Msg("Log level changed")
+	h.GetLogLevels(w, r, nil)
+}
+
+// ReloadConfig does the same as SIGHUP, the response lists changed options and those which need a restart.
+func (h *AdminHandler) ReloadConfig(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	result, err := h.reloader.Reload()
+	if err != nil {
+		logging.Module("api").Err(err).Msg("Cannot reload configuration")
+		ErrorResponse(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+	logging.Module("api").Info().Strs("applied", result.Applied).Strs("restart_required", result.RestartRequired).
+		Msg("Configuration reloaded")
+	Response(w, http.StatusOK, result)
+}
+
+func generateAPIKey() (string, error) {
+	key := make([]byte, apiKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+func maskAPIKey(key string) string {
+	if len(key) <= visibleAPIKeyChars {
+		return "****"
+	}
+	return "****" + key[len(key)-visibleAPIKeyChars:]
+}
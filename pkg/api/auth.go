@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/parser"
+)
+
+const (
+	APIKeyHeader   = "X-API-Key"
+	AdminKeyHeader = "X-Admin-Key"
+)
+
+type tenantContextKey struct{}
+
+// Authenticator resolves tenants from API keys and guards the admin API.
+type Authenticator struct {
+	tenants  parser.Tenants
+	adminKey string
+}
+
+func NewAuthenticator(tenants parser.Tenants, cfg *config.AuthConfig) *Authenticator {
+	return &Authenticator{tenants: tenants, adminKey: cfg.AdminKey}
+}
+
+// Tenant passes the name of the authenticated tenant to the next handler via request context.
+func (a *Authenticator) Tenant(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		tenant, found := a.tenants.Authenticate(apiKey(r))
+		if !found {
+			ErrorResponse(http.StatusUnauthorized, "Invalid or missing API key", w)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, tenant.Name)), params)
+	}
+}
+
+// Admin lets through requests having the configured admin key, the admin API is disabled without such a key.
+func (a *Authenticator) Admin(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if a.adminKey == "" {
+			ErrorResponse(http.StatusForbidden, "Admin API is disabled", w)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(AdminKeyHeader)), []byte(a.adminKey)) != 1 {
+			ErrorResponse(http.StatusUnauthorized, "Invalid or missing admin key", w)
+			return
+		}
+		next(w, r, params)
+	}
+}
+
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// apiKey accepts the key either in the dedicated header or as a bearer token.
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxBulkEntrySize bounds the size of a single entry of a bulk request, including its callback URL and filter,
+// so the body of a bulk request is limited to MaxBulkSize times this size.
+const maxBulkEntrySize = 4 << 10
+
+var errTooManyEntries = errors.New("too many entries")
+
+// decodeBulkSubscriptions reads either a JSON array of subscriptions or a plain text list of addresses, one per line.
+func decodeBulkSubscriptions(w http.ResponseWriter, r *http.Request, limit int) ([]SubscriptionsRequests, error) {
+	body := http.MaxBytesReader(w, r.Body, int64(limit)*maxBulkEntrySize)
+	if isPlainText(r) {
+		addresses, err := decodeLines(body, limit)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]SubscriptionsRequests, len(addresses))
+		for i, address := range addresses {
+			entries[i] = SubscriptionsRequests{Address: address}
+		}
+		return entries, nil
+	}
+	return decodeArray[SubscriptionsRequests](body, limit)
+}
+
+// decodeBulkAddresses reads either a JSON array of addresses or a plain text list of addresses, one per line.
+func decodeBulkAddresses(w http.ResponseWriter, r *http.Request, limit int) ([]string, error) {
+	body := http.MaxBytesReader(w, r.Body, int64(limit)*maxBulkEntrySize)
+	if isPlainText(r) {
+		return decodeLines(body, limit)
+	}
+	return decodeArray[string](body, limit)
+}
+
+// decodeArray decodes elements of a JSON array one by one, so it stops at the first element above the limit
+// instead of decoding the whole array.
+func decodeArray[T any](body io.Reader, limit int) ([]T, error) {
+	decoder := json.NewDecoder(body)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, errInvalidArray(err)
+	}
+	entries := make([]T, 0)
+	for decoder.More() {
+		if len(entries) == limit {
+			return nil, errTooManyEntries
+		}
+		var entry T
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func errInvalidArray(err error) error {
+	if err != nil {
+		return err
+	}
+	return errors.New("expected a JSON array")
+}
+
+func decodeLines(body io.Reader, limit int) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			if len(lines) == limit {
+				return nil, errTooManyEntries
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func isPlainText(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "text/plain"
+}
+
+func bulkDecodingError(err error, limit int, w http.ResponseWriter) {
+	var tooLarge *http.MaxBytesError
+	if errors.Is(err, errTooManyEntries) || errors.As(err, &tooLarge) {
+		ErrorResponse(http.StatusRequestEntityTooLarge, fmt.Sprintf("Too many entries, the limit is %d", limit), w)
+		return
+	}
+	ErrorResponse(http.StatusBadRequest, "Invalid request body", w)
+}
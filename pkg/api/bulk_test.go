@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldDecodeBulkSubscriptionsInSupportedFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		limit       int
+		want        []SubscriptionsRequests
+		wantStatus  int
+	}{
+		{
+			name:        "json array",
+			contentType: "application/json",
+			body:        `[{"address": "0x1"}, {"address": "0x2", "callback_url": "http://localhost/hook"}]`,
+			limit:       2,
+			want:        []SubscriptionsRequests{{Address: "0x1"}, {Address: "0x2", CallbackURL: "http://localhost/hook"}},
+		},
+		{
+			name:        "newline delimited addresses",
+			contentType: "text/plain; charset=utf-8",
+			body:        "0x1\n\n 0x2 \r\n",
+			limit:       2,
+			want:        []SubscriptionsRequests{{Address: "0x1"}, {Address: "0x2"}},
+		},
+		{
+			name:        "too many json entries",
+			contentType: "application/json",
+			body:        `[{"address": "0x1"}, {"address": "0x2"}]`,
+			limit:       1,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "too many lines",
+			contentType: "text/plain",
+			body:        "0x1\n0x2",
+			limit:       1,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "json array above the limit",
+			contentType: "application/json",
+			body:        `[{"address": "0x1"}, {"address": "0x2"}, not even json`,
+			limit:       1,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "too large body",
+			contentType: "application/json",
+			body:        `[{"address": "` + strings.Repeat("0", maxBulkEntrySize) + `"}]`,
+			limit:       1,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        `{"address": "0x1"}`,
+			limit:       1,
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/subscribe/bulk", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			got, err := decodeBulkSubscriptions(httptest.NewRecorder(), r, tt.limit)
+			if tt.wantStatus != 0 {
+				require.Error(t, err)
+				w := httptest.NewRecorder()
+				bulkDecodingError(err, tt.limit, w)
+				require.Equal(t, tt.wantStatus, w.Code)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,31 +1,78 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/model"
 	"github.com/ziollek/etherscription/pkg/parser"
+	"github.com/ziollek/etherscription/pkg/tracing"
+	"github.com/ziollek/etherscription/pkg/webhook"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
 )
 
 type Handler struct {
-	parser parser.Parser
+	parser  parser.Parser
+	guard   *webhook.Guard
+	cfg     *config.APIConfig
+	rpc     *config.RPCConfig
+	limiter *RateLimiter
 }
 
-func NewHandler(parser parser.Parser) *Handler {
-	return &Handler{parser: parser}
+// NewHandler takes the RPC configuration to reject filters on the status of transactions when receipts are not fetched.
+func NewHandler(
+	parser parser.Parser,
+	guard *webhook.Guard,
+	cfg *config.APIConfig,
+	rpc *config.RPCConfig,
+	limiter *RateLimiter,
+) *Handler {
+	return &Handler{parser: parser, guard: guard, cfg: cfg, rpc: rpc, limiter: limiter}
 }
 
 func (h *Handler) GetCurrentBlock(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	Response(w, http.StatusOK, GetCurrentBlocResponse{BlockID: h.parser.GetCurrentBlock()})
 }
 
-func (h *Handler) GetTransactions(w http.ResponseWriter, _ *http.Request, params httprouter.Params) {
-	if !h.parser.IsSubscribed(params.ByName("address")) {
+func (h *Handler) GetTransactions(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	tenant := TenantFromContext(r.Context())
+	if !h.parser.IsSubscribed(tenant, params.ByName("address")) {
 		ErrorResponse(http.StatusNotFound, "There is no subscription for address", w)
 		return
 	}
-	Response(w, http.StatusOK, GetTransactionsResponse{Transactions: h.parser.GetTransactions(params.ByName("address"))})
+	transactions := h.parser.GetTransactions(tenant, params.ByName("address"))
+	linkTransactions(r.Context(), transactions)
+	Response(w, http.StatusOK, GetTransactionsResponse{Transactions: model.Untraced(transactions)})
+}
+
+func (h *Handler) GetDeadLetters(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	tenant := TenantFromContext(r.Context())
+	if !h.parser.IsSubscribed(tenant, params.ByName("address")) {
+		ErrorResponse(http.StatusNotFound, "There is no subscription for address", w)
+		return
+	}
+	Response(w, http.StatusOK, GetDeadLettersResponse{DeadLetters: h.parser.GetDeadLetters(tenant, params.ByName("address"))})
+}
+
+func (h *Handler) DeleteDeadLetters(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	tenant := TenantFromContext(r.Context())
+	if !h.parser.IsSubscribed(tenant, params.ByName("address")) {
+		ErrorResponse(http.StatusNotFound, "There is no subscription for address", w)
+		return
+	}
+	removed := h.parser.DeleteDeadLetters(tenant, params.ByName("address"))
+	Response(w, http.StatusOK, DeleteDeadLettersResponse{Status: true, Removed: removed})
 }
 
 func (h *Handler) Subscribe(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -34,9 +81,178 @@ func (h *Handler) Subscribe(w http.ResponseWriter, r *http.Request, _ httprouter
 		ErrorResponse(http.StatusBadRequest, "Invalid request body", w)
 		return
 	}
-	if h.parser.Subscribe(entry.Address) {
+	if reason := h.validateSubscription(r.Context(), entry, h.guard.CheckURL); reason != "" {
+		ErrorResponse(http.StatusBadRequest, reason, w)
+		return
+	}
+	created, err := h.parser.Subscribe(TenantFromContext(r.Context()), entry.toSubscription())
+	// there is no Retry-After, retrying does not help until other subscriptions are removed or expire
+	if errors.Is(err, parser.ErrQuotaExceeded) {
+		ErrorResponse(http.StatusTooManyRequests, "Subscriptions quota exceeded", w)
+		return
+	}
+	if created {
 		Response(w, http.StatusCreated, SubscriptionsResponse{Status: true})
 		return
 	}
 	Response(w, http.StatusOK, SubscriptionsResponse{Status: false})
 }
+
+func (h *Handler) SubscribeBulk(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	entries, err := decodeBulkSubscriptions(w, r, h.cfg.MaxBulkSize)
+	if err != nil {
+		bulkDecodingError(err, h.cfg.MaxBulkSize, w)
+		return
+	}
+	results := make([]BulkResult, len(entries))
+	subscriptions := make([]model.Subscription, 0, len(entries))
+	positions := make([]int, 0, len(entries))
+	// entries often share the receiver of callbacks, so its host is resolved once
+	checkURL := h.guard.Batch()
+	for i, entry := range entries {
+		results[i].Address = entry.Address
+		if reason := h.validateSubscription(r.Context(), entry, checkURL); reason != "" {
+			results[i].Error = reason
+			continue
+		}
+		subscriptions = append(subscriptions, entry.toSubscription())
+		positions = append(positions, i)
+	}
+	for i, result := range h.parser.SubscribeMany(TenantFromContext(r.Context()), subscriptions) {
+		results[positions[i]].Status = result.Created
+		if result.Err != nil {
+			results[positions[i]].Error = result.Err.Error()
+		}
+	}
+	Response(w, http.StatusOK, BulkResponse{Results: results})
+}
+
+func (h *Handler) UnsubscribeBulk(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	addresses, err := decodeBulkAddresses(w, r, h.cfg.MaxBulkSize)
+	if err != nil {
+		bulkDecodingError(err, h.cfg.MaxBulkSize, w)
+		return
+	}
+	results := make([]BulkResult, len(addresses))
+	for i, status := range h.parser.UnsubscribeMany(TenantFromContext(r.Context()), addresses) {
+		results[i] = BulkResult{Address: addresses[i], Status: status}
+		if !status {
+			results[i].Error = "There is no subscription for address"
+		}
+	}
+	Response(w, http.StatusOK, BulkResponse{Results: results})
+}
+
+func (h *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if !h.parser.Unsubscribe(TenantFromContext(r.Context()), params.ByName("address")) {
+		ErrorResponse(http.StatusNotFound, "There is no subscription for address", w)
+		return
+	}
+	Response(w, http.StatusOK, SubscriptionsResponse{Status: true})
+}
+
+func (h *Handler) GetSubscriptions(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		ErrorResponse(http.StatusBadRequest, "Invalid offset", w)
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultPageLimit)
+	if err != nil || limit <= 0 || limit > maxPageLimit {
+		ErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid limit, it should be between 1 and %d", maxPageLimit), w)
+		return
+	}
+	subscriptions, total := h.parser.GetSubscriptions(TenantFromContext(r.Context()), offset, limit)
+	Response(w, http.StatusOK, GetSubscriptionsResponse{Subscriptions: subscriptions, Total: total, Offset: offset, Limit: limit})
+}
+
+func (h *Handler) GetSubscription(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	subscription, found := h.parser.GetSubscription(TenantFromContext(r.Context()), params.ByName("address"))
+	if !found {
+		ErrorResponse(http.StatusNotFound, "There is no subscription for address", w)
+		return
+	}
+	Response(w, http.StatusOK, h.newSubscriptionResponse(subscription))
+}
+
+func (h *Handler) Heartbeat(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	subscription, found := h.parser.Renew(TenantFromContext(r.Context()), params.ByName("address"))
+	if !found {
+		ErrorResponse(http.StatusNotFound, "There is no subscription for address", w)
+		return
+	}
+	Response(w, http.StatusOK, h.newSubscriptionResponse(subscription))
+}
+
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tenant := TenantFromContext(r.Context())
+	limits := h.limiter.Limits(tenant)
+	Response(w, http.StatusOK, GetUsageResponse{
+		Tenant:                  tenant,
+		Subscriptions:           h.parser.CountSubscriptions(tenant),
+		MaxSubscriptions:        limits.MaxSubscriptions,
+		BufferedTransactions:    h.parser.CountBufferedTransactions(tenant),
+		MaxBufferedTransactions: limits.MaxBufferedTransactions,
+		RequestsPerSecond:       limits.RequestsPerSecond,
+		Burst:                   limits.Burst,
+		AvailableRequests:       h.limiter.Tokens(tenant),
+	})
+}
+
+func (h *Handler) newSubscriptionResponse(subscription model.Subscription) GetSubscriptionResponse {
+	return GetSubscriptionResponse{
+		Address:              subscription.Address,
+		CallbackURL:          subscription.CallbackURL,
+		Filter:               subscription.Filter,
+		CreatedAt:            subscription.CreatedAt,
+		LastDeliveryAt:       subscription.LastDeliveryAt,
+		TTL:                  subscription.TTL,
+		ExpiresAt:            subscription.ExpiresAt,
+		BufferedTransactions: h.parser.CountTransactions(subscription.Tenant, subscription.Address),
+	}
+}
+
+// linkTransactions relates the request with traces of served transactions, so the way of a transaction
+// from the moment its block was seen to the moment it was fetched by the client can be followed.
+func linkTransactions(ctx context.Context, transactions []model.TracedTransaction) {
+	span := trace.SpanFromContext(ctx)
+	for _, transaction := range transactions {
+		if link, ok := tracing.Link(transaction.TraceParent); ok {
+			span.AddLink(link)
+		}
+	}
+}
+
+func queryInt(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func (h *Handler) validateSubscription(
+	ctx context.Context, entry SubscriptionsRequests, checkURL func(ctx context.Context, callbackURL string) error,
+) string {
+	if !model.IsValidAddress(entry.Address) {
+		return "Invalid address"
+	}
+	if entry.CallbackURL != "" {
+		if err := checkURL(ctx, entry.CallbackURL); err != nil {
+			return fmt.Sprintf("Invalid callback URL: %s", err)
+		}
+	}
+	if entry.TTL < 0 {
+		return "Invalid TTL"
+	}
+	if entry.Filter != nil {
+		if err := entry.Filter.Validate(); err != nil {
+			return fmt.Sprintf("Invalid filter: %s", err)
+		}
+		// the status of transactions is unknown without receipts, so nothing would be delivered
+		if entry.Filter.SuccessOnly && !h.rpc.FetchReceipts {
+			return "Invalid filter: success_only requires rpc.fetch_receipts to be enabled"
+		}
+	}
+	return ""
+}
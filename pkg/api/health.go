@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ziollek/etherscription/pkg/health"
+)
+
+// HealthHandler serves probes, both of them respond with the full report and 503 when a check fails.
+type HealthHandler struct {
+	monitor *health.Monitor
+}
+
+func NewHealthHandler(monitor *health.Monitor) *HealthHandler {
+	return &HealthHandler{monitor: monitor}
+}
+
+func (h *HealthHandler) Healthz(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	probeResponse(w, h.monitor.Liveness(time.Now()))
+}
+
+func (h *HealthHandler) Readyz(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	probeResponse(w, h.monitor.Readiness(time.Now()))
+}
+
+func probeResponse(w http.ResponseWriter, report health.Report) {
+	if !report.Healthy {
+		Response(w, http.StatusServiceUnavailable, report)
+		return
+	}
+	Response(w, http.StatusOK, report)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+// HistoryHandler serves transaction history, it is available only when the storage backend keeps it.
+type HistoryHandler struct {
+	history storage.History
+}
+
+func NewHistoryHandler(history storage.History) *HistoryHandler {
+	return &HistoryHandler{history: history}
+}
+
+func (h *HistoryHandler) GetHistory(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if h.history == nil {
+		ErrorResponse(http.StatusNotImplemented, "Transaction history is available only with sql storage backends", w)
+		return
+	}
+	query, reason := parseHistoryQuery(r)
+	if reason != "" {
+		ErrorResponse(http.StatusBadRequest, reason, w)
+		return
+	}
+	transactions, err := h.history.Query(query)
+	if err != nil {
+		logging.Module("api").Err(err).Msg("Cannot query transaction history")
+		ErrorResponse(http.StatusInternalServerError, "Cannot query transaction history", w)
+		return
+	}
+	Response(w, http.StatusOK, GetHistoryResponse{Transactions: transactions, Offset: query.Offset, Limit: query.Limit})
+}
+
+func parseHistoryQuery(r *http.Request) (storage.HistoryQuery, string) {
+	query := storage.HistoryQuery{
+		Address: r.URL.Query().Get("address"),
+		Hash:    r.URL.Query().Get("hash"),
+	}
+	if query.Address != "" && !model.IsValidAddress(query.Address) {
+		return query, "Invalid address"
+	}
+	var err error
+	if query.FromBlock, err = queryInt(r, "from_block", 0); err != nil || query.FromBlock < 0 {
+		return query, "Invalid from_block"
+	}
+	if query.ToBlock, err = queryInt(r, "to_block", 0); err != nil || query.ToBlock < 0 {
+		return query, "Invalid to_block"
+	}
+	if query.ToBlock > 0 && query.FromBlock > query.ToBlock {
+		return query, "from_block should not be greater than to_block"
+	}
+	if query.Offset, err = queryInt(r, "offset", 0); err != nil || query.Offset < 0 {
+		return query, "Invalid offset"
+	}
+	query.Limit, err = queryInt(r, "limit", defaultPageLimit)
+	if err != nil || query.Limit <= 0 || query.Limit > maxPageLimit {
+		return query, fmt.Sprintf("Invalid limit, it should be between 1 and %d", maxPageLimit)
+	}
+	return query, ""
+}
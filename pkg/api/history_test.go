@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+type recordingHistory struct {
+	queries []storage.HistoryQuery
+}
+
+func (h *recordingHistory) Query(query storage.HistoryQuery) ([]model.Transaction, error) {
+	h.queries = append(h.queries, query)
+	return []model.Transaction{}, nil
+}
+
+func TestShouldQueryHistory(t *testing.T) {
+	type testCase struct {
+		name     string
+		url      string
+		wantCode int
+		want     []storage.HistoryQuery
+	}
+	tests := []testCase{
+		{
+			name:     "should apply defaults",
+			url:      "/api/history",
+			wantCode: http.StatusOK,
+			want:     []storage.HistoryQuery{{Limit: defaultPageLimit}},
+		},
+		{
+			name:     "should pass all parameters",
+			url:      "/api/history?address=0x00000000000000000000000000000000000000a1&hash=0x01&from_block=1&to_block=2&offset=3&limit=4",
+			wantCode: http.StatusOK,
+			want: []storage.HistoryQuery{{
+				Address:   "0x00000000000000000000000000000000000000a1",
+				Hash:      "0x01",
+				FromBlock: 1,
+				ToBlock:   2,
+				Offset:    3,
+				Limit:     4,
+			}},
+		},
+		{name: "should reject invalid address", url: "/api/history?address=0x01", wantCode: http.StatusBadRequest},
+		{name: "should reject reversed block range", url: "/api/history?from_block=2&to_block=1", wantCode: http.StatusBadRequest},
+		{name: "should reject too big limit", url: "/api/history?limit=1001", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := &recordingHistory{}
+			w := httptest.NewRecorder()
+			NewHistoryHandler(history).GetHistory(w, httptest.NewRequest("GET", tt.url, nil), nil)
+			require.Equal(t, tt.wantCode, w.Code)
+			require.Equal(t, tt.want, history.queries)
+		})
+	}
+}
+
+func TestShouldReportMissingHistory(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewHistoryHandler(nil).GetHistory(w, httptest.NewRequest("GET", "/api/history", nil), nil)
+	require.Equal(t, http.StatusNotImplemented, w.Code)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ziollek/etherscription/pkg/metrics"
+	"github.com/ziollek/etherscription/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// instrument measures latency of the route and traces it, continuing the trace of the caller if there is one.
+// The route pattern is used instead of the path, so addresses do not blow up the number of series.
+func instrument(route string, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		start := time.Now()
+		ctx, span := tracing.Start(
+			tracing.ExtractHeaders(r.Context(), r.Header),
+			r.Method+" "+route,
+			attribute.String("http.route", route),
+			attribute.String("http.request.method", r.Method),
+		)
+		defer span.End()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r.WithContext(ctx), params)
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		metrics.APIDuration.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/metrics"
+)
+
+func TestShouldMeasureLatencyByRoutePattern(t *testing.T) {
+	handle := instrument("/api/test/:address", func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		ErrorResponse(http.StatusNotFound, "There is no subscription for address", w)
+	})
+	for _, address := range []string{"0x1", "0x2"} {
+		w := httptest.NewRecorder()
+		handle(w, httptest.NewRequest(http.MethodGet, "/api/test/"+address, nil), nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Contains(
+		t,
+		w.Body.String(),
+		`etherscription_api_request_duration_seconds_count{method="GET",route="/api/test/:address",status="404"} 2`,
+	)
+}
@@ -1,6 +1,10 @@
 package api
 
-import "github.com/ziollek/etherscription/pkg/model"
+import (
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/model"
+)
 
 type JSONErrorResponse struct {
 	Error *GenericError `json:"error"`
@@ -12,7 +16,14 @@ type GenericError struct {
 }
 
 type SubscriptionsRequests struct {
-	Address string `json:"address"`
+	Address     string         `json:"address"`
+	CallbackURL string         `json:"callback_url,omitempty"`
+	TTL         model.Duration `json:"ttl,omitempty"`
+	Filter      *model.Filter  `json:"filter,omitempty"`
+}
+
+func (r SubscriptionsRequests) toSubscription() model.Subscription {
+	return model.Subscription{Address: r.Address, CallbackURL: r.CallbackURL, TTL: r.TTL, Filter: r.Filter}
 }
 
 type SubscriptionsResponse struct {
@@ -26,3 +37,115 @@ type GetCurrentBlocResponse struct {
 type GetTransactionsResponse struct {
 	Transactions []model.Transaction `json:"transactions"`
 }
+
+type GetDeadLettersResponse struct {
+	DeadLetters []model.DeadLetter `json:"dead_letters"`
+}
+
+type DeleteDeadLettersResponse struct {
+	Status  bool `json:"status"`
+	Removed int  `json:"removed"`
+}
+
+type GetSubscriptionsResponse struct {
+	Subscriptions []model.Subscription `json:"subscriptions"`
+	Total         int                  `json:"total"`
+	Offset        int                  `json:"offset"`
+	Limit         int                  `json:"limit"`
+}
+
+type GetSubscriptionResponse struct {
+	Address              string         `json:"address"`
+	CallbackURL          string         `json:"callback_url,omitempty"`
+	Filter               *model.Filter  `json:"filter,omitempty"`
+	CreatedAt            time.Time      `json:"created_at"`
+	LastDeliveryAt       *time.Time     `json:"last_delivery_at,omitempty"`
+	TTL                  model.Duration `json:"ttl,omitempty"`
+	ExpiresAt            *time.Time     `json:"expires_at,omitempty"`
+	BufferedTransactions int            `json:"buffered_transactions"`
+}
+
+type BulkResult struct {
+	Address string `json:"address"`
+	Status  bool   `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+type BulkResponse struct {
+	Results []BulkResult `json:"results"`
+}
+
+type CreateTenantRequest struct {
+	Name   string `json:"name"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type TenantResponse struct {
+	Name   string `json:"name"`
+	APIKey string `json:"api_key"`
+}
+
+type GetTenantsResponse struct {
+	Tenants []TenantResponse `json:"tenants"`
+}
+
+type DeleteTenantResponse struct {
+	Status               bool `json:"status"`
+	RemovedSubscriptions int  `json:"removed_subscriptions"`
+}
+
+// LogLevelsResponse lists the default level and overrides of modules.
+type LogLevelsResponse struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules"`
+}
+
+// SetLogLevelRequest changes the default level when Module is empty,
+// otherwise the level of the module, an empty Level removes the override of the module.
+type SetLogLevelRequest struct {
+	Module string `json:"module,omitempty"`
+	Level  string `json:"level"`
+}
+
+type BackfillRequest struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// BufferResponse lists transactions buffered for the address of the tenant, they are not removed.
+type BufferResponse struct {
+	Tenant       string              `json:"tenant"`
+	Address      string              `json:"address"`
+	Count        int                 `json:"count"`
+	Transactions []model.Transaction `json:"transactions"`
+}
+
+type FlushBufferResponse struct {
+	Status  bool `json:"status"`
+	Removed int  `json:"removed"`
+}
+
+// QueueResponse describes transactions fetched but not consumed by the broker yet.
+type QueueResponse struct {
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+}
+
+// GetUsageResponse describes current usage of the tenant, zero limits mean no limit.
+// AvailableRequests equals -1 when requests are not rate limited.
+type GetUsageResponse struct {
+	Tenant                  string  `json:"tenant"`
+	Subscriptions           int     `json:"subscriptions"`
+	MaxSubscriptions        int     `json:"max_subscriptions"`
+	BufferedTransactions    int     `json:"buffered_transactions"`
+	MaxBufferedTransactions int     `json:"max_buffered_transactions_per_subscription"`
+	RequestsPerSecond       float64 `json:"requests_per_second"`
+	Burst                   int     `json:"burst"`
+	AvailableRequests       int     `json:"available_requests"`
+}
+
+type GetHistoryResponse struct {
+	Transactions []model.Transaction `json:"transactions"`
+	Offset       int                 `json:"offset"`
+	Limit        int                 `json:"limit"`
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ziollek/etherscription/pkg/etherum"
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+// Ingestion is implemented by etherum.Fetcher.
+type Ingestion interface {
+	Status() etherum.Status
+	Pause()
+	Resume()
+	RecreateFilter(ctx context.Context) (string, error)
+	Backfill(from, to int) error
+}
+
+// OperationsHandler serves admin endpoints controlling the pipeline, all of them are guarded by Authenticator.Admin.
+// Buffers are accessed directly, so they can be inspected regardless of subscriptions.
+type OperationsHandler struct {
+	ingestion Ingestion
+	buffers   storage.ListSaver[model.TracedTransaction]
+	// queue returns the number of transactions waiting for the broker and the capacity of the queue
+	queue func() (int, int)
+	stats func() map[string]storage.Stats
+}
+
+func NewOperationsHandler(
+	ingestion Ingestion,
+	buffers storage.ListSaver[model.TracedTransaction],
+	queue func() (int, int),
+	stats func() map[string]storage.Stats,
+) *OperationsHandler {
+	return &OperationsHandler{ingestion: ingestion, buffers: buffers, queue: queue, stats: stats}
+}
+
+func (h *OperationsHandler) GetIngestion(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	Response(w, http.StatusOK, h.ingestion.Status())
+}
+
+func (h *OperationsHandler) PauseIngestion(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	h.ingestion.Pause()
+	logging.Module("api").Warn().Msg("Ingestion paused")
+	Response(w, http.StatusOK, h.ingestion.Status())
+}
+
+func (h *OperationsHandler) ResumeIngestion(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	h.ingestion.Resume()
+	logging.Module("api").Warn().Msg("Ingestion resumed")
+	Response(w, http.StatusOK, h.ingestion.Status())
+}
+
+func (h *OperationsHandler) RecreateFilter(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if _, err := h.ingestion.RecreateFilter(r.Context()); err != nil {
+		ErrorResponse(http.StatusBadGateway, "Cannot create filter: "+err.Error(), w)
+		return
+	}
+	Response(w, http.StatusOK, h.ingestion.Status())
+}
+
+func (h *OperationsHandler) Backfill(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var entry BackfillRequest
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		ErrorResponse(http.StatusBadRequest, "Invalid request body", w)
+		return
+	}
+	err := h.ingestion.Backfill(entry.From, entry.To)
+	switch {
+	case errors.Is(err, etherum.ErrInvalidRange):
+		ErrorResponse(http.StatusBadRequest, err.Error(), w)
+	case err != nil:
+		ErrorResponse(http.StatusConflict, err.Error(), w)
+	default:
+		logging.Module("api").Warn().Int("from", entry.From).Int("to", entry.To).Msg("Backfill requested")
+		// progress is reported by GET /admin/ingestion
+		Response(w, http.StatusAccepted, h.ingestion.Status())
+	}
+}
+
+func (h *OperationsHandler) GetBuffer(w http.ResponseWriter, _ *http.Request, params httprouter.Params) {
+	transactions := h.buffers.Peek(model.ScopedKey(params.ByName("tenant"), params.ByName("address")))
+	Response(w, http.StatusOK, BufferResponse{
+		Tenant:       params.ByName("tenant"),
+		Address:      params.ByName("address"),
+		Count:        len(transactions),
+		Transactions: model.Untraced(transactions),
+	})
+}
+
+func (h *OperationsHandler) FlushBuffer(w http.ResponseWriter, _ *http.Request, params httprouter.Params) {
+	key := model.ScopedKey(params.ByName("tenant"), params.ByName("address"))
+	removed := h.buffers.Len(key)
+	if !h.buffers.Delete(key) {
+		ErrorResponse(http.StatusNotFound, "There are no buffered transactions", w)
+		return
+	}
+	logging.Module("api").Warn().Str("key", key).Int("removed", removed).Msg("Buffer flushed")
+	Response(w, http.StatusOK, FlushBufferResponse{Status: true, Removed: removed})
+}
+
+func (h *OperationsHandler) GetQueue(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	depth, capacity := h.queue()
+	Response(w, http.StatusOK, QueueResponse{Depth: depth, Capacity: capacity})
+}
+
+func (h *OperationsHandler) GetStorage(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	Response(w, http.StatusOK, h.stats())
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/internal/storage/memory"
+	"github.com/ziollek/etherscription/pkg/etherum"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+type fakeIngestion struct {
+	status      etherum.Status
+	backfillErr error
+	filterErr   error
+}
+
+func (i *fakeIngestion) Status() etherum.Status { return i.status }
+func (i *fakeIngestion) Pause()                 { i.status.State = etherum.StatePaused }
+func (i *fakeIngestion) Resume()                { i.status.State = etherum.StateRunning }
+
+func (i *fakeIngestion) RecreateFilter(context.Context) (string, error) {
+	if i.filterErr != nil {
+		return "", i.filterErr
+	}
+	i.status.FilterID = "0x2"
+	return i.status.FilterID, nil
+}
+
+func (i *fakeIngestion) Backfill(from, to int) error {
+	if i.backfillErr == nil {
+		i.status.Backfill = &etherum.Backfill{From: from, To: to, Next: from, Running: true}
+	}
+	return i.backfillErr
+}
+
+func newOperationsHandler(ingestion Ingestion, buffers storage.ListSaver[model.TracedTransaction]) *OperationsHandler {
+	return NewOperationsHandler(
+		ingestion,
+		buffers,
+		func() (int, int) { return 3, 100 },
+		func() map[string]storage.Stats {
+			return map[string]storage.Stats{"transactions": {Keys: 1, Entries: 2}}
+		},
+	)
+}
+
+func TestShouldControlIngestion(t *testing.T) {
+	ingestion := &fakeIngestion{status: etherum.Status{State: etherum.StateRunning, FilterID: "0x1"}}
+	handler := newOperationsHandler(ingestion, memory.NewListStorage[model.TracedTransaction]())
+
+	w := httptest.NewRecorder()
+	handler.PauseIngestion(w, httptest.NewRequest("POST", "/admin/ingestion/pause", nil), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), string(etherum.StatePaused))
+
+	w = httptest.NewRecorder()
+	handler.ResumeIngestion(w, httptest.NewRequest("POST", "/admin/ingestion/resume", nil), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), string(etherum.StateRunning))
+
+	w = httptest.NewRecorder()
+	handler.RecreateFilter(w, httptest.NewRequest("POST", "/admin/ingestion/filter", nil), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "0x2", ingestion.status.FilterID)
+}
+
+func TestShouldReportFailedFilterRecreation(t *testing.T) {
+	handler := newOperationsHandler(&fakeIngestion{filterErr: errors.New("connection refused")}, nil)
+	w := httptest.NewRecorder()
+
+	handler.RecreateFilter(w, httptest.NewRequest("POST", "/admin/ingestion/filter", nil), nil)
+
+	require.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+func TestShouldTriggerBackfill(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		err      error
+		wantCode int
+	}{
+		{"should accept range", `{"from": 10, "to": 20}`, nil, http.StatusAccepted},
+		{"should reject invalid body", `{"from": "10"}`, nil, http.StatusBadRequest},
+		{"should reject invalid range", `{"from": 20, "to": 10}`, etherum.ErrInvalidRange, http.StatusBadRequest},
+		{"should reject when not running", `{"from": 10, "to": 20}`, etherum.ErrNotRunning, http.StatusConflict},
+		{"should reject concurrent backfill", `{"from": 10, "to": 20}`, etherum.ErrBackfillRunning, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newOperationsHandler(&fakeIngestion{backfillErr: tt.err}, nil)
+			w := httptest.NewRecorder()
+
+			handler.Backfill(w, httptest.NewRequest("POST", "/admin/ingestion/backfill", strings.NewReader(tt.body)), nil)
+
+			require.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestShouldInspectAndFlushBuffer(t *testing.T) {
+	buffers := memory.NewListStorage[model.TracedTransaction]()
+	key := model.ScopedKey("payments", "0x00000000000000000000000000000000000000a1")
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	buffers.Append(key, model.TracedTransaction{Transaction: model.Transaction{Hash: "0x01"}, TraceParent: traceParent}, time.Minute)
+	buffers.Append(key, model.TracedTransaction{Transaction: model.Transaction{Hash: "0x02"}}, time.Minute)
+	handler := newOperationsHandler(&fakeIngestion{}, buffers)
+	params := httprouter.Params{
+		{Key: "tenant", Value: "payments"},
+		{Key: "address", Value: "0x00000000000000000000000000000000000000a1"},
+	}
+
+	w := httptest.NewRecorder()
+	handler.GetBuffer(w, httptest.NewRequest("GET", "/admin/buffers/payments/0xa1", nil), params)
+	require.NotContains(t, w.Body.String(), traceParent)
+	buffer := BufferResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &buffer))
+	require.Equal(t, 2, buffer.Count)
+	require.Equal(t, 2, buffers.Len(key))
+
+	w = httptest.NewRecorder()
+	handler.FlushBuffer(w, httptest.NewRequest("DELETE", "/admin/buffers/payments/0xa1", nil), params)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status": true, "removed": 2}`, w.Body.String())
+	require.Equal(t, 0, buffers.Len(key))
+
+	w = httptest.NewRecorder()
+	handler.FlushBuffer(w, httptest.NewRequest("DELETE", "/admin/buffers/payments/0xa1", nil), params)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestShouldReportQueueAndStorage(t *testing.T) {
+	handler := newOperationsHandler(&fakeIngestion{}, nil)
+
+	w := httptest.NewRecorder()
+	handler.GetQueue(w, httptest.NewRequest("GET", "/admin/queue", nil), nil)
+	require.JSONEq(t, `{"depth": 3, "capacity": 100}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.GetStorage(w, httptest.NewRequest("GET", "/admin/storage", nil), nil)
+	require.JSONEq(t, `{"transactions": {"keys": 1, "entries": 2}}`, w.Body.String())
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ziollek/etherscription/pkg/config"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter throttles requests of every tenant with a separate token bucket.
+// Buckets keep their tokens when quotas are reloaded, only their rate and burst change.
+type RateLimiter struct {
+	quotas   *config.QuotaConfig
+	limiters map[string]*rate.Limiter
+	mutex    sync.Mutex
+}
+
+func NewRateLimiter(quotas *config.QuotaConfig) *RateLimiter {
+	return &RateLimiter{
+		quotas:   quotas,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// SetQuotas applies reloaded quotas to buckets of all tenants, buckets of tenants which are no longer limited are removed.
+func (l *RateLimiter) SetQuotas(quotas *config.QuotaConfig) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.quotas = quotas
+	for tenant, limiter := range l.limiters {
+		limits := quotas.For(tenant)
+		if limits.RequestsPerSecond <= 0 {
+			delete(l.limiters, tenant)
+			continue
+		}
+		limiter.SetLimit(rate.Limit(limits.RequestsPerSecond))
+		limiter.SetBurst(max(limits.Burst, 1))
+	}
+}
+
+// Forget removes the bucket of a deleted tenant.
+func (l *RateLimiter) Forget(tenant string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.limiters, tenant)
+}
+
+// Limits returns current quotas of the tenant.
+func (l *RateLimiter) Limits(tenant string) config.QuotaLimits {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.quotas.For(tenant)
+}
+
+// Limit has to be wrapped by Authenticator.Tenant, because buckets are chosen by tenant.
+func (l *RateLimiter) Limit(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		limiter := l.limiter(TenantFromContext(r.Context()))
+		if limiter == nil {
+			next(w, r, params)
+			return
+		}
+		reservation := limiter.Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			ErrorResponse(http.StatusTooManyRequests, "Rate limit exceeded", w)
+			return
+		}
+		next(w, r, params)
+	}
+}
+
+// Tokens returns the number of requests the tenant can make immediately, -1 means no limit.
+func (l *RateLimiter) Tokens(tenant string) int {
+	limiter := l.limiter(tenant)
+	if limiter == nil {
+		return -1
+	}
+	return int(limiter.TokensAt(time.Now()))
+}
+
+func (l *RateLimiter) limiter(tenant string) *rate.Limiter {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	limits := l.quotas.For(tenant)
+	if limits.RequestsPerSecond <= 0 {
+		return nil
+	}
+	limiter, found := l.limiters[tenant]
+	if !found {
+		limiter = rate.NewLimiter(rate.Limit(limits.RequestsPerSecond), max(limits.Burst, 1))
+		l.limiters[tenant] = limiter
+	}
+	return limiter
+}
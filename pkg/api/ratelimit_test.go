@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+)
+
+func TestShouldLimitRequestsPerTenant(t *testing.T) {
+	limiter := NewRateLimiter(&config.QuotaConfig{
+		QuotaLimits: config.QuotaLimits{RequestsPerSecond: 0.1, Burst: 2},
+		Tenants:     map[string]config.QuotaLimits{"unlimited": {RequestsPerSecond: -1}},
+	})
+	handle := limiter.Limit(func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusOK)
+	})
+	call := func(tenant string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/usage", nil)
+		r = r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, tenant))
+		w := httptest.NewRecorder()
+		handle(w, r, nil)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, call("first").Code)
+	require.Equal(t, http.StatusOK, call("first").Code)
+	limited := call("first")
+	require.Equal(t, http.StatusTooManyRequests, limited.Code)
+	require.Equal(t, "10", limited.Header().Get("Retry-After"))
+	require.Equal(t, 0, limiter.Tokens("first"))
+
+	require.Equal(t, http.StatusOK, call("second").Code)
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, call("unlimited").Code)
+	}
+	require.Equal(t, -1, limiter.Tokens("unlimited"))
+}
+
+func TestShouldApplyReloadedQuotasToExistingBuckets(t *testing.T) {
+	limiter := NewRateLimiter(&config.QuotaConfig{QuotaLimits: config.QuotaLimits{RequestsPerSecond: 0.1, Burst: 1}})
+	require.Equal(t, 1, limiter.Tokens("first"))
+	require.Equal(t, 1, limiter.Tokens("second"))
+
+	limiter.SetQuotas(&config.QuotaConfig{
+		QuotaLimits: config.QuotaLimits{RequestsPerSecond: 0.1, Burst: 5},
+		Tenants:     map[string]config.QuotaLimits{"second": {RequestsPerSecond: -1}},
+	})
+	require.Equal(t, 5, limiter.Limits("first").Burst)
+	require.Equal(t, -1, limiter.Tokens("second"))
+	require.NotContains(t, limiter.limiters, "second")
+
+	limiter.Forget("first")
+	require.Empty(t, limiter.limiters)
+}
@@ -26,7 +26,7 @@ func Response(w http.ResponseWriter, statusCode int, response interface{}) {
 	if response != nil {
 		if err := encoder.Encode(response); err != nil {
 			ErrorResponse(http.StatusInternalServerError, "Internal Server Error", w)
-			logging.Logger().Err(err)
+			logging.Module("api").Err(err).Msg("Cannot encode response")
 		}
 	}
 }
@@ -1,13 +1,65 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/julienschmidt/httprouter"
+	"github.com/ziollek/etherscription/pkg/metrics"
 )
 
-func ConfigureRouting(handler *Handler) *httprouter.Router {
+func ConfigureRouting(
+	handler *Handler,
+	admin *AdminHandler,
+	history *HistoryHandler,
+	health *HealthHandler,
+	operations *OperationsHandler,
+	auth *Authenticator,
+	limiter *RateLimiter,
+) *httprouter.Router {
 	router := httprouter.New()
-	router.GET("/api/current-block", handler.GetCurrentBlock)
-	router.GET("/api/new-transactions/:address", handler.GetTransactions)
-	router.POST("/api/subscribe", handler.Subscribe)
+	handle := func(method, route string, next httprouter.Handle) {
+		router.Handle(method, route, instrument(route, next))
+	}
+	// tenant scoped endpoints are rate limited per tenant
+	scoped := func(next httprouter.Handle) httprouter.Handle {
+		return auth.Tenant(limiter.Limit(next))
+	}
+	handle(http.MethodGet, "/api/current-block", handler.GetCurrentBlock)
+	handle(http.MethodGet, "/api/new-transactions/:address", scoped(handler.GetTransactions))
+	handle(http.MethodGet, "/api/dead-letters/:address", scoped(handler.GetDeadLetters))
+	handle(http.MethodDelete, "/api/dead-letters/:address", scoped(handler.DeleteDeadLetters))
+	handle(http.MethodPost, "/api/subscribe", scoped(handler.Subscribe))
+	handle(http.MethodPost, "/api/subscribe/bulk", scoped(handler.SubscribeBulk))
+	handle(http.MethodDelete, "/api/subscribe/:address", scoped(handler.Unsubscribe))
+	handle(http.MethodPost, "/api/unsubscribe/bulk", scoped(handler.UnsubscribeBulk))
+	handle(http.MethodGet, "/api/subscriptions", scoped(handler.GetSubscriptions))
+	handle(http.MethodGet, "/api/subscriptions/:address", scoped(handler.GetSubscription))
+	handle(http.MethodPost, "/api/subscriptions/:address/heartbeat", scoped(handler.Heartbeat))
+	handle(http.MethodGet, "/api/usage", scoped(handler.GetUsage))
+	handle(http.MethodGet, "/api/history", scoped(history.GetHistory))
+
+	// all admin endpoints require the admin key
+	adminHandle := func(method, route string, next httprouter.Handle) {
+		handle(method, "/admin"+route, auth.Admin(next))
+	}
+	adminHandle(http.MethodGet, "/tenants", admin.GetTenants)
+	adminHandle(http.MethodPost, "/tenants", admin.CreateTenant)
+	adminHandle(http.MethodDelete, "/tenants/:name", admin.DeleteTenant)
+	adminHandle(http.MethodGet, "/log-levels", admin.GetLogLevels)
+	adminHandle(http.MethodPut, "/log-levels", admin.SetLogLevel)
+	adminHandle(http.MethodPost, "/config/reload", admin.ReloadConfig)
+	adminHandle(http.MethodGet, "/ingestion", operations.GetIngestion)
+	adminHandle(http.MethodPost, "/ingestion/pause", operations.PauseIngestion)
+	adminHandle(http.MethodPost, "/ingestion/resume", operations.ResumeIngestion)
+	adminHandle(http.MethodPost, "/ingestion/filter", operations.RecreateFilter)
+	adminHandle(http.MethodPost, "/ingestion/backfill", operations.Backfill)
+	adminHandle(http.MethodGet, "/buffers/:tenant/:address", operations.GetBuffer)
+	adminHandle(http.MethodDelete, "/buffers/:tenant/:address", operations.FlushBuffer)
+	adminHandle(http.MethodGet, "/queue", operations.GetQueue)
+	adminHandle(http.MethodGet, "/storage", operations.GetStorage)
+	// probes are not instrumented, they would dominate latency metrics
+	router.GET("/healthz", health.Healthz)
+	router.GET("/readyz", health.Readyz)
+	router.Handler(http.MethodGet, "/metrics", metrics.Handler())
 	return router
 }
@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ziollek/etherscription/pkg/api"
+	"github.com/ziollek/etherscription/pkg/etherum"
+)
+
+// GetIngestion returns the status of the fetcher including progress of the last backfill, it requires the admin key.
+func (c *Client) GetIngestion(ctx context.Context) (etherum.Status, error) {
+	status := etherum.Status{}
+	err := c.call(ctx, http.MethodGet, "/admin/ingestion", nil, &status)
+	return status, err
+}
+
+// Backfill starts fetching transactions of past blocks in the background, both ends of the range are included.
+// Progress is reported by GetIngestion, it fails with ErrConflict when another backfill is running.
+func (c *Client) Backfill(ctx context.Context, from, to int) (etherum.Status, error) {
+	status := etherum.Status{}
+	err := c.call(ctx, http.MethodPost, "/admin/ingestion/backfill", api.BackfillRequest{From: from, To: to}, &status)
+	return status, err
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ziollek/etherscription/pkg/api"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+// GetCurrentBlock returns the latest block processed by the instance.
+func (c *Client) GetCurrentBlock(ctx context.Context) (int, error) {
+	response := api.GetCurrentBlocResponse{}
+	if err := c.call(ctx, http.MethodGet, "/api/current-block", nil, &response); err != nil {
+		return 0, err
+	}
+	return response.BlockID, nil
+}
+
+// Subscribe returns false when the address was already subscribed, such a subscription is left untouched.
+// It fails with ErrRateLimited without RetryAfter when the subscriptions quota of the tenant is exceeded.
+func (c *Client) Subscribe(ctx context.Context, subscription api.SubscriptionsRequests) (bool, error) {
+	response := api.SubscriptionsResponse{}
+	if err := c.call(ctx, http.MethodPost, "/api/subscribe", subscription, &response); err != nil {
+		return false, err
+	}
+	return response.Status, nil
+}
+
+// SubscribeBulk returns a result for every subscription, invalid ones are reported in results instead of failing the call.
+func (c *Client) SubscribeBulk(ctx context.Context, subscriptions []api.SubscriptionsRequests) ([]api.BulkResult, error) {
+	response := api.BulkResponse{}
+	if err := c.call(ctx, http.MethodPost, "/api/subscribe/bulk", subscriptions, &response); err != nil {
+		return nil, err
+	}
+	return response.Results, nil
+}
+
+// Unsubscribe removes the subscription together with its buffered transactions and dead letters.
+func (c *Client) Unsubscribe(ctx context.Context, address string) error {
+	return c.call(ctx, http.MethodDelete, "/api/subscribe/"+url.PathEscape(address), nil, &api.SubscriptionsResponse{})
+}
+
+func (c *Client) UnsubscribeBulk(ctx context.Context, addresses []string) ([]api.BulkResult, error) {
+	response := api.BulkResponse{}
+	if err := c.call(ctx, http.MethodPost, "/api/unsubscribe/bulk", addresses, &response); err != nil {
+		return nil, err
+	}
+	return response.Results, nil
+}
+
+// GetSubscriptions returns a page of subscriptions ordered by address, a zero limit means the default one.
+func (c *Client) GetSubscriptions(ctx context.Context, offset, limit int) (*api.GetSubscriptionsResponse, error) {
+	query := url.Values{"offset": {strconv.Itoa(offset)}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	response := &api.GetSubscriptionsResponse{}
+	if err := c.call(ctx, http.MethodGet, "/api/subscriptions?"+query.Encode(), nil, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *Client) GetSubscription(ctx context.Context, address string) (*api.GetSubscriptionResponse, error) {
+	response := &api.GetSubscriptionResponse{}
+	if err := c.call(ctx, http.MethodGet, "/api/subscriptions/"+url.PathEscape(address), nil, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Heartbeat renews the subscription, so it expires after its TTL counting from now.
+func (c *Client) Heartbeat(ctx context.Context, address string) (*api.GetSubscriptionResponse, error) {
+	response := &api.GetSubscriptionResponse{}
+	path := "/api/subscriptions/" + url.PathEscape(address) + "/heartbeat"
+	if err := c.call(ctx, http.MethodPost, path, nil, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetTransactions returns transactions buffered for the address and removes them from the instance,
+// so transactions of a response lost on the way cannot be fetched again. That is why the request is retried
+// only when it did not reach the instance.
+func (c *Client) GetTransactions(ctx context.Context, address string) ([]model.Transaction, error) {
+	response := api.GetTransactionsResponse{}
+	if err := c.callOnce(ctx, http.MethodGet, "/api/new-transactions/"+url.PathEscape(address), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Transactions, nil
+}
+
+// GetDeadLetters returns webhook deliveries which failed after all retries, they are kept until DeleteDeadLetters.
+// It is retried like GetTransactions, because older instances removed dead letters when they were read.
+func (c *Client) GetDeadLetters(ctx context.Context, address string) ([]model.DeadLetter, error) {
+	response := api.GetDeadLettersResponse{}
+	if err := c.callOnce(ctx, http.MethodGet, "/api/dead-letters/"+url.PathEscape(address), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.DeadLetters, nil
+}
+
+// DeleteDeadLetters acknowledges dead letters of the subscription and returns their number.
+func (c *Client) DeleteDeadLetters(ctx context.Context, address string) (int, error) {
+	response := api.DeleteDeadLettersResponse{}
+	if err := c.call(ctx, http.MethodDelete, "/api/dead-letters/"+url.PathEscape(address), nil, &response); err != nil {
+		return 0, err
+	}
+	return response.Removed, nil
+}
+
+func (c *Client) GetUsage(ctx context.Context) (*api.GetUsageResponse, error) {
+	response := &api.GetUsageResponse{}
+	if err := c.call(ctx, http.MethodGet, "/api/usage", nil, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetHistory returns recorded transactions, zero fields of the query are not sent. It fails with ErrNotImplemented
+// when the instance does not keep the history.
+func (c *Client) GetHistory(ctx context.Context, query storage.HistoryQuery) ([]model.Transaction, error) {
+	values := url.Values{}
+	for name, value := range map[string]string{"address": query.Address, "hash": query.Hash} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	for name, value := range map[string]int{
+		"from_block": query.FromBlock, "to_block": query.ToBlock, "offset": query.Offset, "limit": query.Limit,
+	} {
+		if value != 0 {
+			values.Set(name, strconv.Itoa(value))
+		}
+	}
+	response := api.GetHistoryResponse{}
+	if err := c.call(ctx, http.MethodGet, "/api/history?"+values.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Transactions, nil
+}
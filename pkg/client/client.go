@@ -0,0 +1,212 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/api"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	defaultBackoff = 500 * time.Millisecond
+)
+
+// Options configure the client, the zero value talks to an instance without authentication and does not retry.
+type Options struct {
+	// APIKey identifies the tenant when authentication is enabled
+	APIKey string
+	// AdminKey is sent only to admin endpoints
+	AdminKey string
+	// HTTPClient defaults to a client with a 10 seconds timeout
+	HTTPClient *http.Client
+	// Retries is the number of retries of requests failed because of connection errors, rate limiting or unavailability,
+	// requests which could have been processed by the instance are retried only when it could not be connected
+	Retries int
+	// Backoff is the delay before the first retry, doubled for every next one, rate limited requests wait at least
+	// as long as the server asks in Retry-After
+	Backoff time.Duration
+}
+
+// Client calls the HTTP API of an etherscription instance, it is safe for concurrent use.
+type Client struct {
+	server  string
+	options Options
+}
+
+func New(server string, options Options) *Client {
+	if options.HTTPClient == nil {
+		options.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = defaultBackoff
+	}
+	return &Client{server: strings.TrimSuffix(server, "/"), options: options}
+}
+
+// call sends input encoded as JSON and decodes the response into output, error responses are returned as *Error.
+// Only GET requests are idempotent, other ones are retried only when they did not reach the instance.
+func (c *Client) call(ctx context.Context, method, path string, input, output any) error {
+	return c.retry(ctx, method == http.MethodGet, method, path, input, output)
+}
+
+// callOnce is used by GET requests whose response cannot be requested again, e.g. fetched transactions are removed
+// by the instance, so they are retried only when they did not reach the instance.
+func (c *Client) callOnce(ctx context.Context, method, path string, input, output any) error {
+	return c.retry(ctx, false, method, path, input, output)
+}
+
+func (c *Client) retry(ctx context.Context, idempotent bool, method, path string, input, output any) error {
+	var payload []byte
+	if input != nil {
+		var err error
+		if payload, err = json.Marshal(input); err != nil {
+			return fmt.Errorf("cannot encode request: %w", err)
+		}
+	}
+	backoff := c.options.Backoff
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, path, payload, output)
+		if attempt >= c.options.Retries || !retryable(err) || (!idempotent && !unprocessed(err)) {
+			return err
+		}
+		delay := backoff
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, output any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, c.server+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if strings.HasPrefix(path, "/admin/") {
+		request.Header.Set(api.AdminKeyHeader, c.options.AdminKey)
+	} else if c.options.APIKey != "" {
+		request.Header.Set(api.APIKeyHeader, c.options.APIKey)
+	}
+	response, err := c.options.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return newError(response)
+	}
+	if err := json.NewDecoder(response.Body).Decode(output); err != nil {
+		return fmt.Errorf("cannot decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// retryable reports errors after which the request was most likely not processed, so it can be sent again.
+func retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case http.StatusTooManyRequests:
+			// an exceeded quota is reported without Retry-After, it is not lifted by waiting
+			return apiErr.RetryAfter > 0
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// connection errors, the response was not received
+	var urlErr interface{ Timeout() bool }
+	return !errors.As(err, &urlErr) || !urlErr.Timeout()
+}
+
+// unprocessed reports errors which prove that the request was not processed: the instance could not be connected
+// or it rejected the request because of rate limiting. A request whose connection broke or which got 502, 503 or 504
+// from a proxy could have been processed anyway.
+func unprocessed(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Status == http.StatusTooManyRequests
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+var (
+	ErrBadRequest     = errors.New("bad request")
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrForbidden      = errors.New("forbidden")
+	ErrNotFound       = errors.New("not found")
+	ErrConflict       = errors.New("conflict")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrNotImplemented = errors.New("not implemented")
+	ErrServer         = errors.New("server error")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:            ErrBadRequest,
+	http.StatusRequestEntityTooLarge: ErrBadRequest,
+	http.StatusUnauthorized:          ErrUnauthorized,
+	http.StatusForbidden:             ErrForbidden,
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusConflict:              ErrConflict,
+	http.StatusTooManyRequests:       ErrRateLimited,
+	http.StatusNotImplemented:        ErrNotImplemented,
+}
+
+// Error is returned for responses with an error status, Title comes from api.JSONErrorResponse.
+// It matches one of Err* values with errors.Is, e.g. errors.Is(err, client.ErrNotFound).
+type Error struct {
+	Status int
+	Title  string
+	// RetryAfter is the delay requested by the server for rate limited requests
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("etherscription responded with %d: %s", e.Status, e.Title)
+}
+
+func (e *Error) Is(target error) bool {
+	if known, ok := statusErrors[e.Status]; ok {
+		return known == target
+	}
+	return target == ErrServer && e.Status >= http.StatusInternalServerError
+}
+
+func newError(response *http.Response) *Error {
+	apiErr := &Error{Status: response.StatusCode, Title: response.Status}
+	failure := api.JSONErrorResponse{}
+	if err := json.NewDecoder(response.Body).Decode(&failure); err == nil && failure.Error != nil {
+		apiErr.Title = failure.Error.Title
+	}
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
@@ -0,0 +1,348 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/internal/storage/memory"
+	"github.com/ziollek/etherscription/pkg/api"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/etherum"
+	"github.com/ziollek/etherscription/pkg/health"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/parser"
+	"github.com/ziollek/etherscription/pkg/storage"
+	"github.com/ziollek/etherscription/pkg/webhook"
+)
+
+const address = "0x00000000000000000000000000000000000000a1"
+
+type stubIngestion struct {
+	status etherum.Status
+}
+
+func (i *stubIngestion) Status() etherum.Status                         { return i.status }
+func (i *stubIngestion) Pause()                                         {}
+func (i *stubIngestion) Resume()                                        {}
+func (i *stubIngestion) RecreateFilter(context.Context) (string, error) { return "0x1", nil }
+
+func (i *stubIngestion) Backfill(from, to int) error {
+	i.status.Backfill = &etherum.Backfill{From: from, To: to, Next: from, Running: true}
+	return nil
+}
+
+// instance serves the real API on top of memory storages, consumer and state deliver transactions and blocks.
+type instance struct {
+	server   *httptest.Server
+	consumer *parser.TransactionConsumerService
+	state    parser.Consumer[int]
+}
+
+func newInstance(t *testing.T, modify func(cfg *config.Config)) *instance {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Webhook.Secret = "secret"
+	modify(cfg)
+	transactions := memory.NewListStorage[model.TracedTransaction]()
+	subscriptions := memory.NewKVStorage[model.Subscription]()
+	state := memory.NewKVStorage[int]()
+	tenants := parser.NewTenantService(memory.NewKVStorage[model.Tenant](), cfg.Auth)
+	subscriptionService := parser.NewSubscriptionService(
+		transactions, subscriptions, state, memory.NewListStorage[model.DeadLetter](), cfg.Quotas,
+	)
+	ingestion := &stubIngestion{status: etherum.Status{State: etherum.StateRunning}}
+	queue := func() (int, int) { return 0, 1 }
+	limiter := api.NewRateLimiter(cfg.Quotas)
+	guard, err := webhook.NewGuard(cfg.Webhook)
+	require.NoError(t, err)
+	server := httptest.NewServer(api.ConfigureRouting(
+		api.NewHandler(subscriptionService, guard, cfg.API, cfg.RPC, limiter),
+		api.NewAdminHandler(subscriptionService, tenants, limiter, nil),
+		api.NewHistoryHandler(nil),
+		api.NewHealthHandler(health.NewMonitor(cfg.Health, ingestion, queue, func() error { return nil })),
+		api.NewOperationsHandler(ingestion, transactions, queue, func() map[string]storage.Stats { return nil }),
+		api.NewAuthenticator(tenants, cfg.Auth),
+		limiter,
+	))
+	t.Cleanup(server.Close)
+	return &instance{
+		server:   server,
+		consumer: parser.NewConsumerService(transactions, subscriptions, tenants, nil, cfg.Storage, cfg.Quotas),
+		state:    parser.NewStateConsumerService(state),
+	}
+}
+
+func TestShouldSubscribeAndFetchTransactions(t *testing.T) {
+	instance := newInstance(t, func(*config.Config) {})
+	client := New(instance.server.URL, Options{})
+	ctx := context.Background()
+
+	created, err := client.Subscribe(ctx, api.SubscriptionsRequests{Address: address})
+	require.NoError(t, err)
+	require.True(t, created)
+	created, err = client.Subscribe(ctx, api.SubscriptionsRequests{Address: address})
+	require.NoError(t, err)
+	require.False(t, created)
+
+	instance.consumer.Consume(model.TracedTransaction{Transaction: model.Transaction{Hash: "0x01", From: address, To: "0x00000000000000000000000000000000000000a2", Value: 5}})
+	instance.state.Consume(42)
+	block, err := client.GetCurrentBlock(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 42, block)
+	subscription, err := client.GetSubscription(ctx, address)
+	require.NoError(t, err)
+	require.Equal(t, 1, subscription.BufferedTransactions)
+
+	transactions, err := client.GetTransactions(ctx, address)
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	require.Equal(t, "0x01", transactions[0].Hash)
+	transactions, err = client.GetTransactions(ctx, address)
+	require.NoError(t, err)
+	require.Empty(t, transactions)
+
+	page, err := client.GetSubscriptions(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, page.Total)
+	require.NoError(t, client.Unsubscribe(ctx, address))
+	require.ErrorIs(t, client.Unsubscribe(ctx, address), ErrNotFound)
+}
+
+func TestShouldSubscribeInBulk(t *testing.T) {
+	client := New(newInstance(t, func(*config.Config) {}).server.URL, Options{})
+
+	results, err := client.SubscribeBulk(context.Background(), []api.SubscriptionsRequests{{Address: address}, {Address: "0x01"}})
+
+	require.NoError(t, err)
+	require.Equal(t, []api.BulkResult{{Address: address, Status: true}, {Address: "0x01", Error: "Invalid address"}}, results)
+}
+
+func TestShouldMapErrorResponses(t *testing.T) {
+	withAuth := func(cfg *config.Config) {
+		cfg.Auth.Enabled = true
+		cfg.Auth.Tenants = []config.TenantConfig{{Name: "payments", APIKey: "payments-key"}}
+		cfg.Quotas.MaxSubscriptions = 1
+	}
+	tests := []struct {
+		name      string
+		options   Options
+		call      func(client *Client) error
+		wantErr   error
+		wantTitle string
+	}{
+		{
+			name:      "should report invalid API key",
+			options:   Options{APIKey: "wrong"},
+			call:      func(client *Client) error { _, err := client.GetUsage(context.Background()); return err },
+			wantErr:   ErrUnauthorized,
+			wantTitle: "Invalid or missing API key",
+		},
+		{
+			name:    "should report invalid request",
+			options: Options{APIKey: "payments-key"},
+			call: func(client *Client) error {
+				_, err := client.Subscribe(context.Background(), api.SubscriptionsRequests{Address: address, CallbackURL: "ftp://host"})
+				return err
+			},
+			wantErr:   ErrBadRequest,
+			wantTitle: "Invalid callback URL: callback URL must be an absolute http or https URL",
+		},
+		{
+			name:    "should reject invalid address",
+			options: Options{APIKey: "payments-key"},
+			call: func(client *Client) error {
+				_, err := client.Subscribe(context.Background(), api.SubscriptionsRequests{Address: "0x1"})
+				return err
+			},
+			wantErr:   ErrBadRequest,
+			wantTitle: "Invalid address",
+		},
+		{
+			name:    "should reject filter on status without receipts",
+			options: Options{APIKey: "payments-key"},
+			call: func(client *Client) error {
+				_, err := client.Subscribe(context.Background(), api.SubscriptionsRequests{
+					Address: address, Filter: &model.Filter{SuccessOnly: true},
+				})
+				return err
+			},
+			wantErr:   ErrBadRequest,
+			wantTitle: "Invalid filter: success_only requires rpc.fetch_receipts to be enabled",
+		},
+		{
+			name:    "should reject callback URL of internal network",
+			options: Options{APIKey: "payments-key"},
+			call: func(client *Client) error {
+				_, err := client.Subscribe(context.Background(), api.SubscriptionsRequests{
+					Address: address, CallbackURL: "http://169.254.169.254/latest/meta-data",
+				})
+				return err
+			},
+			wantErr:   ErrBadRequest,
+			wantTitle: "Invalid callback URL: callback URL points to a forbidden network",
+		},
+		{
+			name:    "should report exceeded quota",
+			options: Options{APIKey: "payments-key"},
+			call: func(client *Client) error {
+				if _, err := client.Subscribe(context.Background(), api.SubscriptionsRequests{Address: address}); err != nil {
+					return err
+				}
+				_, err := client.Subscribe(context.Background(), api.SubscriptionsRequests{Address: "0x00000000000000000000000000000000000000a2"})
+				return err
+			},
+			wantErr:   ErrRateLimited,
+			wantTitle: "Subscriptions quota exceeded",
+		},
+		{
+			name:    "should report missing history",
+			options: Options{APIKey: "payments-key"},
+			call: func(client *Client) error {
+				_, err := client.GetHistory(context.Background(), storage.HistoryQuery{})
+				return err
+			},
+			wantErr:   ErrNotImplemented,
+			wantTitle: "Transaction history is available only with sql storage backends",
+		},
+		{
+			name:      "should report disabled admin API",
+			call:      func(client *Client) error { _, err := client.GetIngestion(context.Background()); return err },
+			wantErr:   ErrForbidden,
+			wantTitle: "Admin API is disabled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := New(newInstance(t, withAuth).server.URL, tt.options)
+
+			err := tt.call(client)
+
+			require.ErrorIs(t, err, tt.wantErr)
+			var apiErr *Error
+			require.ErrorAs(t, err, &apiErr)
+			require.Equal(t, tt.wantTitle, apiErr.Title)
+		})
+	}
+}
+
+func TestShouldStartBackfillWithAdminKey(t *testing.T) {
+	instance := newInstance(t, func(cfg *config.Config) { cfg.Auth.AdminKey = "admin-key" })
+	client := New(instance.server.URL, Options{AdminKey: "admin-key"})
+
+	status, err := client.Backfill(context.Background(), 10, 20)
+
+	require.NoError(t, err)
+	require.Equal(t, &etherum.Backfill{From: 10, To: 20, Next: 10, Running: true}, status.Backfill)
+	_, err = New(instance.server.URL, Options{AdminKey: "wrong"}).Backfill(context.Background(), 10, 20)
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestShouldRetryUnavailableInstance(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		failures     int
+		wantErr      error
+		wantAttempts int
+	}{
+		{name: "should succeed after retries", retries: 2, failures: 2, wantAttempts: 3},
+		{name: "should give up when retries are exhausted", retries: 1, failures: 2, wantErr: ErrServer, wantAttempts: 2},
+		{name: "should not retry by default", failures: 1, wantErr: ErrServer, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				attempts++
+				if attempts <= tt.failures {
+					api.ErrorResponse(http.StatusServiceUnavailable, "Unavailable", w)
+					return
+				}
+				api.Response(w, http.StatusOK, api.GetCurrentBlocResponse{BlockID: 7})
+			}))
+			defer server.Close()
+			client := New(server.URL, Options{Retries: tt.retries, Backoff: time.Millisecond})
+
+			_, err := client.GetCurrentBlock(context.Background())
+
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestShouldNotRetryRejectedRequests(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts++
+		api.ErrorResponse(http.StatusNotFound, "There is no subscription for address", w)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, Options{Retries: 3, Backoff: time.Millisecond}).GetTransactions(context.Background(), address)
+
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, 1, attempts)
+}
+
+func TestShouldRetryRequestsWithSideEffectsOnlyWhenUnprocessed(t *testing.T) {
+	subscribe := func(c *Client) error {
+		_, err := c.Subscribe(context.Background(), api.SubscriptionsRequests{Address: address})
+		return err
+	}
+	fetch := func(c *Client) error {
+		_, err := c.GetTransactions(context.Background(), address)
+		return err
+	}
+	tests := []struct {
+		name         string
+		status       int
+		retryAfter   string
+		request      func(c *Client) error
+		wantErr      error
+		wantAttempts int
+	}{
+		{name: "should not retry fetching unavailable transactions", status: http.StatusServiceUnavailable, request: fetch, wantErr: ErrServer, wantAttempts: 1},
+		{name: "should not retry subscribing behind failing gateway", status: http.StatusBadGateway, request: subscribe, wantErr: ErrServer, wantAttempts: 1},
+		{name: "should retry rate limited subscribing", status: http.StatusTooManyRequests, retryAfter: "1", request: subscribe, wantErr: ErrRateLimited, wantAttempts: 3},
+		{name: "should not retry subscribing over quota", status: http.StatusTooManyRequests, request: subscribe, wantErr: ErrRateLimited, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				attempts++
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				api.ErrorResponse(tt.status, http.StatusText(tt.status), w)
+			}))
+			defer server.Close()
+
+			err := tt.request(New(server.URL, Options{Retries: 2, Backoff: time.Millisecond}))
+
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestShouldRetryFetchingTransactionsWhenInstanceCannotBeConnected(t *testing.T) {
+	dials := 0
+	transport := &http.Transport{DialContext: func(context.Context, string, string) (net.Conn, error) {
+		dials++
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}}
+	c := New("http://etherscription.test", Options{HTTPClient: &http.Client{Transport: transport}, Retries: 2, Backoff: time.Millisecond})
+
+	_, err := c.GetTransactions(context.Background(), address)
+
+	require.ErrorIs(t, err, syscall.ECONNREFUSED)
+	require.Equal(t, 3, dials)
+}
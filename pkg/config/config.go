@@ -1,20 +1,178 @@
 package config
 
-import "time"
+import (
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/logging"
+)
 
 type RPCConfig struct {
 	Timeout              time.Duration `yaml:"timeout"`
 	Interval             time.Duration `yaml:"interval"`
 	TooManyRequestsDelay time.Duration `yaml:"too_many_requests_delay"`
+	FetchReceipts        bool          `yaml:"fetch_receipts"`
+}
+
+// RedisConfig is used only by the redis backend, Prefix allows sharing one redis database between deployments.
+// Only the replica holding the ingestion lease fetches transactions, another one takes over within LeaseTTL
+// after the holder stopped renewing it.
+type RedisConfig struct {
+	Address  string        `yaml:"address"`
+	Password string        `yaml:"password"`
+	DB       int           `yaml:"db"`
+	Prefix   string        `yaml:"prefix"`
+	Timeout  time.Duration `yaml:"timeout"`
+	LeaseTTL time.Duration `yaml:"lease_ttl"`
+}
+
+// SQLConfig is used by sqlite and postgres backends, zero HistoryRetention keeps transaction history forever.
+type SQLConfig struct {
+	DSN              string        `yaml:"dsn"`
+	HistoryRetention time.Duration `yaml:"history_retention"`
+}
+
+// WALConfig makes memory backend durable, zero SyncInterval syncs the log after every write
+// and zero SnapshotInterval compacts the log only on shutdown. All storages and shards write to a single log,
+// records are buffered one at a time while the shard is locked, so with zero SyncInterval every modification
+// waits for syncs of the others.
+type WALConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Dir              string        `yaml:"dir"`
+	SyncInterval     time.Duration `yaml:"sync_interval"`
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"`
 }
 
+// LimitsConfig bounds every list of the memory backend, zero means no limit. MaxEntries counts entries
+// of all keys of a list, it is not a memory limit, the memory used depends on the size of entries.
+type LimitsConfig struct {
+	MaxEntries       int    `yaml:"max_entries"`
+	MaxEntriesPerKey int    `yaml:"max_entries_per_key"`
+	EvictionPolicy   string `yaml:"eviction_policy"`
+}
+
+// StorageConfig selects the storage backend, Path is used only by the bolt backend,
+// WAL, Shards and Limits only by the memory one, Redis only by the redis one and SQL by sqlite and postgres ones.
 type StorageConfig struct {
+	Backend              string        `yaml:"backend"`
+	Path                 string        `yaml:"path"`
+	WAL                  *WALConfig    `yaml:"wal"`
+	Shards               int           `yaml:"shards"`
+	Limits               *LimitsConfig `yaml:"limits"`
+	Redis                *RedisConfig  `yaml:"redis"`
+	SQL                  *SQLConfig    `yaml:"sql"`
 	Retention            time.Duration `yaml:"retention"`
 	CleanInterval        time.Duration `yaml:"clean_interval"`
 	StoreAllTransactions bool          `yaml:"store_all_transactions"`
 }
 
+// WebhookConfig controls deliveries to callback URLs. Callbacks to loopback, private and link-local networks
+// are rejected unless they belong to AllowedNetworks, DeniedNetworks are rejected even if they are allowed.
+type WebhookConfig struct {
+	Secret              string        `yaml:"secret"`
+	Timeout             time.Duration `yaml:"timeout"`
+	MaxRetries          int           `yaml:"max_retries"`
+	InitialBackoff      time.Duration `yaml:"initial_backoff"`
+	MaxBackoff          time.Duration `yaml:"max_backoff"`
+	QueueSize           int           `yaml:"queue_size"`
+	DeadLetterRetention time.Duration `yaml:"dead_letter_retention"`
+	AllowedNetworks     []string      `yaml:"allowed_networks"`
+	DeniedNetworks      []string      `yaml:"denied_networks"`
+}
+
+type APIConfig struct {
+	MaxBulkSize int `yaml:"max_bulk_size"`
+}
+
+type TenantConfig struct {
+	Name   string `yaml:"name"`
+	APIKey string `yaml:"api_key"`
+}
+
+type AuthConfig struct {
+	Enabled  bool           `yaml:"enabled"`
+	AdminKey string         `yaml:"admin_key"`
+	Tenants  []TenantConfig `yaml:"tenants"`
+}
+
+// QuotaLimits are applied per tenant, zero means no limit.
+// MaxBufferedTransactions limits transactions buffered for a single subscription.
+type QuotaLimits struct {
+	MaxSubscriptions        int     `yaml:"max_subscriptions"`
+	MaxBufferedTransactions int     `yaml:"max_buffered_transactions"`
+	RequestsPerSecond       float64 `yaml:"requests_per_second"`
+	Burst                   int     `yaml:"burst"`
+}
+
+type QuotaConfig struct {
+	QuotaLimits `yaml:",inline"`
+	// Tenants overrides default limits, only non-zero values are taken into account
+	Tenants map[string]QuotaLimits `yaml:"tenants"`
+}
+
+func (cfg *QuotaConfig) For(tenant string) QuotaLimits {
+	limits := cfg.QuotaLimits
+	if override, found := cfg.Tenants[tenant]; found {
+		if override.MaxSubscriptions != 0 {
+			limits.MaxSubscriptions = override.MaxSubscriptions
+		}
+		if override.MaxBufferedTransactions != 0 {
+			limits.MaxBufferedTransactions = override.MaxBufferedTransactions
+		}
+		if override.RequestsPerSecond != 0 {
+			limits.RequestsPerSecond = override.RequestsPerSecond
+		}
+		if override.Burst != 0 {
+			limits.Burst = override.Burst
+		}
+	}
+	return limits
+}
+
+// HealthConfig sets thresholds of health checks, zero disables a check.
+// Readiness fails when the storage is not available. Only when IngestionReadiness is enabled, it fails as well
+// when no RPC call succeeded for MaxRPCStaleness, the last processed block is more than MaxHeadLag blocks behind
+// the node or more than MaxQueueDepth transactions wait for the broker, otherwise they are only reported.
+// Liveness fails when no RPC call succeeded for LivenessStaleness, so a stuck instance gets restarted.
+type HealthConfig struct {
+	MaxRPCStaleness    time.Duration `yaml:"max_rpc_staleness"`
+	MaxHeadLag         int           `yaml:"max_head_lag"`
+	MaxQueueDepth      int           `yaml:"max_queue_depth"`
+	IngestionReadiness bool          `yaml:"ingestion_readiness"`
+	LivenessStaleness  time.Duration `yaml:"liveness_staleness"`
+}
+
+// SupervisorConfig controls restarting of failed components, the backoff doubles after every failure.
+// A component which fails more than MaxRestarts times in a row stops the service, negative value means no limit.
+// Failures are counted in a row only when the component fails within MaxBackoff from its start.
+type SupervisorConfig struct {
+	MaxRestarts    int           `yaml:"max_restarts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// TracingConfig enables exporting spans via OTLP over HTTP to Endpoint (host:port), Insecure disables TLS.
+// SampleRatio is a fraction of traces recorded, traces started by callers keep their sampling decision.
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Config is the effective configuration, see Load for how it is built.
 type Config struct {
-	Storage *StorageConfig `yaml:"storage"`
-	RPC     *RPCConfig     `yaml:"rpc"`
+	// Node is the URL of the ethereum node, Port is the port of the HTTP server
+	Node       string            `yaml:"node"`
+	Port       int               `yaml:"port"`
+	Storage    *StorageConfig    `yaml:"storage"`
+	RPC        *RPCConfig        `yaml:"rpc"`
+	Webhook    *WebhookConfig    `yaml:"webhook"`
+	API        *APIConfig        `yaml:"api"`
+	Auth       *AuthConfig       `yaml:"auth"`
+	Quotas     *QuotaConfig      `yaml:"quotas"`
+	Tracing    *TracingConfig    `yaml:"tracing"`
+	Health     *HealthConfig     `yaml:"health"`
+	Supervisor *SupervisorConfig `yaml:"supervisor"`
+	Logging    *logging.Config   `yaml:"logging"`
 }
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/logging"
+)
+
+const defaultPort = 8888
+
+// Default returns the configuration used when nothing is overridden, every section is present,
+// so the rest of the service never deals with missing ones. It matches configuration/config.yaml.
+func Default() *Config {
+	return &Config{
+		Port: defaultPort,
+		Storage: &StorageConfig{
+			Backend: "memory",
+			Path:    "./etherscription.db",
+			Shards:  32,
+			Limits:  &LimitsConfig{EvictionPolicy: "oldest_first"},
+			WAL: &WALConfig{
+				Dir:              "./etherscription-wal",
+				SyncInterval:     time.Second,
+				SnapshotInterval: 5 * time.Minute,
+			},
+			Redis: &RedisConfig{
+				Address:  "localhost:6379",
+				Prefix:   "etherscription",
+				Timeout:  time.Second,
+				LeaseTTL: 15 * time.Second,
+			},
+			SQL: &SQLConfig{
+				DSN:              "file:etherscription.sqlite?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)",
+				HistoryRetention: 720 * time.Hour,
+			},
+			Retention:            300 * time.Second,
+			CleanInterval:        3 * time.Second,
+			StoreAllTransactions: true,
+		},
+		RPC: &RPCConfig{
+			Timeout:              5 * time.Second,
+			Interval:             3 * time.Second,
+			TooManyRequestsDelay: 500 * time.Millisecond,
+		},
+		Webhook: &WebhookConfig{
+			Secret:              "",
+			Timeout:             5 * time.Second,
+			MaxRetries:          5,
+			InitialBackoff:      500 * time.Millisecond,
+			MaxBackoff:          30 * time.Second,
+			QueueSize:           100,
+			DeadLetterRetention: 24 * time.Hour,
+			AllowedNetworks:     []string{},
+			DeniedNetworks:      []string{},
+		},
+		API:  &APIConfig{MaxBulkSize: 10000},
+		Auth: &AuthConfig{Tenants: []TenantConfig{}},
+		Quotas: &QuotaConfig{
+			QuotaLimits: QuotaLimits{
+				MaxSubscriptions:        100000,
+				MaxBufferedTransactions: 10000,
+				RequestsPerSecond:       50,
+				Burst:                   100,
+			},
+			Tenants: map[string]QuotaLimits{},
+		},
+		Tracing: &TracingConfig{
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "etherscription",
+			SampleRatio: 1,
+		},
+		Health: &HealthConfig{
+			MaxRPCStaleness:   30 * time.Second,
+			MaxHeadLag:        20,
+			MaxQueueDepth:     900,
+			LivenessStaleness: 5 * time.Minute,
+		},
+		Supervisor: &SupervisorConfig{
+			MaxRestarts:    5,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+		},
+		Logging: &logging.Config{
+			Level:    "info",
+			Format:   logging.FormatConsole,
+			Output:   logging.OutputStdout,
+			Modules:  map[string]string{},
+			Sampling: map[string]uint32{},
+		},
+	}
+}
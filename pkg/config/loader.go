@@ -1,29 +1,113 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"reflect"
+	"strings"
 
-	"github.com/ziollek/etherscription/pkg/logging"
 	yaml "gopkg.in/yaml.v3"
 )
 
-const configPath = "./configuration/config.yaml"
+// EnvPrefix starts names of environment variables overriding options, the rest of the name is the path
+// of the option in upper case joined with underscores, e.g. ETHERSCRIPTION_STORAGE_REDIS_ADDRESS.
+const EnvPrefix = "ETHERSCRIPTION_"
 
-func LoadConfig(path string) (*Config, error) {
-	var appConfig Config
-	// Load configuration from file
-	yamlData, err := os.ReadFile(path)
-	// let's use the default path if a provided path does not exist
-	if err != nil && os.IsNotExist(err) {
-		logging.Logger().Warn().Str("module", "config").Msgf("Configuration file not found at %s, using default path %s", path, configPath)
-		yamlData, err = os.ReadFile(configPath)
+// Load builds the configuration from layers, each one overriding the previous one: defaults, the file at path,
+// environment variables and overrides, which are keyed by paths of options joined with dots, e.g. "rpc.timeout".
+// The file is skipped when path is empty, but a missing file is an error. Values of environment variables
+// and overrides are parsed as YAML, so maps and lists can be given as flow collections, e.g. "{parser: warn}".
+// The result is not validated, see Config.Validate.
+func Load(path string, overrides map[string]string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
 	}
-	if err != nil {
-		return nil, err
+	var errs []error
+	unknown := make(map[string]bool, len(overrides))
+	for key := range overrides {
+		unknown[key] = true
+	}
+	walk(reflect.ValueOf(cfg).Elem(), nil, func(path []string, value reflect.Value) {
+		if raw, ok := os.LookupEnv(envName(path)); ok {
+			if err := set(value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", envName(path), err))
+			}
+		}
+		key := strings.Join(path, ".")
+		if raw, ok := overrides[key]; ok {
+			delete(unknown, key)
+			if err := set(value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		}
+	})
+	for key := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown option", key))
 	}
-	if err = yaml.Unmarshal(yamlData, &appConfig); err != nil {
+	if err := errors.Join(errs...); err != nil {
 		return nil, err
 	}
-	logging.Logger().Info().Msgf("loaded config\n%s", string(yamlData))
-	return &appConfig, nil
+	return cfg, nil
+}
+
+// loadFile overrides defaults with options present in the file, unknown options are rejected to catch typos.
+func loadFile(path string, cfg *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot read configuration: %w", err)
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cannot parse configuration %s: %w", path, err)
+	}
+	return nil
+}
+
+// walk visits every option of the struct, nested sections are allocated when missing and inlined ones are flattened.
+func walk(value reflect.Value, path []string, visit func(path []string, value reflect.Value)) {
+	for i := 0; i < value.NumField(); i++ {
+		field, fieldValue := value.Type().Field(i), value.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if options == "inline" {
+			walk(fieldValue, path, visit)
+			continue
+		}
+		fieldPath := append(append([]string{}, path...), name)
+		if field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct {
+			if fieldValue.IsNil() {
+				fieldValue.Set(reflect.New(field.Type.Elem()))
+			}
+			walk(fieldValue.Elem(), fieldPath, visit)
+			continue
+		}
+		visit(fieldPath, fieldValue)
+	}
+}
+
+// set replaces the option, strings are taken as they are, so they do not have to be quoted.
+func set(value reflect.Value, raw string) error {
+	if value.Kind() == reflect.String {
+		value.SetString(raw)
+		return nil
+	}
+	parsed := reflect.New(value.Type())
+	if err := yaml.Unmarshal([]byte(raw), parsed.Interface()); err != nil {
+		return err
+	}
+	value.Set(parsed.Elem())
+	return nil
+}
+
+func envName(path []string) string {
+	return EnvPrefix + strings.ToUpper(strings.Join(path, "_"))
 }
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReloadResult lists options changed by a reload, RestartRequired ones keep their previous values until restart.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+type reloadHandler struct {
+	options []string
+	apply   func(cfg *Config)
+}
+
+// Reloader loads the configuration again and applies changes of options which running components can take over.
+type Reloader struct {
+	load     func() (*Config, error)
+	current  *Config
+	handlers []reloadHandler
+	mutex    sync.Mutex
+}
+
+// NewReloader starts with the configuration in use, load has to return a validated configuration.
+func NewReloader(current *Config, load func() (*Config, error)) *Reloader {
+	return &Reloader{current: current, load: load}
+}
+
+// OnChange registers apply to be called with the new configuration when any of options changes,
+// an option given as a section, e.g. "logging", covers all options of the section.
+func (r *Reloader) OnChange(apply func(cfg *Config), options ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.handlers = append(r.handlers, reloadHandler{options: options, apply: apply})
+}
+
+// Reload applies changed options which have a handler, other changed options are reported as requiring a restart.
+// Nothing is changed when the configuration cannot be loaded.
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	cfg, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	result := &ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	triggered := make([]bool, len(r.handlers))
+	for _, option := range Changes(r.current, cfg) {
+		handled := false
+		for i, handler := range r.handlers {
+			if covers(handler.options, option) {
+				handled, triggered[i] = true, true
+			}
+		}
+		if handled {
+			result.Applied = append(result.Applied, option)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, option)
+		}
+	}
+	// options requiring a restart are still in effect with previous values
+	restore(cfg, r.current, result.RestartRequired)
+	for i, handler := range r.handlers {
+		if triggered[i] {
+			handler.apply(cfg)
+		}
+	}
+	r.current = cfg
+	return result, nil
+}
+
+// Changes returns sorted paths of options which differ between configurations.
+func Changes(previous, next *Config) []string {
+	previousOptions := options(previous)
+	var changes []string
+	for path, value := range options(next) {
+		if !reflect.DeepEqual(previousOptions[path].Interface(), value.Interface()) {
+			changes = append(changes, path)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+func options(cfg *Config) map[string]reflect.Value {
+	values := map[string]reflect.Value{}
+	walk(reflect.ValueOf(cfg).Elem(), nil, func(path []string, value reflect.Value) {
+		values[strings.Join(path, ".")] = value
+	})
+	return values
+}
+
+// restore sets options of cfg to values from previous.
+func restore(cfg, previous *Config, paths []string) {
+	previousOptions, cfgOptions := options(previous), options(cfg)
+	for _, path := range paths {
+		cfgOptions[path].Set(previousOptions[path])
+	}
+}
+
+func covers(options []string, option string) bool {
+	for _, covered := range options {
+		if option == covered || strings.HasPrefix(option, covered+".") {
+			return true
+		}
+	}
+	return false
+}
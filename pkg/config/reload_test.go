@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldApplyOnlyChangedOptionsWithHandlers(t *testing.T) {
+	tests := []struct {
+		name          string
+		modify        func(cfg *Config)
+		wantApplied   []string
+		wantRestart   []string
+		wantIntervals []time.Duration
+		wantLogLevels []string
+	}{
+		{
+			"Should apply interval",
+			func(cfg *Config) { cfg.RPC.Interval = time.Second },
+			[]string{"rpc.interval"},
+			[]string{},
+			[]time.Duration{time.Second},
+			nil,
+		},
+		{
+			"Should apply whole section",
+			func(cfg *Config) {
+				cfg.Logging.Level, cfg.Logging.Modules = "debug", map[string]string{"parser": "warn"}
+			},
+			[]string{"logging.level", "logging.modules"},
+			[]string{},
+			nil,
+			[]string{"debug"},
+		},
+		{
+			"Should keep options requiring restart",
+			func(cfg *Config) { cfg.Storage.Backend, cfg.Storage.Retention = "redis", time.Minute },
+			[]string{},
+			[]string{"storage.backend", "storage.retention"},
+			nil, nil,
+		},
+		{
+			"Should apply nothing without changes",
+			func(*Config) {},
+			[]string{},
+			[]string{},
+			nil, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reloader := NewReloader(Default(), func() (*Config, error) {
+				cfg := Default()
+				tt.modify(cfg)
+				return cfg, nil
+			})
+			var intervals []time.Duration
+			var levels []string
+			reloader.OnChange(func(cfg *Config) { intervals = append(intervals, cfg.RPC.Interval) }, "rpc.interval")
+			reloader.OnChange(func(cfg *Config) { levels = append(levels, cfg.Logging.Level) }, "logging")
+
+			result, err := reloader.Reload()
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantApplied, result.Applied)
+			require.Equal(t, tt.wantRestart, result.RestartRequired)
+			require.Equal(t, tt.wantIntervals, intervals)
+			require.Equal(t, tt.wantLogLevels, levels)
+			// options requiring a restart keep previous values
+			require.Equal(t, 300*time.Second, reloader.current.Storage.Retention)
+			require.Equal(t, "memory", reloader.current.Storage.Backend)
+		})
+	}
+}
+
+func TestShouldKeepConfigurationWhenReloadFails(t *testing.T) {
+	current := Default()
+	errLoad := errors.New("invalid configuration")
+	reloader := NewReloader(current, func() (*Config, error) { return nil, errLoad })
+	reloader.OnChange(func(*Config) { t.Fatal("handler should not be called") }, "rpc")
+
+	_, err := reloader.Reload()
+
+	require.ErrorIs(t, err, errLoad)
+	require.Same(t, current, reloader.current)
+}
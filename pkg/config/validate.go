@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/model"
+)
+
+// publicWebhookSecret was shipped as the default secret in examples.
+const publicWebhookSecret = "change-me"
+
+// violations collects errors of options, each one is prefixed by the path of the option.
+type violations []error
+
+func (v *violations) add(path, format string, args ...any) {
+	*v = append(*v, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (v *violations) positive(path string, value time.Duration) {
+	if value <= 0 {
+		v.add(path, "must be a positive duration, got %s", value)
+	}
+}
+
+func (v *violations) notNegative(path string, value time.Duration) {
+	if value < 0 {
+		v.add(path, "must not be a negative duration, got %s", value)
+	}
+}
+
+func (v *violations) required(path, value string) {
+	if value == "" {
+		v.add(path, "is required")
+	}
+}
+
+func (v *violations) networks(path string, networks []string) {
+	for i, network := range networks {
+		if _, err := netip.ParsePrefix(network); err != nil {
+			v.add(fmt.Sprintf("%s[%d]", path, i), "must be a network in CIDR notation, got %q", network)
+		}
+	}
+}
+
+// Validate reports all invalid options at once, so they can be fixed in one go.
+// It does not check options validated by components using them, e.g. the name of the storage backend.
+func (cfg *Config) Validate() error {
+	var v violations
+	v.required("node", cfg.Node)
+	if cfg.Node != "" {
+		if parsed, err := url.Parse(cfg.Node); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			v.add("node", "must be an absolute URL, got %q", maskURL(cfg.Node))
+		}
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		v.add("port", "must be between 1 and 65535, got %d", cfg.Port)
+	}
+
+	storage := cfg.Storage
+	v.positive("storage.retention", storage.Retention)
+	v.positive("storage.clean_interval", storage.CleanInterval)
+	if storage.Shards < 0 {
+		v.add("storage.shards", "must not be negative, got %d", storage.Shards)
+	}
+	if storage.Limits.MaxEntries < 0 || storage.Limits.MaxEntriesPerKey < 0 {
+		v.add("storage.limits", "limits must not be negative")
+	}
+	if storage.WAL.Enabled {
+		v.required("storage.wal.dir", storage.WAL.Dir)
+	}
+	v.notNegative("storage.wal.sync_interval", storage.WAL.SyncInterval)
+	v.notNegative("storage.wal.snapshot_interval", storage.WAL.SnapshotInterval)
+	switch storage.Backend {
+	case "bolt":
+		v.required("storage.path", storage.Path)
+	case "redis":
+		v.required("storage.redis.address", storage.Redis.Address)
+		v.positive("storage.redis.timeout", storage.Redis.Timeout)
+		v.positive("storage.redis.lease_ttl", storage.Redis.LeaseTTL)
+	case "sqlite", "postgres":
+		v.required("storage.sql.dsn", storage.SQL.DSN)
+	}
+	v.notNegative("storage.sql.history_retention", storage.SQL.HistoryRetention)
+
+	v.positive("rpc.timeout", cfg.RPC.Timeout)
+	v.positive("rpc.interval", cfg.RPC.Interval)
+	v.notNegative("rpc.too_many_requests_delay", cfg.RPC.TooManyRequestsDelay)
+
+	// the secret from examples is public, so anyone could forge requests signed with it
+	if cfg.Webhook.Secret == publicWebhookSecret {
+		v.add("webhook.secret", "must be changed, %q is publicly known", publicWebhookSecret)
+	}
+	v.positive("webhook.timeout", cfg.Webhook.Timeout)
+	v.positive("webhook.initial_backoff", cfg.Webhook.InitialBackoff)
+	v.positive("webhook.max_backoff", cfg.Webhook.MaxBackoff)
+	v.positive("webhook.dead_letter_retention", cfg.Webhook.DeadLetterRetention)
+	if cfg.Webhook.MaxRetries < 0 {
+		v.add("webhook.max_retries", "must not be negative, got %d", cfg.Webhook.MaxRetries)
+	}
+	if cfg.Webhook.QueueSize < 1 {
+		v.add("webhook.queue_size", "must be positive, got %d", cfg.Webhook.QueueSize)
+	}
+	v.networks("webhook.allowed_networks", cfg.Webhook.AllowedNetworks)
+	v.networks("webhook.denied_networks", cfg.Webhook.DeniedNetworks)
+
+	if cfg.API.MaxBulkSize < 1 {
+		v.add("api.max_bulk_size", "must be positive, got %d", cfg.API.MaxBulkSize)
+	}
+	validateTenants(&v, cfg.Auth.Tenants)
+	validateQuotas(&v, "quotas", cfg.Quotas.QuotaLimits)
+	for tenant, limits := range cfg.Quotas.Tenants {
+		validateQuotas(&v, "quotas.tenants."+tenant, limits)
+	}
+
+	if cfg.Tracing.Enabled {
+		v.required("tracing.endpoint", cfg.Tracing.Endpoint)
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		v.add("tracing.sample_ratio", "must be between 0 and 1, got %g", cfg.Tracing.SampleRatio)
+	}
+
+	v.notNegative("health.max_rpc_staleness", cfg.Health.MaxRPCStaleness)
+	v.notNegative("health.liveness_staleness", cfg.Health.LivenessStaleness)
+	v.notNegative("supervisor.initial_backoff", cfg.Supervisor.InitialBackoff)
+	v.notNegative("supervisor.max_backoff", cfg.Supervisor.MaxBackoff)
+	if err := cfg.Logging.Validate(); err != nil {
+		v.add("logging", "%s", err)
+	}
+	return errors.Join(v...)
+}
+
+// validateTenants requires unique names usable in storage keys and unique API keys, which are not reported,
+// so they do not end up in logs.
+func validateTenants(v *violations, tenants []TenantConfig) {
+	names := make(map[string]int, len(tenants))
+	apiKeys := make(map[string]int, len(tenants))
+	for i, tenant := range tenants {
+		path := fmt.Sprintf("auth.tenants[%d]", i)
+		v.required(path+".name", tenant.Name)
+		v.required(path+".api_key", tenant.APIKey)
+		if tenant.Name != "" && !model.IsValidTenantName(tenant.Name) {
+			v.add(path+".name", "must not contain %q, got %q", "/", tenant.Name)
+		}
+		if first, found := names[tenant.Name]; found && tenant.Name != "" {
+			v.add(path+".name", "must be unique, %q is already used by auth.tenants[%d]", tenant.Name, first)
+		} else {
+			names[tenant.Name] = i
+		}
+		if first, found := apiKeys[tenant.APIKey]; found && tenant.APIKey != "" {
+			v.add(path+".api_key", "must be unique, the same key is used by auth.tenants[%d]", first)
+		} else {
+			apiKeys[tenant.APIKey] = i
+		}
+	}
+}
+
+func validateQuotas(v *violations, path string, limits QuotaLimits) {
+	if limits.MaxSubscriptions < 0 || limits.MaxBufferedTransactions < 0 || limits.Burst < 0 {
+		v.add(path, "limits must not be negative")
+	}
+}
@@ -0,0 +1,46 @@
+package etherum
+
+import (
+	"context"
+	"fmt"
+)
+
+// zeroHash is a hash of no transaction, asking for it checks whether a method is supported without depending on the chain.
+const zeroHash = "0x0000000000000000000000000000000000000000000000000000000000000000"
+
+// MethodCheck is the outcome of calling a JSON-RPC method, Required methods are used by every instance,
+// the other ones only by some features.
+type MethodCheck struct {
+	Method   string
+	Required bool
+	Feature  string
+	Err      error
+}
+
+func (check MethodCheck) Supported() bool {
+	return check.Err == nil
+}
+
+// CheckMethods calls every JSON-RPC method used by the service, so an unsupported node is detected before serving,
+// e.g. many public nodes reject eth_newFilter.
+func (c *RPCClient) CheckMethods(ctx context.Context) []MethodCheck {
+	head, headErr := c.getBlockNumber(ctx)
+	checks := []MethodCheck{{Method: blockNumber, Required: true, Err: headErr}}
+	filterID, err := c.createFilter(ctx)
+	checks = append(checks, MethodCheck{Method: createFilter, Required: true, Err: err})
+	if err == nil {
+		_, err = c.getChanges(ctx, filterID)
+	} else {
+		err = fmt.Errorf("cannot be checked without a filter: %w", err)
+	}
+	checks = append(checks, MethodCheck{Method: getFilterChanges, Required: true, Err: err})
+	_, err = c.getTransaction(ctx, zeroHash)
+	checks = append(checks, MethodCheck{Method: getTransaction, Required: true, Err: err})
+	_, err = c.getReceipt(ctx, zeroHash)
+	checks = append(checks, MethodCheck{Method: getReceipt, Feature: "rpc.fetch_receipts", Err: err})
+	err = headErr
+	if err == nil {
+		_, err = c.getLogs(ctx, head, head)
+	}
+	return append(checks, MethodCheck{Method: getLogs, Feature: "backfill", Err: err})
+}
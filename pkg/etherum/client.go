@@ -2,26 +2,38 @@ package etherum
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/ziollek/etherscription/pkg/config"
 	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/metrics"
 	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 const (
 	createFilter     = "eth_newFilter"
 	getFilterChanges = "eth_getFilterChanges"
 	getTransaction   = "eth_getTransactionByHash"
+	getReceipt       = "eth_getTransactionReceipt"
+	blockNumber      = "eth_blockNumber"
+	getLogs          = "eth_getLogs"
 	rpcID            = 111
 	rpcVersion       = "2.0"
 	startBlock       = "latest"
 )
 
+var ErrTransactionNotFound = errors.New("transaction not found")
+
 type createFilterRequest struct {
 	FromBlock string `json:"fromBlock,omitempty"`
 	ToBlock   string `json:"toBlock,omitempty"`
@@ -51,6 +63,10 @@ type jsonRPCResponse[T any] struct {
 	Error   *rpcError `json:"error"`
 }
 
+func (response *jsonRPCResponse[T]) failed() bool {
+	return response.Error != nil
+}
+
 func (response *jsonRPCResponse[T]) ToResponse() (T, error) {
 	if response.Error != nil {
 		return response.Result, response.Error.ToError()
@@ -71,6 +87,8 @@ type RPCClient struct {
 	node          string
 	httpClient    *http.Client
 	slowDownDelay time.Duration
+	// lastSuccess is the unix time in nanoseconds of the last call answered without an error
+	lastSuccess atomic.Int64
 }
 
 func NewRPCClient(node string, cfg *config.RPCConfig) *RPCClient {
@@ -83,37 +101,97 @@ func NewRPCClient(node string, cfg *config.RPCConfig) *RPCClient {
 	}
 }
 
-func (c *RPCClient) createFilter() (string, error) {
+// LastSuccess returns the time of the last successful call, zero time means that no call succeeded yet.
+func (c *RPCClient) LastSuccess() time.Time {
+	if last := c.lastSuccess.Load(); last > 0 {
+		return time.Unix(0, last)
+	}
+	return time.Time{}
+}
+
+func (c *RPCClient) createFilter(ctx context.Context) (string, error) {
 	result := jsonRPCResponse[string]{}
-	if err := c.makeCall(createFilter, []createFilterRequest{{FromBlock: startBlock}}, &result); err != nil {
+	if err := c.makeCall(ctx, createFilter, []createFilterRequest{{FromBlock: startBlock}}, &result); err != nil {
 		return "", err
 	}
 	return result.ToResponse()
 }
 
-func (c *RPCClient) getChanges(filterID string) (logEntries, error) {
+func (c *RPCClient) getChanges(ctx context.Context, filterID string) (logEntries, error) {
+	result := jsonRPCResponse[logEntries]{}
+	if err := c.makeCall(ctx, getFilterChanges, []string{filterID}, &result); err != nil {
+		return nil, err
+	}
+	return result.ToResponse()
+}
+
+// getLogs returns log entries of blocks from the range, both ends are included.
+func (c *RPCClient) getLogs(ctx context.Context, from, to int) (logEntries, error) {
 	result := jsonRPCResponse[logEntries]{}
-	if err := c.makeCall(getFilterChanges, []string{filterID}, &result); err != nil {
+	params := []createFilterRequest{{FromBlock: model.ConvertIntToHex(from), ToBlock: model.ConvertIntToHex(to)}}
+	if err := c.makeCall(ctx, getLogs, params, &result); err != nil {
 		return nil, err
 	}
 	return result.ToResponse()
 }
 
-func (c *RPCClient) getTransaction(hash string) (*model.RawTransaction, error) {
+func (c *RPCClient) getTransaction(ctx context.Context, hash string) (*model.RawTransaction, error) {
 	result := jsonRPCResponse[*model.RawTransaction]{}
-	if err := c.makeCall(getTransaction, []string{hash}, &result); err != nil {
+	if err := c.makeCall(ctx, getTransaction, []string{hash}, &result); err != nil {
 		return nil, fmt.Errorf("error while read body from HTTP response: %w", err)
 	}
 	return result.ToResponse()
 }
 
-func (c *RPCClient) makeCall(method string, input, output interface{}) error {
+func (c *RPCClient) getReceipt(ctx context.Context, hash string) (*model.RawReceipt, error) {
+	result := jsonRPCResponse[*model.RawReceipt]{}
+	if err := c.makeCall(ctx, getReceipt, []string{hash}, &result); err != nil {
+		return nil, fmt.Errorf("error while read body from HTTP response: %w", err)
+	}
+	return result.ToResponse()
+}
+
+func (c *RPCClient) getBlockNumber(ctx context.Context) (int, error) {
+	result := jsonRPCResponse[string]{}
+	if err := c.makeCall(ctx, blockNumber, []string{}, &result); err != nil {
+		return 0, err
+	}
+	number, err := result.ToResponse()
+	return int(model.ConvertHexToInt(number)), err
+}
+
+// makeCall records the outcome of every call, errors reported by the node in the response count as rpc_error.
+func (c *RPCClient) makeCall(ctx context.Context, method string, input, output interface{}) error {
+	ctx, span := tracing.Start(ctx, "rpc "+method, attribute.String("rpc.method", method))
+	start := time.Now()
+	err := c.call(ctx, method, input, output)
+	metrics.RPCDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	} else if response, ok := output.(interface{ failed() bool }); ok && response.failed() {
+		outcome = "rpc_error"
+	}
+	metrics.RPCRequests.WithLabelValues(method, outcome).Inc()
+	if outcome == "success" {
+		c.lastSuccess.Store(time.Now().UnixNano())
+	}
+	span.SetAttributes(attribute.String("rpc.outcome", outcome))
+	if outcome == "rpc_error" {
+		span.SetStatus(codes.Error, outcome)
+	}
+	tracing.Fail(span, err)
+	span.End()
+	return err
+}
+
+func (c *RPCClient) call(ctx context.Context, method string, input, output interface{}) error {
 	payload, err := newEncodedJSONRPCRequest(method, input)
-	logging.Logger().Debug().Str("payload", string(payload)).Msgf("payload for %s", method)
+	logging.Module("etherum").Debug().Str("payload", string(payload)).Msgf("payload for %s", method)
 	if err != nil {
 		return fmt.Errorf("error while encoding JSON RPC request: %w", err)
 	}
-	request, err := http.NewRequest("POST", c.node, bytes.NewBuffer(payload))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.node, bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("error while creating HTTP request: %w", err)
 	}
@@ -123,10 +201,10 @@ func (c *RPCClient) makeCall(method string, input, output interface{}) error {
 		return fmt.Errorf("error while making HTTP request: %w", err)
 	}
 	if resp.StatusCode == 429 {
-		logging.Logger().Warn().Str("response", resp.Status).Msgf("rate limit reached, trying slow down")
+		logging.Module("etherum").Warn().Str("response", resp.Status).Msgf("rate limit reached, trying slow down")
 		time.Sleep(c.slowDownDelay)
 		resp.Body.Close()
-		request, err = http.NewRequest("POST", c.node, bytes.NewBuffer(payload))
+		request, err = http.NewRequestWithContext(ctx, http.MethodPost, c.node, bytes.NewBuffer(payload))
 		if err != nil {
 			return fmt.Errorf("error while creating HTTP request: %w", err)
 		}
@@ -144,10 +222,32 @@ func (c *RPCClient) makeCall(method string, input, output interface{}) error {
 	if err != nil {
 		return fmt.Errorf("error while read body from HTTP response: %w", err)
 	}
-	logging.Logger().Debug().Str("response", string(body)).Msgf("response fetched for %s", createFilter)
+	logging.Module("etherum").Debug().Str("response", string(body)).Msgf("response fetched for %s", createFilter)
 	err = json.Unmarshal(body, &output)
 	if err != nil {
 		return fmt.Errorf("error while unmarshall body from HTTP response: %w", err)
 	}
 	return nil
 }
+
+// Transaction fetches the transaction together with its receipt and maps it like the fetcher does,
+// the status stays unknown when the transaction is not mined yet.
+func (c *RPCClient) Transaction(ctx context.Context, hash string) (model.Transaction, error) {
+	raw, err := c.getTransaction(ctx, hash)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	if raw == nil {
+		return model.Transaction{}, ErrTransactionNotFound
+	}
+	transaction := raw.ToTransaction()
+	receipt, err := c.getReceipt(ctx, hash)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	if receipt != nil {
+		success := receipt.IsSuccessful()
+		transaction.Success = &success
+	}
+	return transaction, nil
+}
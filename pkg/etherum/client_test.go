@@ -0,0 +1,95 @@
+package etherum
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/model"
+)
+
+// fakeNode answers JSON-RPC calls with raw results by method, methods without a result are rejected like unsupported ones.
+func fakeNode(t *testing.T, results map[string]string) *RPCClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := jsonRPCRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		result, ok := results[request.Method]
+		if !ok {
+			_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 111, "error": {"code": -32601, "message": "method not found"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 111, "result": ` + result + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return NewRPCClient(server.URL, &config.RPCConfig{Timeout: time.Second})
+}
+
+func TestShouldCheckMethods(t *testing.T) {
+	client := fakeNode(t, map[string]string{
+		blockNumber:      `"0x10"`,
+		getFilterChanges: `[]`,
+		getTransaction:   `null`,
+		getReceipt:       `null`,
+		getLogs:          `[]`,
+	})
+
+	checks := client.CheckMethods(context.Background())
+
+	supported := map[string]bool{}
+	for _, check := range checks {
+		supported[check.Method] = check.Supported()
+	}
+	require.Equal(t, map[string]bool{
+		blockNumber:      true,
+		createFilter:     false,
+		getFilterChanges: false,
+		getTransaction:   true,
+		getReceipt:       true,
+		getLogs:          true,
+	}, supported)
+	require.ErrorContains(t, checks[1].Err, "method not found")
+	require.True(t, checks[1].Required)
+}
+
+func TestShouldFetchTransaction(t *testing.T) {
+	success := true
+	tests := []struct {
+		name        string
+		transaction string
+		receipt     string
+		want        model.Transaction
+		wantErr     error
+	}{
+		{
+			name:        "should map mined transaction",
+			transaction: `{"hash": "0x01", "blockNumber": "0x10", "from": "0xa1", "to": "0xa2", "value": "0x5", "input": "0xa9059cbb00"}`,
+			receipt:     `{"status": "0x1"}`,
+			want: model.Transaction{
+				Hash: "0x01", BlockNumber: 16, From: "0xa1", To: "0xa2", Value: 5, MethodSelector: "0xa9059cbb", Success: &success,
+			},
+		},
+		{
+			name:        "should keep unknown status of pending transaction",
+			transaction: `{"hash": "0x01", "from": "0xa1", "to": "0xa2", "value": "0x0"}`,
+			receipt:     `null`,
+			want:        model.Transaction{Hash: "0x01", From: "0xa1", To: "0xa2"},
+		},
+		{name: "should report missing transaction", transaction: `null`, receipt: `null`, wantErr: ErrTransactionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeNode(t, map[string]string{getTransaction: tt.transaction, getReceipt: tt.receipt})
+
+			transaction, err := client.Transaction(context.Background(), "0x01")
+
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.want, transaction)
+		})
+	}
+}
@@ -2,75 +2,374 @@ package etherum
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ziollek/etherscription/pkg/config"
 	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/metrics"
 	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+const (
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateStopped  = "stopped"
+	StateFailed   = "failed"
+	StatePaused   = "paused"
+	// StateStandby means that another replica sharing the storage fetches transactions
+	StateStandby = "standby"
+)
+
+const (
+	// backfillChunk is the number of blocks whose logs are requested at once
+	backfillChunk    = 100
+	maxBackfillRange = 10000
+)
+
+var (
+	ErrNotRunning      = errors.New("fetcher is not running")
+	ErrBackfillRunning = errors.New("backfill is already running")
+	ErrInvalidRange    = fmt.Errorf("invalid range of blocks, at most %d blocks can be backfilled at once", maxBackfillRange)
+)
+
+// Status describes progress of the fetcher, HeadBlock is the latest block reported by the node.
+type Status struct {
+	State     string    `json:"state"`
+	LastRPCAt time.Time `json:"last_rpc_at"`
+	LastBlock int       `json:"last_block"`
+	HeadBlock int       `json:"head_block"`
+	FilterID  string    `json:"filter_id"`
+	// Backfill describes the last backfill, it is nil when none has been started
+	Backfill *Backfill `json:"backfill,omitempty"`
+}
+
+// Backfill describes progress of fetching transactions of past blocks, Next is the first block not fetched yet.
+type Backfill struct {
+	From         int    `json:"from"`
+	To           int    `json:"to"`
+	Next         int    `json:"next"`
+	Transactions int    `json:"transactions"`
+	Running      bool   `json:"running"`
+	Error        string `json:"error,omitempty"`
+}
+
 type Fetcher struct {
-	interval   time.Duration
-	client     *RPCClient
-	lastBlock  int
-	txChan     chan<- model.Transaction
-	blocksChan chan<- int
+	interval time.Duration
+	// reset notifies the running fetcher that the interval has changed
+	reset         chan struct{}
+	fetchReceipts bool
+	client        *RPCClient
+	lastBlock     int
+	txChan        chan<- model.TracedTransaction
+	blocksChan    chan<- int
+	paused        atomic.Bool
+	// status is read by health checks from other goroutines
+	status   Status
+	backfill *Backfill
+	// running is the context of the running fetcher, it is nil when the fetcher does not run
+	running   context.Context
+	backfills sync.WaitGroup
+	mutex     sync.RWMutex
 }
 
-func NewFetcher(interval time.Duration, client *RPCClient, txChan chan<- model.Transaction, blocksChan chan<- int) *Fetcher {
+func NewFetcher(cfg *config.RPCConfig, client *RPCClient, txChan chan<- model.TracedTransaction, blocksChan chan<- int) *Fetcher {
+	metrics.FetcherState.WithLabelValues(StateStarting).Set(1)
 	return &Fetcher{
-		lastBlock:  0,
-		interval:   interval,
-		client:     client,
-		txChan:     txChan,
-		blocksChan: blocksChan,
+		lastBlock:     0,
+		interval:      cfg.Interval,
+		reset:         make(chan struct{}, 1),
+		fetchReceipts: cfg.FetchReceipts,
+		client:        client,
+		txChan:        txChan,
+		blocksChan:    blocksChan,
+		status:        Status{State: StateStarting},
+	}
+}
+
+func (f *Fetcher) Status() Status {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	status := f.status
+	status.LastRPCAt = f.client.LastSuccess()
+	if f.backfill != nil {
+		backfill := *f.backfill
+		status.Backfill = &backfill
+	}
+	return status
+}
+
+// SetInterval changes how often the node is polled, a running fetcher applies it immediately.
+func (f *Fetcher) SetInterval(interval time.Duration) {
+	f.mutex.Lock()
+	f.interval = interval
+	f.mutex.Unlock()
+	select {
+	case f.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (f *Fetcher) currentInterval() time.Duration {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.interval
+}
+
+func (f *Fetcher) updateStatus(update func(status *Status)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	previous := f.status.State
+	update(&f.status)
+	if f.status.State != previous {
+		metrics.FetcherState.WithLabelValues(previous).Set(0)
+		metrics.FetcherState.WithLabelValues(f.status.State).Set(1)
 	}
 }
 
+// Start polls the node until ctx is done, channels are not closed, so the fetcher can be restarted
+// and the consumer has to stop on its own, see Broker.Start. A running backfill is stopped along with the fetcher.
 func (f *Fetcher) Start(ctx context.Context) error {
-	// producer should close channels
-	defer close(f.txChan)
-	defer close(f.blocksChan)
-	filterID, err := f.client.createFilter()
-	if err != nil {
-		logging.Logger().Err(err).Str("module", "etherum").Msg("Cannot create filter")
+	if _, err := f.RecreateFilter(ctx); err != nil {
+		f.updateStatus(func(status *Status) { status.State = StateFailed })
 		return err
 	}
-	logging.Logger().Info().Str("module", "etherum").Msgf("Filter %s created", filterID)
+	f.updateStatus(func(status *Status) { status.State = f.runningState() })
+	running, stop := context.WithCancel(ctx)
+	f.mutex.Lock()
+	f.running = running
+	f.mutex.Unlock()
+	defer func() {
+		f.mutex.Lock()
+		f.running = nil
+		f.mutex.Unlock()
+		stop()
+		f.backfills.Wait()
+	}()
 
-	ticker := time.NewTicker(f.interval)
+	ticker := time.NewTicker(f.currentInterval())
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			logging.Logger().Warn().
-				Str("module", "fetcher").Msg("Context done, stopping fetcher")
+			logging.Module("etherum").Warn().Msg("Context done, stopping fetcher")
+			f.updateStatus(func(status *Status) { status.State = StateStopped })
 			return nil
+		case <-f.reset:
+			ticker.Reset(f.currentInterval())
 		case <-ticker.C:
-			entries, err := f.client.getChanges(filterID)
-			start := time.Now()
-			if err != nil {
-				logging.Logger().Err(err).Str("module", "etherum").Msg("Cannot get filter changes")
-			} else {
-				transactions := entries.GetUniqueTransactionHashes()
-				logging.Logger().Info().Str("module", "etherum").Msgf("Fetched %d uniq transactions", len(transactions))
-				for _, txHash := range transactions {
-					transaction, err := f.client.getTransaction(txHash)
-					if err != nil {
-						// there should be a retry mechanism
-						logging.Logger().Err(err).Str("module", "etherum").Msgf("Cannot get transaction %s", txHash)
-					} else {
-						logging.Logger().Debug().
-							Str("module", "etherum").
-							Str("transaction", txHash).
-							Msgf("New transaction fetched: %+v", transaction)
-						f.txChan <- transaction.ToTransaction()
-					}
-				}
-				if nextBlock := entries.GetLastBlock(); nextBlock > f.lastBlock {
-					f.lastBlock = nextBlock
-					f.blocksChan <- f.lastBlock
-					logging.Logger().Debug().Str("module", "etherum").Dur("duration", time.Since(start)).Msgf("Block %d has been parsed", f.lastBlock)
-				}
+			if !f.paused.Load() {
+				f.poll(ctx, f.Status().FilterID)
 			}
+			f.updateHead(ctx)
+		}
+	}
+}
+
+// Standby marks the fetcher as waiting for another replica to give ingestion up, see supervisor.Exclusive.
+func (f *Fetcher) Standby() {
+	f.updateStatus(func(status *Status) { status.State = StateStandby })
+}
+
+// Pause stops polling for new blocks until Resume, the head of the node is still tracked, so the lag grows.
+// Blocks which appear meanwhile are fetched after resuming, as long as the node keeps the filter.
+func (f *Fetcher) Pause() {
+	f.paused.Store(true)
+	f.updateStatus(func(status *Status) {
+		if status.State == StateRunning {
+			status.State = StatePaused
+		}
+	})
+}
+
+func (f *Fetcher) Resume() {
+	f.paused.Store(false)
+	f.updateStatus(func(status *Status) {
+		if status.State == StatePaused {
+			status.State = StateRunning
 		}
+	})
+}
+
+func (f *Fetcher) runningState() string {
+	if f.paused.Load() {
+		return StatePaused
+	}
+	return StateRunning
+}
+
+// RecreateFilter replaces the filter of new blocks, e.g. when the node dropped it. Blocks which appeared
+// between the last poll and creating the new filter are not fetched, Backfill can be used for them.
+func (f *Fetcher) RecreateFilter(ctx context.Context) (string, error) {
+	filterID, err := f.client.createFilter(ctx)
+	if err != nil {
+		logging.Module("etherum").Err(err).Msg("Cannot create filter")
+		return "", err
+	}
+	logging.Module("etherum").Info().Msgf("Filter %s created", filterID)
+	f.updateStatus(func(status *Status) { status.FilterID = filterID })
+	return filterID, nil
+}
+
+// Backfill fetches transactions of past blocks from the range in the background, both ends are included.
+// Transactions go through the same pipeline as new ones, so transactions already fetched are stored again.
+func (f *Fetcher) Backfill(from, to int) error {
+	if from <= 0 || to < from || to-from >= maxBackfillRange {
+		return ErrInvalidRange
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.running == nil {
+		return ErrNotRunning
+	}
+	if f.backfill != nil && f.backfill.Running {
+		return ErrBackfillRunning
+	}
+	f.backfill = &Backfill{From: from, To: to, Next: from, Running: true}
+	f.backfills.Add(1)
+	go func(ctx context.Context) {
+		defer f.backfills.Done()
+		err := f.backfillRange(ctx, from, to)
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		f.backfill.Running = false
+		if err != nil {
+			f.backfill.Error = err.Error()
+		}
+	}(f.running)
+	return nil
+}
+
+func (f *Fetcher) backfillRange(ctx context.Context, from, to int) error {
+	ctx, span := tracing.Start(ctx, "fetcher.backfill", attribute.Int("from", from), attribute.Int("to", to))
+	defer span.End()
+	logging.Module("etherum").Info().Int("from", from).Int("to", to).Msg("Backfill started")
+	for start := from; start <= to; start += backfillChunk {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(start+backfillChunk-1, to)
+		entries, err := f.client.getLogs(ctx, start, end)
+		if err != nil {
+			logging.Module("etherum").Err(err).Int("from", start).Int("to", end).Msg("Cannot get logs")
+			tracing.Fail(span, err)
+			return err
+		}
+		transactions := entries.GetUniqueTransactionHashes()
+		metrics.TransactionsFetched.Add(float64(len(transactions)))
+		for _, txHash := range transactions {
+			if !f.fetch(ctx, txHash) {
+				return ctx.Err()
+			}
+		}
+		f.mutex.Lock()
+		f.backfill.Next, f.backfill.Transactions = end+1, f.backfill.Transactions+len(transactions)
+		f.mutex.Unlock()
+	}
+	logging.Module("etherum").Info().Int("from", from).Int("to", to).Msg("Backfill finished")
+	return nil
+}
+
+// poll fetches transactions of blocks which appeared since the previous poll, every transaction carries
+// the context of its own span, so the rest of the pipeline can be traced back to the moment the block was seen.
+func (f *Fetcher) poll(ctx context.Context, filterID string) {
+	ctx, span := tracing.Start(ctx, "fetcher.poll")
+	defer span.End()
+	entries, err := f.client.getChanges(ctx, filterID)
+	start := time.Now()
+	if err != nil {
+		logging.Module("etherum").Err(err).Msg("Cannot get filter changes")
+		tracing.Fail(span, err)
+		return
+	}
+	transactions := entries.GetUniqueTransactionHashes()
+	span.SetAttributes(attribute.Int("block", entries.GetLastBlock()), attribute.Int("transactions", len(transactions)))
+	logging.Module("etherum").Info().Msgf("Fetched %d uniq transactions", len(transactions))
+	metrics.TransactionsFetched.Add(float64(len(transactions)))
+	for _, txHash := range transactions {
+		if !f.fetch(ctx, txHash) {
+			return
+		}
+	}
+	if nextBlock := entries.GetLastBlock(); nextBlock > f.lastBlock {
+		f.countBlocks(nextBlock)
+		f.lastBlock = nextBlock
+		select {
+		case f.blocksChan <- f.lastBlock:
+		case <-ctx.Done():
+			return
+		}
+		logging.Module("etherum").Debug().Dur("duration", time.Since(start)).Msgf("Block %d has been parsed", f.lastBlock)
+	}
+}
+
+// fetch passes the transaction to the broker, it returns false when the context is done before the broker takes it.
+func (f *Fetcher) fetch(ctx context.Context, txHash string) bool {
+	ctx, span := tracing.Start(ctx, "fetcher.transaction", attribute.String("transaction.hash", txHash))
+	defer span.End()
+	transaction, err := f.client.getTransaction(ctx, txHash)
+	if err != nil {
+		// there should be a retry mechanism
+		logging.Module("etherum").Err(err).Msgf("Cannot get transaction %s", txHash)
+		tracing.Fail(span, err)
+		return true
+	}
+	logging.Module("etherum").Debug().
+		Str("transaction", txHash).
+		Msgf("New transaction fetched: %+v", transaction)
+	enriched := f.enrich(ctx, txHash, transaction.ToTransaction())
+	// the span lasts until the broker takes the transaction, so a full channel shows up in the trace
+	select {
+	case f.txChan <- model.TracedTransaction{Transaction: enriched, TraceParent: tracing.Inject(ctx)}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (f *Fetcher) countBlocks(nextBlock int) {
+	if f.lastBlock == 0 {
+		metrics.BlocksProcessed.Inc()
+	} else {
+		metrics.BlocksProcessed.Add(float64(nextBlock - f.lastBlock))
+	}
+	metrics.LastBlock.Set(float64(nextBlock))
+	f.updateStatus(func(status *Status) { status.LastBlock = nextBlock })
+}
+
+// updateHead asks the node for its latest block, so a stalled filter shows up as a growing lag.
+func (f *Fetcher) updateHead(ctx context.Context) {
+	head, err := f.client.getBlockNumber(ctx)
+	if err != nil {
+		logging.Module("etherum").Err(err).Msg("Cannot get head block")
+		return
+	}
+	metrics.HeadBlock.Set(float64(head))
+	f.updateStatus(func(status *Status) { status.HeadBlock = head })
+	if f.lastBlock > 0 {
+		metrics.HeadLag.Set(float64(max(head-f.lastBlock, 0)))
+	}
+}
+
+func (f *Fetcher) enrich(ctx context.Context, txHash string, transaction model.Transaction) model.Transaction {
+	if !f.fetchReceipts {
+		return transaction
+	}
+	// when the receipt is not available, the status stays unknown and success only filters skip such a transaction
+	receipt, err := f.client.getReceipt(ctx, txHash)
+	if err != nil {
+		logging.Module("etherum").Err(err).Msgf("Cannot get receipt of transaction %s", txHash)
+		return transaction
+	}
+	if receipt == nil {
+		return transaction
 	}
+	success := receipt.IsSuccessful()
+	transaction.Success = &success
+	return transaction
 }
@@ -0,0 +1,35 @@
+package etherum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/model"
+)
+
+func TestShouldStopPollingWhenNobodyTakesTransactions(t *testing.T) {
+	client := fakeNode(t, map[string]string{
+		getFilterChanges: `[{"blockNumber": "0x10", "transactionHash": "0x01"}]`,
+		getTransaction:   `{"hash": "0x01", "blockNumber": "0x10", "from": "0xa1", "to": "0xa2", "value": "0x5"}`,
+	})
+	// neither channel is read, like after the broker has stopped
+	fetcher := NewFetcher(&config.RPCConfig{Interval: time.Second}, client, make(chan model.TracedTransaction), make(chan int))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fetcher.poll(ctx, "0x1")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("poll is blocked on a full channel after the context is done")
+	}
+	// the block is not complete until all of its transactions are taken
+	require.Zero(t, fetcher.lastBlock)
+}
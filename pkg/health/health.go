@@ -0,0 +1,124 @@
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/etherum"
+)
+
+// FetcherProbe is implemented by etherum.Fetcher.
+type FetcherProbe interface {
+	Status() etherum.Status
+}
+
+// Report describes the state of the pipeline, Failures explain why it is not healthy.
+// Warnings describe exceeded ingestion thresholds which do not fail the probe.
+type Report struct {
+	Healthy       bool           `json:"healthy"`
+	Fetcher       etherum.Status `json:"fetcher"`
+	HeadLag       int            `json:"head_lag"`
+	QueueDepth    int            `json:"queue_depth"`
+	QueueCapacity int            `json:"queue_capacity"`
+	StorageError  string         `json:"storage_error,omitempty"`
+	Failures      []string       `json:"failures,omitempty"`
+	Warnings      []string       `json:"warnings,omitempty"`
+}
+
+func (r *Report) fail(format string, args ...any) {
+	r.Healthy = false
+	r.Failures = append(r.Failures, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) warn(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Monitor evaluates health checks on demand, queue returns the number of transactions waiting for the broker
+// and the capacity of the queue, storage returns an error when the storage is not available.
+type Monitor struct {
+	cfg     config.HealthConfig
+	fetcher FetcherProbe
+	queue   func() (int, int)
+	storage func() error
+}
+
+func NewMonitor(cfg *config.HealthConfig, fetcher FetcherProbe, queue func() (int, int), storage func() error) *Monitor {
+	monitor := &Monitor{fetcher: fetcher, queue: queue, storage: storage}
+	if cfg != nil {
+		monitor.cfg = *cfg
+	}
+	return monitor
+}
+
+// Liveness fails only when restarting the process may help: the fetcher stopped or it cannot reach the node for long.
+// A paused or standby fetcher does not call the node, so its staleness is not checked.
+func (m *Monitor) Liveness(now time.Time) Report {
+	report := m.report()
+	switch report.Fetcher.State {
+	case etherum.StateFailed, etherum.StateStopped:
+		report.fail("fetcher is %s", report.Fetcher.State)
+	case etherum.StatePaused, etherum.StateStandby:
+		return report
+	}
+	if stale, ok := stale(report, now, m.cfg.LivenessStaleness); ok {
+		report.fail("no successful RPC call for %s, allowed %s", stale.Truncate(time.Second), m.cfg.LivenessStaleness)
+	}
+	return report
+}
+
+// Readiness fails when the instance cannot serve the API, i.e. the storage is not available. Replicas usually share
+// a node, so a lagging node would take all of them out of service at once, although buffered data can be served,
+// that is why ingestion checks only add warnings unless they are enabled by IngestionReadiness. A paused or standby
+// fetcher never fails readiness, so admin requests, e.g. resuming ingestion, can still reach the instance.
+func (m *Monitor) Readiness(now time.Time) Report {
+	report := m.report()
+	if report.StorageError != "" {
+		report.fail("storage is not available")
+	}
+	check := report.warn
+	if m.cfg.IngestionReadiness {
+		check = report.fail
+	}
+	switch report.Fetcher.State {
+	case etherum.StatePaused, etherum.StateStandby:
+		return report
+	case etherum.StateRunning:
+	default:
+		check("fetcher is %s", report.Fetcher.State)
+	}
+	if stale, ok := stale(report, now, m.cfg.MaxRPCStaleness); ok {
+		check("no successful RPC call for %s, allowed %s", stale.Truncate(time.Second), m.cfg.MaxRPCStaleness)
+	}
+	if m.cfg.MaxHeadLag > 0 && report.HeadLag > m.cfg.MaxHeadLag {
+		check("last block is %d blocks behind the node, allowed %d", report.HeadLag, m.cfg.MaxHeadLag)
+	}
+	if m.cfg.MaxQueueDepth > 0 && report.QueueDepth > m.cfg.MaxQueueDepth {
+		check("%d transactions wait for the broker, allowed %d", report.QueueDepth, m.cfg.MaxQueueDepth)
+	}
+	return report
+}
+
+func (m *Monitor) report() Report {
+	report := Report{Healthy: true, Fetcher: m.fetcher.Status()}
+	if report.Fetcher.HeadBlock > 0 && report.Fetcher.LastBlock > 0 {
+		report.HeadLag = max(report.Fetcher.HeadBlock-report.Fetcher.LastBlock, 0)
+	}
+	report.QueueDepth, report.QueueCapacity = m.queue()
+	if err := m.storage(); err != nil {
+		report.StorageError = err.Error()
+	}
+	return report
+}
+
+// stale returns the time since the last successful RPC call when it exceeds the threshold,
+// it is never exceeded before the first call, the fetcher state covers the startup.
+func stale(report Report, now time.Time, threshold time.Duration) (time.Duration, bool) {
+	last := report.Fetcher.LastRPCAt
+	if threshold <= 0 || last.IsZero() {
+		return 0, false
+	}
+	stale := now.Sub(last)
+	return stale, stale > threshold
+}
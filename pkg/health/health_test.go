@@ -0,0 +1,154 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/etherum"
+)
+
+type fetcherStub etherum.Status
+
+func (f fetcherStub) Status() etherum.Status {
+	return etherum.Status(f)
+}
+
+func TestShouldEvaluateProbes(t *testing.T) {
+	now := time.Now()
+	cfg := &config.HealthConfig{
+		MaxRPCStaleness:    30 * time.Second,
+		MaxHeadLag:         10,
+		MaxQueueDepth:      100,
+		IngestionReadiness: true,
+		LivenessStaleness:  5 * time.Minute,
+	}
+	reporting := *cfg
+	reporting.IngestionReadiness = false
+	running := etherum.Status{State: etherum.StateRunning, LastRPCAt: now.Add(-time.Second), LastBlock: 100, HeadBlock: 105}
+	type testCase struct {
+		name          string
+		status        etherum.Status
+		queue         int
+		storageErr    error
+		wantLive      bool
+		wantReady     bool
+		wantFailures  int
+		wantWarnings  int
+		wantHeadLag   int
+		wantStorage   string
+		configuration *config.HealthConfig
+	}
+	tests := []testCase{
+		{name: "should be ready when pipeline is up to date", status: running, wantLive: true, wantReady: true, wantHeadLag: 5},
+		{
+			name:         "should not be ready while fetcher is starting",
+			status:       etherum.Status{State: etherum.StateStarting},
+			wantLive:     true,
+			wantFailures: 1,
+		},
+		{name: "should fail both probes when fetcher failed", status: etherum.Status{State: etherum.StateFailed}, wantFailures: 1},
+		{
+			name:         "should not be ready when node does not respond",
+			status:       etherum.Status{State: etherum.StateRunning, LastRPCAt: now.Add(-time.Minute)},
+			wantLive:     true,
+			wantFailures: 1,
+		},
+		{
+			name:         "should fail liveness when node does not respond for long",
+			status:       etherum.Status{State: etherum.StateRunning, LastRPCAt: now.Add(-time.Hour)},
+			wantFailures: 1,
+		},
+		{
+			name:      "should stay alive and ready while fetcher is paused",
+			status:    etherum.Status{State: etherum.StatePaused, LastRPCAt: now.Add(-time.Hour)},
+			wantLive:  true,
+			wantReady: true,
+		},
+		{
+			name:      "should be ready on standby replica",
+			status:    etherum.Status{State: etherum.StateStandby},
+			wantLive:  true,
+			wantReady: true,
+		},
+		{
+			name:         "should not be ready when lagging behind head",
+			status:       etherum.Status{State: etherum.StateRunning, LastRPCAt: now, LastBlock: 100, HeadBlock: 120},
+			wantLive:     true,
+			wantFailures: 1,
+			wantHeadLag:  20,
+		},
+		{name: "should not be ready when queue is full", status: running, queue: 101, wantLive: true, wantFailures: 1, wantHeadLag: 5},
+		{
+			name:         "should not be ready when storage is not available",
+			status:       running,
+			storageErr:   errors.New("connection refused"),
+			wantLive:     true,
+			wantFailures: 1,
+			wantHeadLag:  5,
+			wantStorage:  "connection refused",
+		},
+		{
+			name:          "should only warn about ingestion by default",
+			status:        etherum.Status{State: etherum.StateRunning, LastRPCAt: now.Add(-time.Minute), LastBlock: 100, HeadBlock: 120},
+			queue:         101,
+			configuration: &reporting,
+			wantLive:      true,
+			wantReady:     true,
+			wantWarnings:  3,
+			wantHeadLag:   20,
+		},
+		{
+			name:          "should be ready by default while fetcher is starting",
+			status:        etherum.Status{State: etherum.StateStarting},
+			configuration: &reporting,
+			wantLive:      true,
+			wantReady:     true,
+			wantWarnings:  1,
+		},
+		{
+			name:          "should not be ready by default when storage is not available",
+			status:        running,
+			storageErr:    errors.New("connection refused"),
+			configuration: &reporting,
+			wantLive:      true,
+			wantFailures:  1,
+			wantHeadLag:   5,
+			wantStorage:   "connection refused",
+		},
+		{
+			name:          "should skip disabled checks",
+			status:        etherum.Status{State: etherum.StateRunning, LastRPCAt: now.Add(-time.Hour), LastBlock: 1, HeadBlock: 1000},
+			queue:         1000,
+			configuration: &config.HealthConfig{},
+			wantLive:      true,
+			wantReady:     true,
+			wantHeadLag:   999,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := cfg
+			if tt.configuration != nil {
+				configuration = tt.configuration
+			}
+			monitor := NewMonitor(
+				configuration,
+				fetcherStub(tt.status),
+				func() (int, int) { return tt.queue, 1000 },
+				func() error { return tt.storageErr },
+			)
+			live := monitor.Liveness(now)
+			ready := monitor.Readiness(now)
+			require.Equal(t, tt.wantLive, live.Healthy)
+			require.Equal(t, tt.wantReady, ready.Healthy)
+			require.Len(t, ready.Failures, tt.wantFailures)
+			require.Len(t, ready.Warnings, tt.wantWarnings)
+			require.Equal(t, tt.wantHeadLag, ready.HeadLag)
+			require.Equal(t, tt.queue, ready.QueueDepth)
+			require.Equal(t, tt.wantStorage, ready.StorageError)
+		})
+	}
+}
@@ -1,18 +1,226 @@
 package logging
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"sync"
 
 	"github.com/rs/zerolog"
 )
 
-var defaultLogger zerolog.Logger
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+	OutputStdout  = "stdout"
+	OutputStderr  = "stderr"
+)
+
+// Config is a part of the service configuration, it lives here because the config package logs as well.
+// Modules overrides Level for loggers returned by Module, e.g. {"parser": "warn"}.
+// Sampling writes only every n-th event of loggers returned by Sampled, e.g. {"consumed": 100}.
+type Config struct {
+	Level    string            `yaml:"level"`
+	Format   string            `yaml:"format"`
+	Output   string            `yaml:"output"`
+	Modules  map[string]string `yaml:"modules"`
+	Sampling map[string]uint32 `yaml:"sampling"`
+}
+
+var (
+	mutex sync.RWMutex
+	root  zerolog.Logger
+	// output is the file written by root, it is closed when replaced unless it is stdout or stderr
+	output       = os.Stdout
+	level        = zerolog.InfoLevel
+	moduleLevels = map[string]zerolog.Level{}
+	sampling     = map[string]uint32{}
+	// loggers caches derived loggers, it is cleared whenever levels change
+	loggers = map[string]*zerolog.Logger{}
+)
 
 func init() {
-	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	defaultLogger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).With().Timestamp().Logger()
+	// levels are applied per logger, so the global one must not filter anything
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	setWriter(zerolog.ConsoleWriter{Out: os.Stdout})
+}
+
+// Validate checks levels and the format, the output is checked only when it is opened.
+func (cfg *Config) Validate() error {
+	if cfg.Level != "" {
+		if _, err := parseLevel(cfg.Level); err != nil {
+			return err
+		}
+	}
+	for module, value := range cfg.Modules {
+		if _, err := parseLevel(value); err != nil {
+			return fmt.Errorf("module %s: %w", module, err)
+		}
+	}
+	if cfg.Format != "" && cfg.Format != FormatConsole && cfg.Format != FormatJSON {
+		return fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+	return nil
 }
 
+// Configure replaces the writer, levels and sampling of all loggers. Empty fields keep defaults:
+// info level written to stdout in the console format. The previous output file is closed,
+// events written at the same time by loggers obtained before are lost.
+func Configure(cfg *Config) error {
+	if cfg == nil {
+		return nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	newLevel := zerolog.InfoLevel
+	if cfg.Level != "" {
+		newLevel, _ = parseLevel(cfg.Level)
+	}
+	newModuleLevels := make(map[string]zerolog.Level, len(cfg.Modules))
+	for module, value := range cfg.Modules {
+		newModuleLevels[module], _ = parseLevel(value)
+	}
+	newOutput, err := openOutput(cfg.Output)
+	if err != nil {
+		return err
+	}
+	var writer io.Writer = newOutput
+	if cfg.Format != FormatJSON {
+		writer = zerolog.ConsoleWriter{Out: newOutput, NoColor: !isStandard(newOutput)}
+	}
+
+	mutex.Lock()
+	previous := output
+	level, moduleLevels, sampling = newLevel, newModuleLevels, make(map[string]uint32, len(cfg.Sampling))
+	for key, n := range cfg.Sampling {
+		sampling[key] = n
+	}
+	output = newOutput
+	setWriter(writer)
+	mutex.Unlock()
+	if !isStandard(previous) {
+		// the configuration is already applied, so failing to close the previous file is not its error
+		if err := previous.Close(); err != nil {
+			Logger().Warn().Err(err).Str("file", previous.Name()).Msg("Cannot close previous log output")
+		}
+	}
+	return nil
+}
+
+// Logger returns the logger of events which do not belong to any module.
 func Logger() *zerolog.Logger {
-	return &defaultLogger
+	return cached("", func() zerolog.Logger {
+		return root.Level(level)
+	})
+}
+
+// Module returns the logger of the module, it adds the module field and applies the level of the module.
+func Module(module string) *zerolog.Logger {
+	return cached(module, func() zerolog.Logger {
+		return root.With().Str("module", module).Logger().Level(levelOf(module))
+	})
+}
+
+// Sampled returns the logger of the module which writes only every n-th event, where n is configured
+// for the key in the sampling section. It is meant for high volume events, e.g. every consumed transaction.
+func Sampled(module, key string) *zerolog.Logger {
+	return cached(module+"/"+key, func() zerolog.Logger {
+		logger := root.With().Str("module", module).Logger().Level(levelOf(module))
+		if n := sampling[key]; n > 1 {
+			logger = logger.Sample(&zerolog.BasicSampler{N: n})
+		}
+		return logger
+	})
+}
+
+// SetLevel changes the level at runtime, an empty module changes the default level of all modules
+// without an override, an empty level removes the override of the module.
+func SetLevel(module, value string) error {
+	var parsed zerolog.Level
+	if value != "" {
+		var err error
+		if parsed, err = parseLevel(value); err != nil {
+			return err
+		}
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	switch {
+	case module == "" && value == "":
+		return fmt.Errorf("level is required")
+	case module == "":
+		level = parsed
+	case value == "":
+		delete(moduleLevels, module)
+	default:
+		moduleLevels[module] = parsed
+	}
+	clear(loggers)
+	return nil
+}
+
+// Levels returns the default level and overrides of modules.
+func Levels() (string, map[string]string) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	modules := make(map[string]string, len(moduleLevels))
+	for module, moduleLevel := range moduleLevels {
+		modules[module] = moduleLevel.String()
+	}
+	return level.String(), modules
+}
+
+func cached(key string, build func() zerolog.Logger) *zerolog.Logger {
+	mutex.RLock()
+	logger, ok := loggers[key]
+	mutex.RUnlock()
+	if ok {
+		return logger
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if logger, ok = loggers[key]; !ok {
+		built := build()
+		logger = &built
+		loggers[key] = logger
+	}
+	return logger
+}
+
+// levelOf has to be called with the mutex held.
+func levelOf(module string) zerolog.Level {
+	if moduleLevel, ok := moduleLevels[module]; ok {
+		return moduleLevel
+	}
+	return level
+}
+
+// setWriter has to be called with the mutex held, or before loggers are used.
+func setWriter(writer io.Writer) {
+	root = zerolog.New(writer).With().Timestamp().Logger()
+	clear(loggers)
+}
+
+func parseLevel(value string) (zerolog.Level, error) {
+	parsed, err := zerolog.ParseLevel(value)
+	if err != nil || value == "" {
+		return zerolog.NoLevel, fmt.Errorf("unknown log level: %q", value)
+	}
+	return parsed, nil
+}
+
+func isStandard(file *os.File) bool {
+	return file == os.Stdout || file == os.Stderr
+}
+
+func openOutput(output string) (*os.File, error) {
+	switch output {
+	case "", OutputStdout:
+		return os.Stdout, nil
+	case OutputStderr:
+		return os.Stderr, nil
+	default:
+		return os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	}
 }
@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type entry struct {
+	Level   string `json:"level"`
+	Module  string `json:"module"`
+	Message string `json:"message"`
+}
+
+func configureTestLogging(t *testing.T, cfg Config) string {
+	t.Helper()
+	cfg.Format, cfg.Output = FormatJSON, filepath.Join(t.TempDir(), "test.log")
+	require.NoError(t, Configure(&cfg))
+	t.Cleanup(func() {
+		require.NoError(t, Configure(&Config{}))
+	})
+	return cfg.Output
+}
+
+func readEntries(t *testing.T, path string) []entry {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	entries := []entry{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line == "" {
+			continue
+		}
+		var e entry
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestShouldApplyLevelsOfModules(t *testing.T) {
+	path := configureTestLogging(t, Config{Level: "warn", Modules: map[string]string{"parser": "debug"}})
+
+	Logger().Info().Msg("default info")
+	Logger().Warn().Msg("default warn")
+	Module("memory").Info().Msg("memory info")
+	Module("parser").Debug().Msg("parser debug")
+	Module("parser").Trace().Msg("parser trace")
+
+	require.Equal(t, []entry{
+		{Level: "warn", Message: "default warn"},
+		{Level: "debug", Module: "parser", Message: "parser debug"},
+	}, readEntries(t, path))
+}
+
+func TestShouldSampleEvents(t *testing.T) {
+	path := configureTestLogging(t, Config{Sampling: map[string]uint32{"consumed": 3}})
+
+	for i := 0; i < 7; i++ {
+		Sampled("parser", "consumed").Info().Msg("consumed")
+		Sampled("parser", "other").Info().Msg("other")
+	}
+
+	messages := map[string]int{}
+	for _, e := range readEntries(t, path) {
+		require.Equal(t, "parser", e.Module)
+		messages[e.Message]++
+	}
+	require.Equal(t, map[string]int{"consumed": 3, "other": 7}, messages)
+}
+
+func TestShouldChangeLevelsAtRuntime(t *testing.T) {
+	tests := []struct {
+		name        string
+		module      string
+		level       string
+		wantErr     bool
+		wantLevel   string
+		wantModules map[string]string
+	}{
+		{"Should change default level", "", "error", false, "error", map[string]string{"parser": "warn"}},
+		{"Should override level of module", "memory", "debug", false, "info", map[string]string{"parser": "warn", "memory": "debug"}},
+		{"Should remove override of module", "parser", "", false, "info", map[string]string{}},
+		{"Should reject unknown level", "parser", "loud", true, "info", map[string]string{"parser": "warn"}},
+		{"Should reject empty default level", "", "", true, "info", map[string]string{"parser": "warn"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configureTestLogging(t, Config{Modules: map[string]string{"parser": "warn"}})
+			require.True(t, Module("parser").Info() == nil)
+
+			err := SetLevel(tt.module, tt.level)
+
+			require.Equal(t, tt.wantErr, err != nil)
+			level, modules := Levels()
+			require.Equal(t, tt.wantLevel, level)
+			require.Equal(t, tt.wantModules, modules)
+			_, overridden := tt.wantModules["parser"]
+			require.Equal(t, overridden, Module("parser").Info() == nil)
+		})
+	}
+}
+
+func TestShouldRejectInvalidConfiguration(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"Should reject unknown level", Config{Level: "loud"}},
+		{"Should reject unknown level of module", Config{Modules: map[string]string{"parser": "loud"}}},
+		{"Should reject unknown format", Config{Format: "xml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, Configure(&tt.cfg))
+		})
+	}
+}
+
+func TestShouldClosePreviousOutputFile(t *testing.T) {
+	configureTestLogging(t, Config{})
+	mutex.RLock()
+	previous := output
+	mutex.RUnlock()
+
+	path := configureTestLogging(t, Config{})
+	Logger().Info().Msg("written to the new file")
+
+	_, err := previous.WriteString("test")
+	require.ErrorIs(t, err, os.ErrClosed)
+	require.Len(t, readEntries(t, path), 1)
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "etherscription"
+
+// Registry gathers all metrics of the service, a dedicated registry keeps tests independent of global state.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	RPCRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rpc",
+		Name:      "requests_total",
+		Help:      "Number of JSON-RPC calls made to the node by method and outcome.",
+	}, []string{"method", "outcome"})
+	RPCDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "rpc",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of JSON-RPC calls made to the node by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	BlocksProcessed = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "fetcher",
+		Name:      "blocks_processed_total",
+		Help:      "Number of blocks whose transactions were fetched.",
+	})
+	LastBlock = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "fetcher",
+		Name:      "last_block",
+		Help:      "Number of the last processed block.",
+	})
+	HeadBlock = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "fetcher",
+		Name:      "head_block",
+		Help:      "Number of the latest block reported by the node.",
+	})
+	HeadLag = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "fetcher",
+		Name:      "head_lag_blocks",
+		Help:      "Number of blocks the last processed block is behind the head of the node.",
+	})
+	FetcherState = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "fetcher",
+		Name:      "state",
+		Help:      "State of the fetcher, the gauge of the current state is 1, other ones are 0.",
+	}, []string{"state"})
+	TransactionsFetched = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transactions",
+		Name:      "fetched_total",
+		Help:      "Number of transactions fetched from the node.",
+	})
+	TransactionsConsumed = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transactions",
+		Name:      "consumed_total",
+		Help:      "Number of transactions consumed by the broker.",
+	})
+	TransactionsStored = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transactions",
+		Name:      "stored_total",
+		Help:      "Number of transactions appended to subscriber buffers.",
+	})
+	TransactionsDropped = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transactions",
+		Name:      "dropped_total",
+		Help:      "Number of transactions not buffered for a subscriber by reason.",
+	}, []string{"reason"})
+	StorageEvictions = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "evictions_total",
+		Help:      "Number of entries discarded because of storage limits by storage and kind (evicted or dropped).",
+	}, []string{"storage", "kind"})
+	WALFailures = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "wal_failures_total",
+		Help:      "Number of failed writes and syncs of the write-ahead log of the memory backend.",
+	})
+	CleanerDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "cleaner",
+		Name:      "duration_seconds",
+		Help:      "Duration of a single cleaner run by cleaner.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"cleaner"})
+	CleanerRemoved = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cleaner",
+		Name:      "removed_total",
+		Help:      "Number of outdated entries removed by cleaner.",
+	}, []string{"cleaner"})
+	APIDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "api",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP API requests by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler exposes metrics in the prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterChannel reports occupancy of a buffered channel, it is read on every scrape.
+func RegisterChannel[T any](name string, channel chan T) {
+	labels := prometheus.Labels{"channel": name}
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Subsystem:   "channel",
+		Name:        "length",
+		Help:        "Number of messages waiting in the channel.",
+		ConstLabels: labels,
+	}, func() float64 { return float64(len(channel)) })
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Subsystem:   "channel",
+		Name:        "capacity",
+		Help:        "Capacity of the channel.",
+		ConstLabels: labels,
+	}, func() float64 { return float64(cap(channel)) })
+}
+
+// RegisterStorage reports the number of keys and entries of a storage, each of them only when its function is not nil.
+// Both functions are called on every scrape, so they should be cheap.
+func RegisterStorage(name string, keys, entries func() int) {
+	labels := prometheus.Labels{"storage": name}
+	if keys != nil {
+		factory.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "storage",
+			Name:        "keys",
+			Help:        "Number of keys in the storage.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(keys()) })
+	}
+	if entries == nil {
+		return
+	}
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Subsystem:   "storage",
+		Name:        "entries",
+		Help:        "Number of entries in the storage, including outdated ones not cleaned yet.",
+		ConstLabels: labels,
+	}, func() float64 { return float64(entries()) })
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+type Direction string
+
+const (
+	DirectionAny      Direction = ""
+	DirectionIncoming Direction = "incoming"
+	DirectionOutgoing Direction = "outgoing"
+)
+
+// Filter narrows down transactions delivered to a subscription. Empty fields match everything.
+// MinValue and MaxValue are amounts of wei, in JSON they are numbers of any size.
+// TokenContract matches transactions sent to the given contract, SuccessOnly requires fetching receipts.
+type Filter struct {
+	Direction      Direction `json:"direction,omitempty"`
+	MinValue       *big.Int  `json:"min_value,omitempty"`
+	MaxValue       *big.Int  `json:"max_value,omitempty"`
+	Counterparties []string  `json:"counterparties,omitempty"`
+	TokenContract  string    `json:"token_contract,omitempty"`
+	MethodSelector string    `json:"method_selector,omitempty"`
+	SuccessOnly    bool      `json:"success_only,omitempty"`
+}
+
+func (f *Filter) Validate() error {
+	if f.Direction != DirectionAny && f.Direction != DirectionIncoming && f.Direction != DirectionOutgoing {
+		return errors.New("direction should be either incoming or outgoing")
+	}
+	if (f.MinValue != nil && f.MinValue.Sign() < 0) || (f.MaxValue != nil && f.MaxValue.Sign() < 0) {
+		return errors.New("min_value and max_value cannot be negative")
+	}
+	if f.MinValue != nil && f.MaxValue != nil && f.MinValue.Cmp(f.MaxValue) > 0 {
+		return errors.New("min_value cannot be greater than max_value")
+	}
+	for _, counterparty := range f.Counterparties {
+		if !IsValidAddress(counterparty) {
+			return errors.New("counterparties should contain valid addresses")
+		}
+	}
+	if f.TokenContract != "" && !IsValidAddress(f.TokenContract) {
+		return errors.New("token_contract should be a valid address")
+	}
+	if f.MethodSelector != "" && !isValidMethodSelector(f.MethodSelector) {
+		return errors.New("method_selector should be 0x prefixed 4 bytes long hex string")
+	}
+	return nil
+}
+
+// Matches checks the transaction from the perspective of the subscribed address.
+// A nil filter matches every transaction.
+func (f *Filter) Matches(address string, transaction Transaction) bool {
+	if f == nil {
+		return true
+	}
+	incoming := strings.EqualFold(transaction.To, address)
+	outgoing := strings.EqualFold(transaction.From, address)
+	switch {
+	case f.Direction == DirectionIncoming && !incoming:
+		return false
+	case f.Direction == DirectionOutgoing && !outgoing:
+		return false
+	case f.MinValue != nil && transaction.Amount().Cmp(f.MinValue) < 0:
+		return false
+	case f.MaxValue != nil && transaction.Amount().Cmp(f.MaxValue) > 0:
+		return false
+	case f.TokenContract != "" && !strings.EqualFold(transaction.To, f.TokenContract):
+		return false
+	case f.MethodSelector != "" && !strings.EqualFold(transaction.MethodSelector, f.MethodSelector):
+		return false
+	case f.SuccessOnly && (transaction.Success == nil || !*transaction.Success):
+		return false
+	}
+	return len(f.Counterparties) == 0 ||
+		(incoming && f.isCounterparty(transaction.From)) ||
+		(outgoing && f.isCounterparty(transaction.To))
+}
+
+func (f *Filter) isCounterparty(address string) bool {
+	for _, counterparty := range f.Counterparties {
+		if strings.EqualFold(counterparty, address) {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidMethodSelector(selector string) bool {
+	if len(selector) != methodSelectorLength || !strings.HasPrefix(selector, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(selector[2:])
+	return err == nil
+}
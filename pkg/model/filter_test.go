@@ -0,0 +1,79 @@
+package model
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestShouldMatchTransactionsAgainstFilter(t *testing.T) {
+	const (
+		subscriber = "0xae2fc483527b8ef99eb5d9b44875f005ba1fae13"
+		other      = "0x1f2f10d1c40777ae1da742455c65828ff36df387"
+		third      = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+	)
+	low, high := big.NewInt(10), big.NewInt(100)
+	// 10 ETH does not fit into int64
+	tenEther, _ := new(big.Int).SetString("10000000000000000000", 10)
+	succeeded, failed := true, false
+	incoming := Transaction{From: other, To: subscriber, Value: 50, MethodSelector: "0xa9059cbb", Success: &succeeded}
+	outgoing := Transaction{From: subscriber, To: other, Value: 50, Success: &failed}
+	tests := []struct {
+		name        string
+		filter      *Filter
+		transaction Transaction
+		want        bool
+	}{
+		{name: "nil filter", filter: nil, transaction: incoming, want: true},
+		{name: "empty filter", filter: &Filter{}, transaction: outgoing, want: true},
+		{name: "incoming only for incoming", filter: &Filter{Direction: DirectionIncoming}, transaction: incoming, want: true},
+		{name: "incoming only for outgoing", filter: &Filter{Direction: DirectionIncoming}, transaction: outgoing, want: false},
+		{name: "outgoing only for outgoing", filter: &Filter{Direction: DirectionOutgoing}, transaction: outgoing, want: true},
+		{name: "value in range", filter: &Filter{MinValue: low, MaxValue: high}, transaction: incoming, want: true},
+		{name: "value below minimum", filter: &Filter{MinValue: high}, transaction: incoming, want: false},
+		{name: "value above maximum", filter: &Filter{MaxValue: low}, transaction: incoming, want: false},
+		{name: "exact value above minimum", filter: &Filter{MinValue: high}, transaction: Transaction{From: other, To: subscriber, Value: tenEther.Int64(), ExactValue: tenEther}, want: true},
+		{name: "exact value above maximum", filter: &Filter{MaxValue: high}, transaction: Transaction{From: other, To: subscriber, Value: tenEther.Int64(), ExactValue: tenEther}, want: false},
+		{name: "known counterparty", filter: &Filter{Counterparties: []string{third, other}}, transaction: outgoing, want: true},
+		{name: "unknown counterparty", filter: &Filter{Counterparties: []string{third}}, transaction: incoming, want: false},
+		{name: "token contract", filter: &Filter{TokenContract: other}, transaction: outgoing, want: true},
+		{name: "other token contract", filter: &Filter{TokenContract: third}, transaction: outgoing, want: false},
+		{name: "method selector", filter: &Filter{MethodSelector: "0xA9059CBB"}, transaction: incoming, want: true},
+		{name: "other method selector", filter: &Filter{MethodSelector: "0x23b872dd"}, transaction: incoming, want: false},
+		{name: "success only for successful", filter: &Filter{SuccessOnly: true}, transaction: incoming, want: true},
+		{name: "success only for failed", filter: &Filter{SuccessOnly: true}, transaction: outgoing, want: false},
+		{name: "success only for unknown status", filter: &Filter{SuccessOnly: true}, transaction: Transaction{From: other, To: subscriber}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(subscriber, tt.transaction); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldValidateFilter(t *testing.T) {
+	low, high := big.NewInt(10), big.NewInt(100)
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr bool
+	}{
+		{name: "empty", filter: Filter{}, wantErr: false},
+		{name: "unknown direction", filter: Filter{Direction: "sideways"}, wantErr: true},
+		{name: "proper range", filter: Filter{MinValue: low, MaxValue: high}, wantErr: false},
+		{name: "inverted range", filter: Filter{MinValue: high, MaxValue: low}, wantErr: true},
+		{name: "negative value", filter: Filter{MinValue: big.NewInt(-1)}, wantErr: true},
+		{name: "invalid counterparty", filter: Filter{Counterparties: []string{"0x1"}}, wantErr: true},
+		{name: "invalid token contract", filter: Filter{TokenContract: "0x1"}, wantErr: true},
+		{name: "invalid method selector", filter: Filter{MethodSelector: "0xa9059c"}, wantErr: true},
+		{name: "proper method selector", filter: Filter{MethodSelector: "0xa9059cbb"}, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,19 +1,144 @@
 package model
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"math/big"
+	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	methodSelectorLength = len("0x") + 8
+	// DefaultTenant owns all subscriptions when authentication is disabled
+	DefaultTenant   = "default"
+	tenantSeparator = "/"
 )
 
 // Transaction represents a simplified transaction in the Ethereum network. It is used in parser package.
+// Success is known only when transaction receipts are fetched.
+// Value does not hold amounts above math.MaxInt64 wei (about 9.22 ETH), such amounts are kept in ExactValue.
 type Transaction struct {
-	From  string `json:"from"`
-	To    string `json:"to"`
-	Value int64  `json:"value"`
+	Hash           string   `json:"hash,omitempty"`
+	BlockNumber    int      `json:"block_number,omitempty"`
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	Value          int64    `json:"value"`
+	ExactValue     *big.Int `json:"exact_value,omitempty"`
+	MethodSelector string   `json:"method_selector,omitempty"`
+	Success        *bool    `json:"success,omitempty"`
+}
+
+// TracedTransaction is passed through the pipeline and kept in storages, TraceParent carries the W3C trace context
+// of the span which handled the transaction most recently, it is empty when tracing is disabled.
+// It is internal, only the embedded Transaction is exposed by the API and webhooks.
+type TracedTransaction struct {
+	Transaction
+	TraceParent string `json:"trace_parent,omitempty"`
+}
+
+// Untraced strips trace contexts of transactions before they leave the service.
+func Untraced(transactions []TracedTransaction) []Transaction {
+	untraced := make([]Transaction, len(transactions))
+	for i, transaction := range transactions {
+		untraced[i] = transaction.Transaction
+	}
+	return untraced
+}
+
+// Amount returns the value of the transaction in wei without loss of precision.
+func (t *Transaction) Amount() *big.Int {
+	if t.ExactValue != nil {
+		return t.ExactValue
+	}
+	return big.NewInt(t.Value)
+}
+
+// Subscription represents an address watched by a client. It is used in parser and api packages.
+// When CallbackURL is set, matched transactions are pushed to it instead of being buffered.
+// When TTL is set, the subscription expires unless it is renewed before ExpiresAt.
+type Subscription struct {
+	Tenant         string     `json:"tenant,omitempty"`
+	Address        string     `json:"address"`
+	CallbackURL    string     `json:"callback_url,omitempty"`
+	Filter         *Filter    `json:"filter,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty"`
+	TTL            Duration   `json:"ttl,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// Key identifies the subscription in storages, the same address can be subscribed by many tenants.
+func (s *Subscription) Key() string {
+	return ScopedKey(s.Tenant, s.Address)
+}
+
+// Renew moves the expiration of the subscription by its TTL counting from now.
+func (s *Subscription) Renew(now time.Time) {
+	if s.TTL > 0 {
+		expiresAt := now.Add(time.Duration(s.TTL))
+		s.ExpiresAt = &expiresAt
+	}
+}
+
+func (s *Subscription) IsActive(now time.Time) bool {
+	return s.ExpiresAt == nil || s.ExpiresAt.After(now)
+}
+
+// Duration is a time.Duration represented in JSON as a string like "24h" or "90s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Tenant represents a client identified by an API key. It is used in parser and api packages.
+// Managed tenants come from the configuration, they are removed from storage once they are no longer configured.
+type Tenant struct {
+	Name    string `json:"name"`
+	APIKey  string `json:"api_key"`
+	Managed bool   `json:"managed,omitempty"`
+}
+
+// ScopedKey builds a storage key of the address owned by the tenant.
+func ScopedKey(tenant, address string) string {
+	return tenant + tenantSeparator + address
+}
+
+// IsValidTenantName checks whether the name can be safely used as a part of a storage key.
+func IsValidTenantName(name string) bool {
+	return name != "" && !strings.Contains(name, tenantSeparator)
+}
+
+// DeadLetter represents a webhook delivery that failed after all retries. It is used in webhook package.
+type DeadLetter struct {
+	Tenant      string      `json:"tenant,omitempty"`
+	Address     string      `json:"address"`
+	CallbackURL string      `json:"callback_url"`
+	Transaction Transaction `json:"transaction"`
+	Attempts    int         `json:"attempts"`
+	LastError   string      `json:"last_error"`
+	FailedAt    time.Time   `json:"failed_at"`
 }
 
 // RawTransaction represents a raw transaction in the Ethereum network. It is used in ethereum package.
 type RawTransaction struct {
+	Hash             string `json:"hash"`
+	BlockNumber      string `json:"blockNumber"`
 	From             string `json:"from"`
 	To               string `json:"to"`
 	Input            string `json:"input"`
@@ -22,18 +147,58 @@ type RawTransaction struct {
 }
 
 func (t *RawTransaction) ToTransaction() Transaction {
-	return Transaction{
-		From:  t.From,
-		To:    t.To,
-		Value: ConvertHexToInt(t.Value),
+	transaction := Transaction{
+		Hash:        t.Hash,
+		BlockNumber: int(ConvertHexToInt(t.BlockNumber)),
+		From:        t.From,
+		To:          t.To,
+	}
+	value := convertHexToBigInt(t.Value)
+	transaction.Value = value.Int64()
+	if !value.IsInt64() {
+		transaction.ExactValue = value
 	}
+	// the selector is the first 4 bytes of the call data
+	if len(t.Input) >= methodSelectorLength {
+		transaction.MethodSelector = t.Input[:methodSelectorLength]
+	}
+	return transaction
+}
+
+// RawReceipt represents a receipt of a mined transaction. It is used in ethereum package.
+type RawReceipt struct {
+	Status string `json:"status"`
+}
+
+func (r *RawReceipt) IsSuccessful() bool {
+	return ConvertHexToInt(r.Status) == 1
+}
+
+// IsValidAddress checks whether the address is a 0x prefixed, 20 bytes long hex string.
+func IsValidAddress(address string) bool {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(address[2:])
+	return err == nil
 }
 
 func ConvertHexToInt(hex string) int64 {
+	return convertHexToBigInt(hex).Int64()
+}
+
+func convertHexToBigInt(hex string) *big.Int {
+	value := new(big.Int)
 	if !strings.HasPrefix(hex, "0x") {
-		return 0
+		return value
 	}
-	value := new(big.Int)
-	value.SetString(hex, 0)
-	return value.Int64()
+	if _, ok := value.SetString(hex, 0); !ok {
+		return new(big.Int)
+	}
+	return value
+}
+
+// ConvertIntToHex encodes a block number the way JSON-RPC expects it, e.g. 0x1b4.
+func ConvertIntToHex(value int) string {
+	return "0x" + strconv.FormatInt(int64(value), 16)
 }
@@ -1,8 +1,11 @@
 package model
 
 import (
+	"encoding/json"
+	"math/big"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestShouldConvertHexStringToProperInt(t *testing.T) {
@@ -31,6 +34,7 @@ func TestShouldConvertHexStringToProperInt(t *testing.T) {
 }
 
 func TestShouldConvertRawToSimplifiedTransaction(t *testing.T) {
+	tenEther, _ := new(big.Int).SetString("10000000000000000000", 10)
 	type fields struct {
 		From             string
 		To               string
@@ -46,6 +50,16 @@ func TestShouldConvertRawToSimplifiedTransaction(t *testing.T) {
 		{name: "empty", fields: fields{}, want: Transaction{}},
 		{name: "simple", fields: fields{From: "0x1", To: "0x2", Value: "0x10"}, want: Transaction{From: "0x1", To: "0x2", Value: 16}},
 		{name: "complex", fields: fields{From: "0x1", To: "0x2", Value: "0x1f"}, want: Transaction{From: "0x1", To: "0x2", Value: 31}},
+		{
+			name:   "contract call",
+			fields: fields{From: "0x1", To: "0x2", Value: "0x0", Input: "0xa9059cbb000000000000000000000000"},
+			want:   Transaction{From: "0x1", To: "0x2", Value: 0, MethodSelector: "0xa9059cbb"},
+		},
+		{
+			name:   "value above int64",
+			fields: fields{From: "0x1", To: "0x2", Value: "0x8ac7230489e80000"},
+			want:   Transaction{From: "0x1", To: "0x2", Value: tenEther.Int64(), ExactValue: tenEther},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t1 *testing.T) {
@@ -62,3 +76,81 @@ func TestShouldConvertRawToSimplifiedTransaction(t *testing.T) {
 		})
 	}
 }
+
+func TestShouldEncodeDurationAsHumanReadableString(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    Duration
+		wantErr bool
+	}{
+		{name: "hours", json: `"24h"`, want: Duration(24 * time.Hour)},
+		{name: "mixed", json: `"1m30s"`, want: Duration(90 * time.Second)},
+		{name: "invalid unit", json: `"10x"`, wantErr: true},
+		{name: "number", json: `10`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Duration
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("UnmarshalJSON() = %v, want %v", got, tt.want)
+			}
+			encoded, _ := json.Marshal(got)
+			var decoded Duration
+			if err = json.Unmarshal(encoded, &decoded); err != nil || decoded != tt.want {
+				t.Errorf("MarshalJSON() = %s, cannot be decoded back", encoded)
+			}
+		})
+	}
+}
+
+func TestShouldExpireSubscriptionOnlyWhenTTLIsSet(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		subscription Subscription
+		at           time.Time
+		want         bool
+	}{
+		{name: "without ttl", subscription: Subscription{}, at: now.Add(time.Hour * 1000), want: true},
+		{name: "before expiration", subscription: Subscription{TTL: Duration(time.Minute)}, at: now.Add(time.Second), want: true},
+		{name: "after expiration", subscription: Subscription{TTL: Duration(time.Minute)}, at: now.Add(time.Hour), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.subscription.Renew(now)
+			if got := tt.subscription.IsActive(tt.at); got != tt.want {
+				t.Errorf("IsActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldValidateAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    bool
+	}{
+		{name: "empty", address: "", want: false},
+		{name: "without prefix", address: "ae2fc483527b8ef99eb5d9b44875f005ba1fae13", want: false},
+		{name: "too short", address: "0x1234", want: false},
+		{name: "not a hex", address: "0xzz2fc483527b8ef99eb5d9b44875f005ba1fae13", want: false},
+		{name: "lower case", address: "0xae2fc483527b8ef99eb5d9b44875f005ba1fae13", want: true},
+		{name: "mixed case", address: "0xAe2fc483527b8ef99eb5d9b44875f005ba1fAE13", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidAddress(tt.address); got != tt.want {
+				t.Errorf("IsValidAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
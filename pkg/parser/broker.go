@@ -5,16 +5,18 @@ import (
 
 	"github.com/ziollek/etherscription/pkg/logging"
 	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Broker struct {
-	transactions  <-chan model.Transaction
+	transactions  <-chan model.TracedTransaction
 	blocks        <-chan int
-	txConsumer    Consumer[model.Transaction]
+	txConsumer    Consumer[model.TracedTransaction]
 	stateConsumer Consumer[int]
 }
 
-func NewBroker(transactions <-chan model.Transaction, blocks <-chan int, txConsumer Consumer[model.Transaction], stateConsumer Consumer[int]) *Broker {
+func NewBroker(transactions <-chan model.TracedTransaction, blocks <-chan int, txConsumer Consumer[model.TracedTransaction], stateConsumer Consumer[int]) *Broker {
 	return &Broker{
 		transactions:  transactions,
 		blocks:        blocks,
@@ -23,18 +25,48 @@ func NewBroker(transactions <-chan model.Transaction, blocks <-chan int, txConsu
 	}
 }
 
+// Start consumes messages until ctx is done, then it drains messages which are already buffered.
 func (broker *Broker) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			logging.Logger().Warn().
-				Str("module", "parser").Msg("Context done, stopping fetcher")
+			logging.Module("parser").Warn().Msg("Context done, stopping broker")
+			broker.drain()
 			return
 		case transaction := <-broker.transactions:
 			// it can be done in parallel for slower storages
-			broker.txConsumer.Consume(transaction)
+			broker.dispatch(transaction)
 		case block := <-broker.blocks:
 			broker.stateConsumer.Consume(block)
 		}
 	}
 }
+
+// drain consumes messages which are already buffered, producers have to be stopped before,
+// otherwise messages sent later are left in channels.
+func (broker *Broker) drain() {
+	drained := 0
+	for {
+		select {
+		case transaction := <-broker.transactions:
+			broker.dispatch(transaction)
+		case block := <-broker.blocks:
+			broker.stateConsumer.Consume(block)
+		default:
+			logging.Module("parser").Info().Int("messages", drained).Msg("Broker drained")
+			return
+		}
+		drained++
+	}
+}
+
+func (broker *Broker) dispatch(transaction model.TracedTransaction) {
+	ctx, span := tracing.Start(
+		tracing.Extract(context.Background(), transaction.TraceParent),
+		"broker.dispatch",
+		attribute.String("transaction.hash", transaction.Hash),
+	)
+	defer span.End()
+	transaction.TraceParent = tracing.Inject(ctx)
+	broker.txConsumer.Consume(transaction)
+}
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/model"
+)
+
+type recordingConsumer[T any] struct {
+	messages []T
+}
+
+func (consumer *recordingConsumer[T]) Consume(message T) {
+	consumer.messages = append(consumer.messages, message)
+}
+
+func TestShouldDrainBufferedMessagesWhenStopped(t *testing.T) {
+	transactions := make(chan model.TracedTransaction, 3)
+	blocks := make(chan int, 1)
+	for _, hash := range []string{"0x1", "0x2", "0x3"} {
+		transactions <- model.TracedTransaction{Transaction: model.Transaction{Hash: hash}}
+	}
+	blocks <- 7
+	txConsumer, stateConsumer := &recordingConsumer[model.TracedTransaction]{}, &recordingConsumer[int]{}
+	broker := NewBroker(transactions, blocks, txConsumer, stateConsumer)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	broker.Start(ctx)
+
+	require.Len(t, txConsumer.messages, 3)
+	require.Equal(t, []int{7}, stateConsumer.messages)
+	require.Empty(t, transactions)
+	require.Empty(t, blocks)
+}
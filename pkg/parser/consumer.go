@@ -1,36 +1,116 @@
 package parser
 
 import (
+	"context"
+	"sync/atomic"
+	"time"
+
 	"github.com/ziollek/etherscription/pkg/config"
 	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/metrics"
 	"github.com/ziollek/etherscription/pkg/model"
 	"github.com/ziollek/etherscription/pkg/storage"
+	"github.com/ziollek/etherscription/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// TransactionConsumerService fans out every transaction to each tenant independently.
 type TransactionConsumerService struct {
-	txStorage  storage.ListSaver[model.Transaction]
-	subStorage storage.KVSaver[string]
-	cfg        *config.StorageConfig
+	txStorage  storage.ListSaver[model.TracedTransaction]
+	subStorage storage.KVSaver[model.Subscription]
+	tenants    TenantLister
+	notifier   Notifier
+	// cfg and quotas are replaced when the configuration is reloaded
+	cfg    atomic.Pointer[config.StorageConfig]
+	quotas atomic.Pointer[config.QuotaConfig]
 }
 
-func NewConsumerService(txStorage storage.ListSaver[model.Transaction], subStorage storage.KVSaver[string], cfg *config.StorageConfig) Consumer[model.Transaction] {
-	return &TransactionConsumerService{
+func NewConsumerService(
+	txStorage storage.ListSaver[model.TracedTransaction],
+	subStorage storage.KVSaver[model.Subscription],
+	tenants TenantLister,
+	notifier Notifier,
+	cfg *config.StorageConfig,
+	quotas *config.QuotaConfig,
+) *TransactionConsumerService {
+	service := &TransactionConsumerService{
 		txStorage:  txStorage,
 		subStorage: subStorage,
-		cfg:        cfg,
+		tenants:    tenants,
+		notifier:   notifier,
+	}
+	service.cfg.Store(cfg)
+	service.quotas.Store(quotas)
+	return service
+}
+
+// SetConfig replaces retention and storing of all transactions, it is safe to call while transactions are consumed.
+func (s *TransactionConsumerService) SetConfig(cfg *config.StorageConfig) {
+	s.cfg.Store(cfg)
+}
+
+// SetQuotas replaces quotas, buffers above a lowered limit are kept, but new transactions are dropped.
+func (s *TransactionConsumerService) SetQuotas(quotas *config.QuotaConfig) {
+	s.quotas.Store(quotas)
+}
+
+func (s *TransactionConsumerService) Consume(transaction model.TracedTransaction) {
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), transaction.TraceParent), "parser.consume")
+	defer span.End()
+	transaction.TraceParent = tracing.Inject(ctx)
+	quotas := s.quotas.Load()
+	for _, tenant := range s.tenants.Names() {
+		limits := quotas.For(tenant)
+		s.dispatch(ctx, model.ScopedKey(tenant, transaction.To), transaction.To, transaction, limits)
+		s.dispatch(ctx, model.ScopedKey(tenant, transaction.From), transaction.From, transaction, limits)
 	}
+	metrics.TransactionsConsumed.Inc()
+	logging.Sampled("parser", "consumed").Info().Str("from", transaction.From).Str("to", transaction.To).Int64("value", transaction.Value).Msgf("consumed")
 }
 
-func (s *TransactionConsumerService) Consume(transaction model.Transaction) {
-	if _, found := s.subStorage.Get(transaction.To); found || s.cfg.StoreAllTransactions {
-		logging.Logger().Debug().Str("module", "parser").Str("subscriber", transaction.To).Msgf("Appending transaction %+v to storage", transaction)
-		s.txStorage.Append(transaction.To, transaction, s.cfg.Retention)
+func (s *TransactionConsumerService) dispatch(ctx context.Context, key, address string, transaction model.TracedTransaction, limits config.QuotaLimits) {
+	subscription, found := s.subStorage.Get(key)
+	// expired subscriptions may wait for the cleaner for a while
+	found = found && subscription.IsActive(time.Now())
+	if found && !subscription.Filter.Matches(address, transaction.Transaction) {
+		// the subscriber explicitly does not want this transaction, even if all transactions are stored
+		return
 	}
-	if _, found := s.subStorage.Get(transaction.From); found || s.cfg.StoreAllTransactions {
-		logging.Logger().Debug().Str("module", "parser").Str("subscriber", transaction.From).Msgf("Appending transaction %+v to storage", transaction)
-		s.txStorage.Append(transaction.From, transaction, s.cfg.Retention)
+	if found && subscription.CallbackURL != "" {
+		// subscribers with a callback get transactions pushed, there is no need to buffer them
+		logging.Module("parser").Debug().Str("subscriber", key).Msgf("Notifying about transaction %+v", transaction)
+		s.notifier.Notify(subscription, transaction)
+		return
 	}
-	logging.Logger().Info().Str("module", "parser").Str("from", transaction.From).Str("to", transaction.To).Int64("value", transaction.Value).Msgf("consumed")
+	if found || s.cfg.Load().StoreAllTransactions {
+		logging.Module("parser").Debug().Str("subscriber", key).Msgf("Appending transaction %+v to storage", transaction)
+		if !s.append(ctx, key, transaction, limits.MaxBufferedTransactions) {
+			logging.Module("parser").Debug().Str("subscriber", key).Msg("Buffered transactions quota exceeded, dropping transaction")
+			metrics.TransactionsDropped.WithLabelValues("quota").Inc()
+		}
+	}
+}
+
+// append stores the transaction with the context of its own span, so a request which serves it can link to it.
+// It returns false when the buffer of the key already holds limit transactions, a non-positive limit means no limit.
+// Storages checking the limit while appending are preferred, others have to count the buffer first.
+func (s *TransactionConsumerService) append(ctx context.Context, key string, transaction model.TracedTransaction, limit int) bool {
+	ctx, span := tracing.Start(ctx, "storage.append", attribute.String("storage.key", key))
+	defer span.End()
+	transaction.TraceParent = tracing.Inject(ctx)
+	retention := s.cfg.Load().Retention
+	if bounded, ok := s.txStorage.(storage.BoundedAppender[model.TracedTransaction]); ok {
+		if !bounded.AppendBounded(key, transaction, retention, limit) {
+			return false
+		}
+	} else {
+		if limit > 0 && s.txStorage.Len(key) >= limit {
+			return false
+		}
+		s.txStorage.Append(key, transaction, retention)
+	}
+	metrics.TransactionsStored.Inc()
+	return true
 }
 
 type StateConsumerService struct {
@@ -44,6 +124,15 @@ func NewStateConsumerService(stateStorage storage.KVSaver[int]) Consumer[int] {
 }
 
 func (s *StateConsumerService) Consume(lastBlock int) {
-	logging.Logger().Info().Str("module", "parser").Int(lastBlockKey, lastBlock).Msgf("Updating last block")
+	logging.Module("parser").Info().Int(lastBlockKey, lastBlock).Msgf("Updating last block")
 	s.stateStorage.Set(lastBlockKey, lastBlock)
 }
+
+// Consumers passes every message to all consumers in order, e.g. to record transaction history next to buffering.
+type Consumers[T any] []Consumer[T]
+
+func (consumers Consumers[T]) Consume(message T) {
+	for _, consumer := range consumers {
+		consumer.Consume(message)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/internal/storage/memory"
 	"github.com/ziollek/etherscription/pkg/config"
 	"github.com/ziollek/etherscription/pkg/model"
 	"github.com/ziollek/etherscription/pkg/storage/mock_storage"
@@ -37,7 +39,7 @@ func TestShouldConsumeParsedTransactions(t *testing.T) {
 		storeAllTx bool
 	}
 	type args struct {
-		transaction       model.Transaction
+		transaction       model.TracedTransaction
 		subscriptionState map[string]bool
 	}
 	type expected struct {
@@ -52,31 +54,31 @@ func TestShouldConsumeParsedTransactions(t *testing.T) {
 		{
 			"Should not store transaction that is not subscribed",
 			fields{time.Second, false},
-			args{model.Transaction{From: "0x1", To: "0x2", Value: 1}, map[string]bool{"0x1": false, "0x2": false}},
+			args{model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}}, map[string]bool{"0x1": false, "0x2": false}},
 			expected{[]string{}},
 		},
 		{
 			"Should store transaction that is subscribed by receiver once",
 			fields{time.Second, false},
-			args{model.Transaction{From: "0x1", To: "0x2", Value: 1}, map[string]bool{"0x1": false, "0x2": true}},
+			args{model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}}, map[string]bool{"0x1": false, "0x2": true}},
 			expected{[]string{"0x2"}},
 		},
 		{
 			"Should store transaction that is subscribed by sender once",
 			fields{time.Second, false},
-			args{model.Transaction{From: "0x1", To: "0x2", Value: 1}, map[string]bool{"0x1": true, "0x2": false}},
+			args{model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}}, map[string]bool{"0x1": true, "0x2": false}},
 			expected{[]string{"0x1"}},
 		},
 		{
 			"Should store transaction that is subscribed by sender & receiver twice",
 			fields{time.Second, false},
-			args{model.Transaction{From: "0x1", To: "0x2", Value: 1}, map[string]bool{"0x1": true, "0x2": true}},
+			args{model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}}, map[string]bool{"0x1": true, "0x2": true}},
 			expected{[]string{"0x1", "0x2"}},
 		},
 		{
 			"Should store transaction that is not subscribed but store all transaction is enabled",
 			fields{time.Second, true},
-			args{model.Transaction{From: "0x1", To: "0x2", Value: 1}, map[string]bool{"0x1": false, "0x2": false}},
+			args{model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}}, map[string]bool{"0x1": false, "0x2": false}},
 			expected{[]string{"0x1", "0x2"}},
 		},
 	}
@@ -84,16 +86,168 @@ func TestShouldConsumeParsedTransactions(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
-			kv := mock_storage.NewMockKVSaver[string](ctrl)
+			kv := mock_storage.NewMockKVSaver[model.Subscription](ctrl)
 			for k, exists := range tt.args.subscriptionState {
-				kv.EXPECT().Get(k).Return("", exists)
+				kv.EXPECT().Get(model.ScopedKey(model.DefaultTenant, k)).Return(model.Subscription{Address: k}, exists)
 			}
-			txStorage := mock_storage.NewMockListSaver[model.Transaction](ctrl)
+			txStorage := mock_storage.NewMockListSaver[model.TracedTransaction](ctrl)
 			for _, address := range tt.expected.shouldAppendFor {
-				txStorage.EXPECT().Append(address, tt.args.transaction, tt.fields.ttl)
+				txStorage.EXPECT().Append(model.ScopedKey(model.DefaultTenant, address), tt.args.transaction, tt.fields.ttl)
 			}
-			s := NewConsumerService(txStorage, kv, &config.StorageConfig{Retention: tt.fields.ttl, StoreAllTransactions: tt.fields.storeAllTx})
+			s := NewConsumerService(txStorage, kv, staticTenants{model.DefaultTenant}, &recordingNotifier{}, &config.StorageConfig{Retention: tt.fields.ttl, StoreAllTransactions: tt.fields.storeAllTx}, &config.QuotaConfig{})
 			s.Consume(tt.args.transaction)
 		})
 	}
 }
+
+type staticTenants []string
+
+func (t staticTenants) Names() []string {
+	return t
+}
+
+type recordingNotifier struct {
+	notified []string
+}
+
+func (n *recordingNotifier) Notify(subscription model.Subscription, _ model.TracedTransaction) {
+	n.notified = append(n.notified, subscription.Address)
+}
+
+func TestShouldNotifySubscribersWithCallbackInsteadOfStoring(t *testing.T) {
+	type args struct {
+		transaction   model.TracedTransaction
+		subscriptions map[string]model.Subscription
+		storeAllTx    bool
+	}
+	expired := time.Now().Add(-time.Second)
+	type expected struct {
+		shouldNotify    []string
+		shouldAppendFor []string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected expected
+	}{
+		{
+			"Should notify receiver with callback",
+			args{
+				model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}},
+				map[string]model.Subscription{"0x2": {Address: "0x2", CallbackURL: "http://localhost/hook"}},
+				false,
+			},
+			expected{[]string{"0x2"}, []string{}},
+		},
+		{
+			"Should notify sender with callback and store for receiver without callback",
+			args{
+				model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}},
+				map[string]model.Subscription{"0x1": {Address: "0x1", CallbackURL: "http://localhost/hook"}, "0x2": {Address: "0x2"}},
+				false,
+			},
+			expected{[]string{"0x1"}, []string{"0x2"}},
+		},
+		{
+			"Should neither notify nor store for expired subscription",
+			args{
+				model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}},
+				map[string]model.Subscription{"0x2": {Address: "0x2", CallbackURL: "http://localhost/hook", ExpiresAt: &expired}},
+				false,
+			},
+			expected{[]string{}, []string{}},
+		},
+		{
+			"Should skip transaction not matching filter even if store all transaction is enabled",
+			args{
+				model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}},
+				map[string]model.Subscription{"0x2": {Address: "0x2", Filter: &model.Filter{Direction: model.DirectionOutgoing}}},
+				true,
+			},
+			expected{[]string{}, []string{"0x1"}},
+		},
+		{
+			"Should not store notified transaction even if store all transaction is enabled",
+			args{
+				model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}},
+				map[string]model.Subscription{"0x2": {Address: "0x2", CallbackURL: "http://localhost/hook"}},
+				true,
+			},
+			expected{[]string{"0x2"}, []string{"0x1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			kv := mock_storage.NewMockKVSaver[model.Subscription](ctrl)
+			for _, address := range []string{tt.args.transaction.From, tt.args.transaction.To} {
+				subscription, exists := tt.args.subscriptions[address]
+				kv.EXPECT().Get(model.ScopedKey(model.DefaultTenant, address)).Return(subscription, exists)
+			}
+			txStorage := mock_storage.NewMockListSaver[model.TracedTransaction](ctrl)
+			for _, address := range tt.expected.shouldAppendFor {
+				txStorage.EXPECT().Append(model.ScopedKey(model.DefaultTenant, address), tt.args.transaction, time.Second)
+			}
+			notifier := &recordingNotifier{}
+			s := NewConsumerService(txStorage, kv, staticTenants{model.DefaultTenant}, notifier, &config.StorageConfig{Retention: time.Second, StoreAllTransactions: tt.args.storeAllTx}, &config.QuotaConfig{})
+			s.Consume(tt.args.transaction)
+			require.ElementsMatch(t, tt.expected.shouldNotify, notifier.notified)
+		})
+	}
+}
+
+func TestShouldFanOutTransactionToEveryTenantIndependently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	transaction := model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}}
+	subscribed := map[string]bool{
+		model.ScopedKey("first", "0x2"):  true,
+		model.ScopedKey("second", "0x2"): true,
+		model.ScopedKey("second", "0x1"): true,
+	}
+	kv := mock_storage.NewMockKVSaver[model.Subscription](ctrl)
+	for _, tenant := range []string{"first", "second"} {
+		for _, address := range []string{"0x1", "0x2"} {
+			key := model.ScopedKey(tenant, address)
+			kv.EXPECT().Get(key).Return(model.Subscription{Tenant: tenant, Address: address}, subscribed[key])
+		}
+	}
+	txStorage := mock_storage.NewMockListSaver[model.TracedTransaction](ctrl)
+	for key := range subscribed {
+		txStorage.EXPECT().Append(key, transaction, time.Second)
+	}
+	s := NewConsumerService(txStorage, kv, staticTenants{"first", "second"}, &recordingNotifier{}, &config.StorageConfig{Retention: time.Second}, &config.QuotaConfig{})
+	s.Consume(transaction)
+}
+
+func TestShouldDropTransactionsExceedingBufferedQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	transaction := model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}}
+	kv := mock_storage.NewMockKVSaver[model.Subscription](ctrl)
+	kv.EXPECT().Get(model.ScopedKey("limited", "0x1")).Return(model.Subscription{Address: "0x1"}, true)
+	kv.EXPECT().Get(model.ScopedKey("limited", "0x2")).Return(model.Subscription{Address: "0x2"}, true)
+	txStorage := mock_storage.NewMockListSaver[model.TracedTransaction](ctrl)
+	txStorage.EXPECT().Len(model.ScopedKey("limited", "0x1")).Return(1)
+	txStorage.EXPECT().Len(model.ScopedKey("limited", "0x2")).Return(2)
+	txStorage.EXPECT().Append(model.ScopedKey("limited", "0x1"), transaction, time.Second)
+	quotas := &config.QuotaConfig{Tenants: map[string]config.QuotaLimits{"limited": {MaxBufferedTransactions: 2}}}
+	s := NewConsumerService(txStorage, kv, staticTenants{"limited"}, &recordingNotifier{}, &config.StorageConfig{Retention: time.Second}, quotas)
+	s.Consume(transaction)
+}
+
+func TestShouldCheckBufferedQuotaWhileAppending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	kv := mock_storage.NewMockKVSaver[model.Subscription](ctrl)
+	kv.EXPECT().Get(model.ScopedKey("limited", "0x1")).Return(model.Subscription{Address: "0x1"}, false).AnyTimes()
+	kv.EXPECT().Get(model.ScopedKey("limited", "0x2")).Return(model.Subscription{Address: "0x2"}, true).AnyTimes()
+	txStorage := memory.NewListStorage[model.TracedTransaction]()
+	quotas := &config.QuotaConfig{Tenants: map[string]config.QuotaLimits{"limited": {MaxBufferedTransactions: 2}}}
+	s := NewConsumerService(txStorage, kv, staticTenants{"limited"}, &recordingNotifier{}, &config.StorageConfig{Retention: time.Second}, quotas)
+	for _, hash := range []string{"0x10", "0x11", "0x12"} {
+		s.Consume(model.TracedTransaction{Transaction: model.Transaction{Hash: hash, From: "0x1", To: "0x2"}})
+	}
+	require.Equal(t, 2, txStorage.Len(model.ScopedKey("limited", "0x2")))
+}
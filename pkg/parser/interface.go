@@ -1,14 +1,63 @@
 package parser
 
-import "github.com/ziollek/etherscription/pkg/model"
+import (
+	"errors"
 
+	"github.com/ziollek/etherscription/pkg/model"
+)
+
+var ErrQuotaExceeded = errors.New("subscriptions quota exceeded")
+
+// SubscribeResult tells whether a new subscription has been created, Err is set when it has been rejected.
+type SubscribeResult struct {
+	Created bool
+	Err     error
+}
+
+// Parser scopes all subscription related operations to a tenant.
 type Parser interface {
 	GetCurrentBlock() int
-	Subscribe(address string) bool
-	GetTransactions(address string) []model.Transaction
-	IsSubscribed(address string) bool
+	Subscribe(tenant string, subscription model.Subscription) (bool, error)
+	Unsubscribe(tenant, address string) bool
+	// SubscribeMany and UnsubscribeMany process the whole batch atomically and report the result for every entry
+	SubscribeMany(tenant string, subscriptions []model.Subscription) []SubscribeResult
+	UnsubscribeMany(tenant string, addresses []string) []bool
+	// UnsubscribeAll removes all subscriptions of the tenant and returns their number
+	UnsubscribeAll(tenant string) int
+	// Renew extends the expiration of the subscription by its TTL
+	Renew(tenant, address string) (model.Subscription, bool)
+	GetSubscription(tenant, address string) (model.Subscription, bool)
+	// GetSubscriptions returns a page of subscriptions ordered by address and the total number of subscriptions
+	GetSubscriptions(tenant string, offset, limit int) ([]model.Subscription, int)
+	CountTransactions(tenant, address string) int
+	CountSubscriptions(tenant string) int
+	// CountBufferedTransactions sums transactions buffered for all subscriptions of the tenant
+	CountBufferedTransactions(tenant string) int
+	// GetTransactions returns transactions with their trace contexts, which must not be exposed
+	GetTransactions(tenant, address string) []model.TracedTransaction
+	GetDeadLetters(tenant, address string) []model.DeadLetter
+	// DeleteDeadLetters removes dead letters of the subscription and returns their number
+	DeleteDeadLetters(tenant, address string) int
+	IsSubscribed(tenant, address string) bool
+}
+
+type Tenants interface {
+	IsEnabled() bool
+	Authenticate(apiKey string) (model.Tenant, bool)
+	GetTenants() []model.Tenant
+	CreateTenant(tenant model.Tenant) bool
+	DeleteTenant(name string) bool
+}
+
+// TenantLister provides names of all tenants, so transactions can be fanned out to each of them.
+type TenantLister interface {
+	Names() []string
 }
 
 type Consumer[T any] interface {
 	Consume(T)
 }
+
+type Notifier interface {
+	Notify(subscription model.Subscription, transaction model.TracedTransaction)
+}
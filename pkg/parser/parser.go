@@ -1,6 +1,13 @@
 package parser
 
 import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/config"
 	"github.com/ziollek/etherscription/pkg/model"
 	"github.com/ziollek/etherscription/pkg/storage"
 )
@@ -9,18 +16,38 @@ const (
 	lastBlockKey = "last_block"
 )
 
+// SubscriptionService keeps subscriptions, buffered transactions and dead letters under keys scoped by tenant.
 type SubscriptionService struct {
-	txStorage    storage.ListSaver[model.Transaction]
-	subStorage   storage.KVSaver[string]
-	stateStorage storage.KVSaver[int]
+	txStorage         storage.ListSaver[model.TracedTransaction]
+	subStorage        storage.KVSaver[model.Subscription]
+	stateStorage      storage.KVSaver[int]
+	deadLetterStorage storage.ListSaver[model.DeadLetter]
+	// quotas are replaced when the configuration is reloaded
+	quotas atomic.Pointer[config.QuotaConfig]
+	// guards read-modify-write operations on subscriptions
+	mutex sync.Mutex
 }
 
-func NewSubscriptionService(txStorage storage.ListSaver[model.Transaction], subStorage storage.KVSaver[string], stateStorage storage.KVSaver[int]) Parser {
-	return &SubscriptionService{
-		txStorage:    txStorage,
-		subStorage:   subStorage,
-		stateStorage: stateStorage,
+func NewSubscriptionService(
+	txStorage storage.ListSaver[model.TracedTransaction],
+	subStorage storage.KVSaver[model.Subscription],
+	stateStorage storage.KVSaver[int],
+	deadLetterStorage storage.ListSaver[model.DeadLetter],
+	quotas *config.QuotaConfig,
+) *SubscriptionService {
+	service := &SubscriptionService{
+		txStorage:         txStorage,
+		subStorage:        subStorage,
+		stateStorage:      stateStorage,
+		deadLetterStorage: deadLetterStorage,
 	}
+	service.quotas.Store(quotas)
+	return service
+}
+
+// SetQuotas replaces quotas, subscriptions above a lowered limit are kept, but new ones are rejected.
+func (service *SubscriptionService) SetQuotas(quotas *config.QuotaConfig) {
+	service.quotas.Store(quotas)
 }
 
 func (service *SubscriptionService) GetCurrentBlock() int {
@@ -30,19 +57,205 @@ func (service *SubscriptionService) GetCurrentBlock() int {
 	return 0
 }
 
-func (service *SubscriptionService) Subscribe(address string) bool {
-	if _, found := service.subStorage.Get(address); !found {
-		service.subStorage.Set(address, address)
-		return true
+func (service *SubscriptionService) Subscribe(tenant string, subscription model.Subscription) (bool, error) {
+	result := service.SubscribeMany(tenant, []model.Subscription{subscription})[0]
+	return result.Created, result.Err
+}
+
+// SubscribeMany checks the quota and stores subscriptions atomically in storages shared by replicas,
+// see storage.SetWithinLimit.
+func (service *SubscriptionService) SubscribeMany(tenant string, subscriptions []model.Subscription) []SubscribeResult {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	now := time.Now()
+	results := make([]SubscribeResult, len(subscriptions))
+	keys := make([]string, 0, len(subscriptions))
+	values := make([]model.Subscription, 0, len(subscriptions))
+	positions := make([]int, 0, len(subscriptions))
+	pending := make(map[string]bool, len(subscriptions))
+	for i, subscription := range subscriptions {
+		subscription.Tenant = tenant
+		key := subscription.Key()
+		if _, found := service.getActive(key); found || pending[key] {
+			continue
+		}
+		// an expired subscription is going to be replaced, so it does not count,
+		// data buffered for it must not be served to the new subscription
+		service.remove(key)
+		subscription.CreatedAt = now
+		subscription.Renew(now)
+		pending[key] = true
+		keys = append(keys, key)
+		values = append(values, subscription)
+		positions = append(positions, i)
+	}
+	limit := service.quotas.Load().For(tenant).MaxSubscriptions
+	for i, set := range storage.SetWithinLimit(service.subStorage, model.ScopedKey(tenant, ""), limit, keys, values) {
+		results[positions[i]].Created = set
+		if !set {
+			results[positions[i]].Err = ErrQuotaExceeded
+		}
 	}
-	return false
+	return results
 }
 
-func (service *SubscriptionService) IsSubscribed(address string) bool {
-	_, found := service.subStorage.Get(address)
+func (service *SubscriptionService) Unsubscribe(tenant, address string) bool {
+	return service.UnsubscribeMany(tenant, []string{address})[0]
+}
+
+func (service *SubscriptionService) UnsubscribeMany(tenant string, addresses []string) []bool {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	results := make([]bool, len(addresses))
+	for i, address := range addresses {
+		key := model.ScopedKey(tenant, address)
+		_, active := service.getActive(key)
+		// expired subscriptions are removed as well, but they are reported as missing
+		results[i] = service.remove(key) && active
+	}
+	return results
+}
+
+func (service *SubscriptionService) UnsubscribeAll(tenant string) int {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	removed := 0
+	for _, key := range service.tenantKeys(tenant) {
+		if service.remove(key) {
+			removed++
+		}
+	}
+	return removed
+}
+
+func (service *SubscriptionService) Renew(tenant, address string) (model.Subscription, bool) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	subscription, found := service.getActive(model.ScopedKey(tenant, address))
+	if found {
+		subscription.Renew(time.Now())
+		service.subStorage.Set(subscription.Key(), subscription)
+	}
+	return subscription, found
+}
+
+func (service *SubscriptionService) IsSubscribed(tenant, address string) bool {
+	_, found := service.getActive(model.ScopedKey(tenant, address))
 	return found
 }
 
-func (service *SubscriptionService) GetTransactions(address string) []model.Transaction {
-	return service.txStorage.FetchAndFlush(address)
+func (service *SubscriptionService) GetSubscription(tenant, address string) (model.Subscription, bool) {
+	return service.getActive(model.ScopedKey(tenant, address))
+}
+
+func (service *SubscriptionService) GetSubscriptions(tenant string, offset, limit int) ([]model.Subscription, int) {
+	keys := service.tenantKeys(tenant)
+	subscriptions := make([]model.Subscription, 0, limit)
+	for _, key := range keys[min(offset, len(keys)):min(offset+limit, len(keys))] {
+		// the subscription could have been removed in the meantime
+		if subscription, found := service.getActive(key); found {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, len(keys)
+}
+
+func (service *SubscriptionService) CountTransactions(tenant, address string) int {
+	return service.txStorage.Len(model.ScopedKey(tenant, address))
+}
+
+func (service *SubscriptionService) CountSubscriptions(tenant string) int {
+	return len(service.tenantKeys(tenant))
+}
+
+func (service *SubscriptionService) CountBufferedTransactions(tenant string) int {
+	count := 0
+	for _, key := range service.tenantKeys(tenant) {
+		count += service.txStorage.Len(key)
+	}
+	return count
+}
+
+func (service *SubscriptionService) GetTransactions(tenant, address string) []model.TracedTransaction {
+	key := model.ScopedKey(tenant, address)
+	transactions := service.txStorage.FetchAndFlush(key)
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	// fetching works as a heartbeat for subscriptions with TTL
+	if subscription, found := service.getActive(key); found {
+		now := time.Now()
+		subscription.Renew(now)
+		if len(transactions) > 0 {
+			subscription.LastDeliveryAt = &now
+		}
+		service.subStorage.Set(key, subscription)
+	}
+	return transactions
+}
+
+// GetDeadLetters does not remove dead letters, they are kept until DeleteDeadLetters or the end of their retention.
+func (service *SubscriptionService) GetDeadLetters(tenant, address string) []model.DeadLetter {
+	return service.deadLetterStorage.Peek(model.ScopedKey(tenant, address))
+}
+
+// DeleteDeadLetters acknowledges dead letters of the subscription and returns their number.
+func (service *SubscriptionService) DeleteDeadLetters(tenant, address string) int {
+	key := model.ScopedKey(tenant, address)
+	removed := service.deadLetterStorage.Len(key)
+	service.deadLetterStorage.Delete(key)
+	return removed
+}
+
+func (service *SubscriptionService) RecordDelivery(subscription model.Subscription, at time.Time) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if current, found := service.getActive(subscription.Key()); found {
+		current.LastDeliveryAt = &at
+		service.subStorage.Set(current.Key(), current)
+	}
+}
+
+// Expire removes expired subscriptions together with their buffered data, it is run by the cleaner.
+func (service *SubscriptionService) Expire(now time.Time) int {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	expired := 0
+	for _, key := range service.subStorage.Keys() {
+		if subscription, found := service.subStorage.Get(key); found && !subscription.IsActive(now) {
+			service.remove(key)
+			expired++
+		}
+	}
+	return expired
+}
+
+// tenantKeys returns sorted storage keys of all subscriptions owned by the tenant.
+func (service *SubscriptionService) tenantKeys(tenant string) []string {
+	prefix := model.ScopedKey(tenant, "")
+	keys := make([]string, 0)
+	for _, key := range service.subStorage.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (service *SubscriptionService) getActive(key string) (model.Subscription, bool) {
+	subscription, found := service.subStorage.Get(key)
+	if !found || !subscription.IsActive(time.Now()) {
+		return model.Subscription{}, false
+	}
+	return subscription, true
+}
+
+func (service *SubscriptionService) remove(key string) bool {
+	if !service.subStorage.Delete(key) {
+		return false
+	}
+	// buffered data would not be reachable anymore
+	service.txStorage.Delete(key)
+	service.deadLetterStorage.Delete(key)
+	return true
 }
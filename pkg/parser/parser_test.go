@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/internal/storage/memory"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/model"
+)
+
+const (
+	testTenant           = model.DefaultTenant
+	testMaxSubscriptions = 3
+)
+
+func newTestSubscriptionService() (*SubscriptionService, *memory.ListStorage[model.TracedTransaction]) {
+	txStorage := memory.NewListStorage[model.TracedTransaction]()
+	return NewSubscriptionService(
+		txStorage,
+		memory.NewKVStorage[model.Subscription](),
+		memory.NewKVStorage[int](),
+		memory.NewListStorage[model.DeadLetter](),
+		&config.QuotaConfig{QuotaLimits: config.QuotaLimits{MaxSubscriptions: testMaxSubscriptions}},
+	), txStorage
+}
+
+func mustSubscribe(t *testing.T, service *SubscriptionService, tenant string, subscription model.Subscription) {
+	created, err := service.Subscribe(tenant, subscription)
+	require.NoError(t, err)
+	require.True(t, created)
+}
+
+func TestShouldPaginateSubscriptionsByAddress(t *testing.T) {
+	type args struct {
+		offset int
+		limit  int
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{"Should return first page", args{0, 2}, []string{"0x1", "0x2"}},
+		{"Should return last incomplete page", args{2, 2}, []string{"0x3"}},
+		{"Should return empty page for offset out of range", args{5, 2}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := newTestSubscriptionService()
+			for _, address := range []string{"0x3", "0x1", "0x2"} {
+				mustSubscribe(t, service, testTenant, model.Subscription{Address: address})
+			}
+			subscriptions, total := service.GetSubscriptions(testTenant, tt.args.offset, tt.args.limit)
+			addresses := make([]string, 0, len(subscriptions))
+			for _, subscription := range subscriptions {
+				addresses = append(addresses, subscription.Address)
+			}
+			require.Equal(t, tt.want, addresses)
+			require.Equal(t, 3, total)
+		})
+	}
+}
+
+func TestShouldRemoveBufferedTransactionsOnUnsubscribe(t *testing.T) {
+	service, txStorage := newTestSubscriptionService()
+	mustSubscribe(t, service, testTenant, model.Subscription{Address: "0x1"})
+	txStorage.Append(model.ScopedKey(testTenant, "0x1"), model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2"}}, time.Minute)
+	require.Equal(t, 1, service.CountTransactions(testTenant, "0x1"))
+
+	require.True(t, service.Unsubscribe(testTenant, "0x1"))
+	require.False(t, service.Unsubscribe(testTenant, "0x1"))
+	require.False(t, service.IsSubscribed(testTenant, "0x1"))
+	require.Equal(t, 0, service.CountTransactions(testTenant, "0x1"))
+}
+
+func TestShouldRecordDeliveryWhenTransactionsAreFetched(t *testing.T) {
+	service, txStorage := newTestSubscriptionService()
+	mustSubscribe(t, service, testTenant, model.Subscription{Address: "0x1"})
+	subscription, _ := service.GetSubscription(testTenant, "0x1")
+	require.False(t, subscription.CreatedAt.IsZero())
+	require.Nil(t, subscription.LastDeliveryAt)
+
+	require.Empty(t, service.GetTransactions(testTenant, "0x1"))
+	subscription, _ = service.GetSubscription(testTenant, "0x1")
+	require.Nil(t, subscription.LastDeliveryAt)
+
+	txStorage.Append(model.ScopedKey(testTenant, "0x1"), model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2"}}, time.Minute)
+	require.Len(t, service.GetTransactions(testTenant, "0x1"), 1)
+	subscription, _ = service.GetSubscription(testTenant, "0x1")
+	require.NotNil(t, subscription.LastDeliveryAt)
+}
+
+func TestShouldExpireSubscriptionsWithTheirBufferedTransactions(t *testing.T) {
+	service, txStorage := newTestSubscriptionService()
+	mustSubscribe(t, service, testTenant, model.Subscription{Address: "0x1", TTL: model.Duration(time.Minute)})
+	mustSubscribe(t, service, testTenant, model.Subscription{Address: "0x2"})
+	txStorage.Append(model.ScopedKey(testTenant, "0x1"), model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2"}}, time.Hour)
+
+	require.Equal(t, 0, service.Expire(time.Now()))
+	require.Equal(t, 1, service.Expire(time.Now().Add(time.Hour)))
+	require.False(t, service.IsSubscribed(testTenant, "0x1"))
+	require.True(t, service.IsSubscribed(testTenant, "0x2"))
+	require.Equal(t, 0, service.CountTransactions(testTenant, "0x1"))
+}
+
+func TestShouldRenewSubscriptionWithTTL(t *testing.T) {
+	service, _ := newTestSubscriptionService()
+	mustSubscribe(t, service, testTenant, model.Subscription{Address: "0x1", TTL: model.Duration(time.Minute)})
+	subscription, _ := service.GetSubscription(testTenant, "0x1")
+	firstExpiration := *subscription.ExpiresAt
+
+	renewed, found := service.Renew(testTenant, "0x1")
+	require.True(t, found)
+	require.True(t, renewed.ExpiresAt.After(firstExpiration))
+
+	_, found = service.Renew(testTenant, "0x2")
+	require.False(t, found)
+}
+
+func TestShouldTreatExpiredSubscriptionAsMissing(t *testing.T) {
+	service, _ := newTestSubscriptionService()
+	expiresAt := time.Now().Add(-time.Second)
+	service.subStorage.Set(model.ScopedKey(testTenant, "0x1"), model.Subscription{Tenant: testTenant, Address: "0x1", TTL: model.Duration(time.Minute), ExpiresAt: &expiresAt})
+
+	require.False(t, service.IsSubscribed(testTenant, "0x1"))
+	_, found := service.Renew(testTenant, "0x1")
+	require.False(t, found)
+	require.False(t, service.Unsubscribe(testTenant, "0x1"))
+	mustSubscribe(t, service, testTenant, model.Subscription{Address: "0x1"})
+}
+
+func TestShouldNotServeDataOfReplacedExpiredSubscription(t *testing.T) {
+	service, txStorage := newTestSubscriptionService()
+	key := model.ScopedKey(testTenant, "0x1")
+	expiresAt := time.Now().Add(-time.Second)
+	service.subStorage.Set(key, model.Subscription{Tenant: testTenant, Address: "0x1", TTL: model.Duration(time.Minute), ExpiresAt: &expiresAt})
+	txStorage.Append(key, model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2"}}, time.Hour)
+	service.deadLetterStorage.Append(key, model.DeadLetter{LastError: "timeout"}, time.Hour)
+
+	mustSubscribe(t, service, testTenant, model.Subscription{Address: "0x1"})
+
+	require.Empty(t, service.GetTransactions(testTenant, "0x1"))
+	require.Empty(t, service.GetDeadLetters(testTenant, "0x1"))
+}
+
+func TestShouldReportResultForEveryEntryOfBatch(t *testing.T) {
+	service, _ := newTestSubscriptionService()
+	mustSubscribe(t, service, testTenant, model.Subscription{Address: "0x1"})
+
+	require.Equal(t,
+		[]SubscribeResult{{}, {Created: true}, {}, {Created: true}, {Err: ErrQuotaExceeded}},
+		service.SubscribeMany(testTenant, []model.Subscription{{Address: "0x1"}, {Address: "0x2"}, {Address: "0x2"}, {Address: "0x3"}, {Address: "0x4"}}),
+	)
+	require.Equal(t, []bool{true, true, true, false}, service.UnsubscribeMany(testTenant, []string{"0x1", "0x2", "0x3", "0x4"}))
+	_, total := service.GetSubscriptions(testTenant, 0, 10)
+	require.Equal(t, 0, total)
+}
+
+func TestShouldIsolateSubscriptionsOfTenants(t *testing.T) {
+	service, txStorage := newTestSubscriptionService()
+	mustSubscribe(t, service, "first", model.Subscription{Address: "0x1"})
+	mustSubscribe(t, service, "second", model.Subscription{Address: "0x1"})
+	mustSubscribe(t, service, "second", model.Subscription{Address: "0x2"})
+	txStorage.Append(model.ScopedKey("first", "0x1"), model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x3"}}, time.Minute)
+	txStorage.Append(model.ScopedKey("second", "0x1"), model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x3"}}, time.Minute)
+
+	require.Len(t, service.GetTransactions("first", "0x1"), 1)
+	require.Len(t, service.GetTransactions("second", "0x1"), 1)
+	require.False(t, service.IsSubscribed("first", "0x2"))
+	_, total := service.GetSubscriptions("second", 0, 10)
+	require.Equal(t, 2, total)
+
+	require.Equal(t, 2, service.UnsubscribeAll("second"))
+	require.True(t, service.IsSubscribed("first", "0x1"))
+	require.False(t, service.IsSubscribed("second", "0x1"))
+}
+
+func TestShouldKeepDeadLettersUntilDeleted(t *testing.T) {
+	deadLetters := memory.NewListStorage[model.DeadLetter]()
+	service := NewSubscriptionService(
+		memory.NewListStorage[model.TracedTransaction](),
+		memory.NewKVStorage[model.Subscription](),
+		memory.NewKVStorage[int](),
+		deadLetters,
+		&config.QuotaConfig{},
+	)
+	mustSubscribe(t, service, testTenant, model.Subscription{Address: "0x1", CallbackURL: "https://example.com/hook"})
+	deadLetters.Append(model.ScopedKey(testTenant, "0x1"), model.DeadLetter{LastError: "timeout"}, time.Minute)
+
+	require.Len(t, service.GetDeadLetters(testTenant, "0x1"), 1)
+	require.Len(t, service.GetDeadLetters(testTenant, "0x1"), 1)
+	require.Equal(t, 1, service.DeleteDeadLetters(testTenant, "0x1"))
+	require.Empty(t, service.GetDeadLetters(testTenant, "0x1"))
+	require.Equal(t, 0, service.DeleteDeadLetters(testTenant, "0x1"))
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+// namesTTL bounds how long tenants created or deleted by other replicas sharing the storage stay unnoticed.
+const namesTTL = 10 * time.Second
+
+// TenantService keeps tenants indexed by their API keys.
+// When authentication is disabled, there is only the default tenant.
+type TenantService struct {
+	tenantStorage storage.KVSaver[model.Tenant]
+	enabled       bool
+	// guards uniqueness of tenant names
+	mutex sync.Mutex
+	// names are needed for every consumed transaction, so they are cached until tenants change or namesTTL passes,
+	// namesMutex is held while they are loaded, so an invalidation cannot be overwritten by names loaded before it
+	names      []string
+	namesAt    time.Time
+	namesMutex sync.Mutex
+}
+
+func NewTenantService(tenantStorage storage.KVSaver[model.Tenant], cfg *config.AuthConfig) *TenantService {
+	service := &TenantService{
+		tenantStorage: tenantStorage,
+		enabled:       cfg.Enabled,
+	}
+	service.SetTenants(cfg.Tenants)
+	return service
+}
+
+// SetTenants reconciles stored tenants with configured ones, it is called on startup and on every reload.
+// Stored keys of configured tenants which differ from configured ones are removed, as well as keys of tenants
+// which were configured before and are no longer, so rotated or removed keys stop authenticating.
+func (service *TenantService) SetTenants(configured []config.TenantConfig) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	keys := make(map[string]string, len(configured))
+	for _, tenant := range configured {
+		keys[tenant.Name] = tenant.APIKey
+	}
+	for _, tenant := range service.GetTenants() {
+		key, found := keys[tenant.Name]
+		if (found && key != tenant.APIKey) || (!found && tenant.Managed) {
+			service.tenantStorage.Delete(tenant.APIKey)
+		}
+	}
+	for _, tenant := range configured {
+		service.tenantStorage.Set(tenant.APIKey, model.Tenant{Name: tenant.Name, APIKey: tenant.APIKey, Managed: true})
+	}
+	service.invalidateNames()
+}
+
+func (service *TenantService) IsEnabled() bool {
+	return service.enabled
+}
+
+func (service *TenantService) Authenticate(apiKey string) (model.Tenant, bool) {
+	if !service.enabled {
+		return model.Tenant{Name: model.DefaultTenant}, true
+	}
+	if apiKey == "" {
+		return model.Tenant{}, false
+	}
+	return service.tenantStorage.Get(apiKey)
+}
+
+// Names returns cached names of tenants, the returned slice must not be modified.
+func (service *TenantService) Names() []string {
+	if !service.enabled {
+		return []string{model.DefaultTenant}
+	}
+	service.namesMutex.Lock()
+	defer service.namesMutex.Unlock()
+	if service.names == nil || time.Since(service.namesAt) >= namesTTL {
+		tenants := service.GetTenants()
+		names := make([]string, len(tenants))
+		for i, tenant := range tenants {
+			names[i] = tenant.Name
+		}
+		service.names, service.namesAt = names, time.Now()
+	}
+	return service.names
+}
+
+func (service *TenantService) invalidateNames() {
+	service.namesMutex.Lock()
+	defer service.namesMutex.Unlock()
+	service.names = nil
+}
+
+// GetTenants returns tenants ordered by name.
+func (service *TenantService) GetTenants() []model.Tenant {
+	tenants := make([]model.Tenant, 0)
+	for _, key := range service.tenantStorage.Keys() {
+		if tenant, found := service.tenantStorage.Get(key); found {
+			tenants = append(tenants, tenant)
+		}
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].Name < tenants[j].Name
+	})
+	return tenants
+}
+
+// CreateTenant returns false when either the name or the API key is already taken.
+func (service *TenantService) CreateTenant(tenant model.Tenant) bool {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if _, found := service.tenantStorage.Get(tenant.APIKey); found {
+		return false
+	}
+	if _, found := service.find(tenant.Name); found {
+		return false
+	}
+	service.tenantStorage.Set(tenant.APIKey, tenant)
+	service.invalidateNames()
+	return true
+}
+
+func (service *TenantService) DeleteTenant(name string) bool {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if tenant, found := service.find(name); found {
+		deleted := service.tenantStorage.Delete(tenant.APIKey)
+		service.invalidateNames()
+		return deleted
+	}
+	return false
+}
+
+func (service *TenantService) find(name string) (model.Tenant, bool) {
+	for _, tenant := range service.GetTenants() {
+		if tenant.Name == name {
+			return tenant, true
+		}
+	}
+	return model.Tenant{}, false
+}
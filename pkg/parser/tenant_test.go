@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/internal/storage/memory"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+func TestShouldAuthenticateTenantsByAPIKey(t *testing.T) {
+	type want struct {
+		tenant string
+		found  bool
+	}
+	tests := []struct {
+		name   string
+		cfg    config.AuthConfig
+		apiKey string
+		want   want
+	}{
+		{"Should use default tenant when authentication is disabled", config.AuthConfig{}, "", want{model.DefaultTenant, true}},
+		{
+			"Should find configured tenant",
+			config.AuthConfig{Enabled: true, Tenants: []config.TenantConfig{{Name: "payments", APIKey: "secret"}}},
+			"secret",
+			want{"payments", true},
+		},
+		{
+			"Should reject unknown key",
+			config.AuthConfig{Enabled: true, Tenants: []config.TenantConfig{{Name: "payments", APIKey: "secret"}}},
+			"other",
+			want{"", false},
+		},
+		{"Should reject missing key", config.AuthConfig{Enabled: true}, "", want{"", false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewTenantService(memory.NewKVStorage[model.Tenant](), &tt.cfg)
+			tenant, found := service.Authenticate(tt.apiKey)
+			require.Equal(t, tt.want.found, found)
+			require.Equal(t, tt.want.tenant, tenant.Name)
+		})
+	}
+}
+
+func TestShouldKeepTenantNamesAndKeysUnique(t *testing.T) {
+	service := NewTenantService(memory.NewKVStorage[model.Tenant](), &config.AuthConfig{Enabled: true})
+	require.True(t, service.CreateTenant(model.Tenant{Name: "payments", APIKey: "first"}))
+	require.False(t, service.CreateTenant(model.Tenant{Name: "payments", APIKey: "second"}))
+	require.False(t, service.CreateTenant(model.Tenant{Name: "compliance", APIKey: "first"}))
+	require.True(t, service.CreateTenant(model.Tenant{Name: "compliance", APIKey: "second"}))
+	require.Equal(t, []string{"compliance", "payments"}, service.Names())
+
+	require.True(t, service.DeleteTenant("payments"))
+	require.False(t, service.DeleteTenant("payments"))
+	_, found := service.Authenticate("first")
+	require.False(t, found)
+	require.Equal(t, []string{"compliance"}, service.Names())
+}
+
+func TestShouldReconcileConfiguredTenantsByName(t *testing.T) {
+	tenantStorage := memory.NewKVStorage[model.Tenant]()
+	service := NewTenantService(tenantStorage, &config.AuthConfig{
+		Enabled: true,
+		Tenants: []config.TenantConfig{{Name: "payments", APIKey: "first"}, {Name: "compliance", APIKey: "second"}},
+	})
+	require.True(t, service.CreateTenant(model.Tenant{Name: "analytics", APIKey: "third"}))
+
+	// keys survive a restart in persistent storages
+	service = NewTenantService(tenantStorage, &config.AuthConfig{
+		Enabled: true,
+		Tenants: []config.TenantConfig{{Name: "payments", APIKey: "rotated"}},
+	})
+	for key, want := range map[string]bool{"first": false, "second": false, "third": true, "rotated": true} {
+		_, found := service.Authenticate(key)
+		require.Equal(t, want, found, key)
+	}
+
+	service.SetTenants([]config.TenantConfig{{Name: "payments", APIKey: "again"}})
+	_, found := service.Authenticate("rotated")
+	require.False(t, found)
+	tenant, found := service.Authenticate("again")
+	require.True(t, found)
+	require.Equal(t, "payments", tenant.Name)
+	require.Equal(t, []string{"analytics", "payments"}, service.Names())
+}
+
+// countingTenants counts listings of stored tenants.
+type countingTenants struct {
+	storage.KVSaver[model.Tenant]
+	listed int
+}
+
+func (c *countingTenants) Keys() []string {
+	c.listed++
+	return c.KVSaver.Keys()
+}
+
+func TestShouldCacheTenantNamesUntilTenantsChange(t *testing.T) {
+	tenantStorage := &countingTenants{KVSaver: memory.NewKVStorage[model.Tenant]()}
+	service := NewTenantService(tenantStorage, &config.AuthConfig{
+		Enabled: true,
+		Tenants: []config.TenantConfig{{Name: "payments", APIKey: "first"}},
+	})
+	tenantStorage.listed = 0
+
+	require.Equal(t, []string{"payments"}, service.Names())
+	require.Equal(t, []string{"payments"}, service.Names())
+	require.Equal(t, 1, tenantStorage.listed)
+
+	require.True(t, service.CreateTenant(model.Tenant{Name: "compliance", APIKey: "second"}))
+	require.Equal(t, []string{"compliance", "payments"}, service.Names())
+	require.True(t, service.DeleteTenant("payments"))
+	require.Equal(t, []string{"compliance"}, service.Names())
+	service.SetTenants([]config.TenantConfig{{Name: "payments", APIKey: "third"}})
+	listed := tenantStorage.listed
+	require.Equal(t, []string{"compliance", "payments"}, service.Names())
+	require.Equal(t, []string{"compliance", "payments"}, service.Names())
+	require.Equal(t, listed+1, tenantStorage.listed)
+}
@@ -0,0 +1,18 @@
+package storage
+
+import "github.com/ziollek/etherscription/pkg/model"
+
+// HistoryQuery narrows transaction history, zero values are not taken into account.
+type HistoryQuery struct {
+	Address   string
+	Hash      string
+	FromBlock int
+	ToBlock   int
+	Offset    int
+	Limit     int
+}
+
+// History keeps every consumed transaction, regardless of whether it was already fetched by subscribers.
+type History interface {
+	Query(query HistoryQuery) ([]model.Transaction, error)
+}
@@ -1,13 +1,101 @@
 package storage
 
-import "time"
+import (
+	"context"
+	"strings"
+	"time"
+)
 
 type KVSaver[T any] interface {
 	Get(key string) (T, bool)
 	Set(key string, value T)
+	Delete(key string) bool
+	Keys() []string
+	// Scan returns up to limit keys following the cursor in lexicographical order and the cursor of the next page.
+	// An empty cursor starts from the beginning, an empty next cursor means that there are no more keys.
+	Scan(cursor string, limit int) ([]string, string)
 }
 
 type ListSaver[T any] interface {
 	FetchAndFlush(key string) []T
 	Append(key string, value T, ttl time.Duration)
+	Len(key string) int
+	// Peek returns not expired values without removing them.
+	Peek(key string) []T
+	// Range returns not expired items matching the query without removing them.
+	Range(key string, query RangeQuery) []Item[T]
+	Delete(key string) bool
+	Keys() []string
+	Scan(cursor string, limit int) ([]string, string)
+}
+
+// BoundedAppender is implemented by list storages which can check the length of the list while appending,
+// without reading the whole list. Outdated entries which are not cleaned yet are counted as well.
+type BoundedAppender[T any] interface {
+	// AppendBounded does not append the value and returns false when the list already has limit entries.
+	AppendBounded(key string, value T, ttl time.Duration, limit int) bool
+}
+
+// Cleanable is a list storage which can drop outdated entries key by key, it is used by the cleaner.
+type Cleanable interface {
+	Keys() []string
+	CleanOutdated(key string) (int, int)
+}
+
+// Expirer is implemented by components which remove outdated data on the cleaner's schedule.
+// It returns the number of removed entries.
+type Expirer interface {
+	Expire(now time.Time) int
+}
+
+// Counter is implemented by storages which can count their keys without reading all of them.
+type Counter interface {
+	Count() int
+}
+
+// Stats describes the size of a storage, Entries is -1 when the storage cannot count them without reading all lists.
+type Stats struct {
+	Keys    int `json:"keys"`
+	Entries int `json:"entries"`
+}
+
+// LimitedSaver is implemented by KV storages shared by replicas, which have to count keys and set values atomically.
+// SetWithinLimit sets values in order while fewer than limit keys start with prefix, existing keys are always
+// overwritten and non-positive limit means no limit. It reports which values were set.
+type LimitedSaver[T any] interface {
+	SetWithinLimit(prefix string, limit int, keys []string, values []T) []bool
+}
+
+// SetWithinLimit uses LimitedSaver when the storage implements it, otherwise keys are counted and values are set
+// one by one, so callers of storages used by a single process have to serialize calls on their own.
+func SetWithinLimit[T any](saver KVSaver[T], prefix string, limit int, keys []string, values []T) []bool {
+	if limited, ok := saver.(LimitedSaver[T]); ok {
+		return limited.SetWithinLimit(prefix, limit, keys, values)
+	}
+	count := 0
+	for _, key := range saver.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			count++
+		}
+	}
+	results := make([]bool, len(keys))
+	for i, key := range keys {
+		if _, exists := saver.Get(key); !exists {
+			if limit > 0 && count >= limit {
+				continue
+			}
+			count++
+		}
+		saver.Set(key, values[i])
+		results[i] = true
+	}
+	return results
+}
+
+// Lease grants an exclusive right to one of replicas sharing a storage, e.g. to ingest transactions.
+// Acquire returns true when the lease is held by the caller, the holder extends the lease by calling Acquire again
+// before it expires. Release gives the lease up if it is held by the caller.
+type Lease interface {
+	Acquire(ctx context.Context) (bool, error)
+	Release(ctx context.Context) error
 }
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// Item is a value of a list together with its position, Sequence grows monotonically within a list,
+// but it may start over when the list is flushed or deleted.
+type Item[T any] struct {
+	Sequence uint64    `json:"sequence"`
+	AddedAt  time.Time `json:"added_at"`
+	Value    T         `json:"value"`
+}
+
+// RangeQuery narrows items of a list, bounds are inclusive and zero values are not taken into account.
+type RangeQuery struct {
+	FromSequence uint64
+	ToSequence   uint64
+	From         time.Time
+	To           time.Time
+	Limit        int
+}
+
+func (query RangeQuery) Matches(sequence uint64, addedAt time.Time) bool {
+	return (query.FromSequence == 0 || sequence >= query.FromSequence) &&
+		(query.ToSequence == 0 || sequence <= query.ToSequence) &&
+		(query.From.IsZero() || !addedAt.Before(query.From)) &&
+		(query.To.IsZero() || !addedAt.After(query.To))
+}
+
+// Full reports whether items already reached the limit of the query.
+func (query RangeQuery) Full(items int) bool {
+	return query.Limit > 0 && items >= query.Limit
+}
+
+// Page implements Scan for storages which cannot iterate over keys in order, keys are sorted in place.
+// Non-positive limit returns all remaining keys.
+func Page(keys []string, cursor string, limit int) ([]string, string) {
+	sort.Strings(keys)
+	start := 0
+	if cursor != "" {
+		start = sort.SearchStrings(keys, cursor)
+		if start < len(keys) && keys[start] == cursor {
+			start++
+		}
+	}
+	keys = keys[start:]
+	if limit <= 0 || len(keys) <= limit {
+		return keys, ""
+	}
+	return keys[:limit], keys[limit-1]
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldPageKeys(t *testing.T) {
+	type testCase struct {
+		name     string
+		cursor   string
+		limit    int
+		want     []string
+		wantNext string
+	}
+	tests := []testCase{
+		{name: "should return first page", limit: 2, want: []string{"a", "b"}, wantNext: "b"},
+		{name: "should continue after cursor", cursor: "b", limit: 2, want: []string{"c", "d"}, wantNext: ""},
+		{name: "should continue after missing cursor", cursor: "bb", limit: 1, want: []string{"c"}, wantNext: "c"},
+		{name: "should return all keys without limit", want: []string{"a", "b", "c", "d"}},
+		{name: "should return nothing after last key", cursor: "d", limit: 2, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next := Page([]string{"d", "b", "a", "c"}, tt.cursor, tt.limit)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.wantNext, next)
+		})
+	}
+}
+
+func TestShouldMatchRange(t *testing.T) {
+	now := time.Now()
+	query := RangeQuery{FromSequence: 2, ToSequence: 4, From: now.Add(-time.Minute), To: now}
+	require.True(t, query.Matches(2, now))
+	require.True(t, query.Matches(4, now.Add(-time.Minute)))
+	require.False(t, query.Matches(1, now))
+	require.False(t, query.Matches(5, now))
+	require.False(t, query.Matches(3, now.Add(time.Second)))
+	require.True(t, RangeQuery{}.Matches(100, time.Time{}))
+}
@@ -9,6 +9,7 @@ import (
 	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	storage "github.com/ziollek/etherscription/pkg/storage"
 )
 
 // MockKVSaver is a mock of KVSaver interface.
@@ -34,6 +35,20 @@ func (m *MockKVSaver[T]) EXPECT() *MockKVSaverMockRecorder[T] {
 	return m.recorder
 }
 
+// Delete mocks base method.
+func (m *MockKVSaver[T]) Delete(key string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", key)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockKVSaverMockRecorder[T]) Delete(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockKVSaver[T])(nil).Delete), key)
+}
+
 // Get mocks base method.
 func (m *MockKVSaver[T]) Get(key string) (T, bool) {
 	m.ctrl.T.Helper()
@@ -49,6 +64,35 @@ func (mr *MockKVSaverMockRecorder[T]) Get(key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockKVSaver[T])(nil).Get), key)
 }
 
+// Keys mocks base method.
+func (m *MockKVSaver[T]) Keys() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Keys")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Keys indicates an expected call of Keys.
+func (mr *MockKVSaverMockRecorder[T]) Keys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Keys", reflect.TypeOf((*MockKVSaver[T])(nil).Keys))
+}
+
+// Scan mocks base method.
+func (m *MockKVSaver[T]) Scan(cursor string, limit int) ([]string, string) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Scan", cursor, limit)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(string)
+	return ret0, ret1
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockKVSaverMockRecorder[T]) Scan(cursor, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockKVSaver[T])(nil).Scan), cursor, limit)
+}
+
 // Set mocks base method.
 func (m *MockKVSaver[T]) Set(key string, value T) {
 	m.ctrl.T.Helper()
@@ -96,6 +140,20 @@ func (mr *MockListSaverMockRecorder[T]) Append(key, value, ttl interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Append", reflect.TypeOf((*MockListSaver[T])(nil).Append), key, value, ttl)
 }
 
+// Delete mocks base method.
+func (m *MockListSaver[T]) Delete(key string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", key)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockListSaverMockRecorder[T]) Delete(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockListSaver[T])(nil).Delete), key)
+}
+
 // FetchAndFlush mocks base method.
 func (m *MockListSaver[T]) FetchAndFlush(key string) []T {
 	m.ctrl.T.Helper()
@@ -109,3 +167,163 @@ func (mr *MockListSaverMockRecorder[T]) FetchAndFlush(key interface{}) *gomock.C
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchAndFlush", reflect.TypeOf((*MockListSaver[T])(nil).FetchAndFlush), key)
 }
+
+// Keys mocks base method.
+func (m *MockListSaver[T]) Keys() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Keys")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Keys indicates an expected call of Keys.
+func (mr *MockListSaverMockRecorder[T]) Keys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Keys", reflect.TypeOf((*MockListSaver[T])(nil).Keys))
+}
+
+// Len mocks base method.
+func (m *MockListSaver[T]) Len(key string) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Len", key)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Len indicates an expected call of Len.
+func (mr *MockListSaverMockRecorder[T]) Len(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Len", reflect.TypeOf((*MockListSaver[T])(nil).Len), key)
+}
+
+// Peek mocks base method.
+func (m *MockListSaver[T]) Peek(key string) []T {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek", key)
+	ret0, _ := ret[0].([]T)
+	return ret0
+}
+
+// Peek indicates an expected call of Peek.
+func (mr *MockListSaverMockRecorder[T]) Peek(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockListSaver[T])(nil).Peek), key)
+}
+
+// Range mocks base method.
+func (m *MockListSaver[T]) Range(key string, query storage.RangeQuery) []storage.Item[T] {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Range", key, query)
+	ret0, _ := ret[0].([]storage.Item[T])
+	return ret0
+}
+
+// Range indicates an expected call of Range.
+func (mr *MockListSaverMockRecorder[T]) Range(key, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Range", reflect.TypeOf((*MockListSaver[T])(nil).Range), key, query)
+}
+
+// Scan mocks base method.
+func (m *MockListSaver[T]) Scan(cursor string, limit int) ([]string, string) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Scan", cursor, limit)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(string)
+	return ret0, ret1
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockListSaverMockRecorder[T]) Scan(cursor, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockListSaver[T])(nil).Scan), cursor, limit)
+}
+
+// MockCleanable is a mock of Cleanable interface.
+type MockCleanable struct {
+	ctrl     *gomock.Controller
+	recorder *MockCleanableMockRecorder
+}
+
+// MockCleanableMockRecorder is the mock recorder for MockCleanable.
+type MockCleanableMockRecorder struct {
+	mock *MockCleanable
+}
+
+// NewMockCleanable creates a new mock instance.
+func NewMockCleanable(ctrl *gomock.Controller) *MockCleanable {
+	mock := &MockCleanable{ctrl: ctrl}
+	mock.recorder = &MockCleanableMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCleanable) EXPECT() *MockCleanableMockRecorder {
+	return m.recorder
+}
+
+// CleanOutdated mocks base method.
+func (m *MockCleanable) CleanOutdated(key string) (int, int) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CleanOutdated", key)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(int)
+	return ret0, ret1
+}
+
+// CleanOutdated indicates an expected call of CleanOutdated.
+func (mr *MockCleanableMockRecorder) CleanOutdated(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanOutdated", reflect.TypeOf((*MockCleanable)(nil).CleanOutdated), key)
+}
+
+// Keys mocks base method.
+func (m *MockCleanable) Keys() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Keys")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Keys indicates an expected call of Keys.
+func (mr *MockCleanableMockRecorder) Keys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Keys", reflect.TypeOf((*MockCleanable)(nil).Keys))
+}
+
+// MockExpirer is a mock of Expirer interface.
+type MockExpirer struct {
+	ctrl     *gomock.Controller
+	recorder *MockExpirerMockRecorder
+}
+
+// MockExpirerMockRecorder is the mock recorder for MockExpirer.
+type MockExpirerMockRecorder struct {
+	mock *MockExpirer
+}
+
+// NewMockExpirer creates a new mock instance.
+func NewMockExpirer(ctrl *gomock.Controller) *MockExpirer {
+	mock := &MockExpirer{ctrl: ctrl}
+	mock.recorder = &MockExpirerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExpirer) EXPECT() *MockExpirerMockRecorder {
+	return m.recorder
+}
+
+// Expire mocks base method.
+func (m *MockExpirer) Expire(now time.Time) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", now)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Expire indicates an expected call of Expire.
+func (mr *MockExpirerMockRecorder) Expire(now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockExpirer)(nil).Expire), now)
+}
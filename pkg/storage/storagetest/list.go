@@ -0,0 +1,143 @@
+// Package storagetest provides a conformance suite shared by tests of storage backends.
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+// ListStorage is a list storage which can be plugged into the cleaner, every backend provides one.
+type ListStorage interface {
+	storage.ListSaver[string]
+	CleanOutdated(key string) (int, int)
+}
+
+// TestListSaver checks the behaviour every list storage has to share, newStorage creates an empty storage.
+func TestListSaver(t *testing.T, newStorage func(t *testing.T) ListStorage) {
+	t.Run("should append entries in order and flush them after read", func(t *testing.T) {
+		s := newStorage(t)
+		for _, value := range []string{"first", "second", "third"} {
+			s.Append("key", value, time.Second)
+		}
+		s.Append("key", "outdated", -time.Second)
+		require.Equal(t, []string{"key"}, s.Keys())
+		requireCount(t, s, 1)
+		require.Equal(t, 3, s.Len("key"))
+		require.Equal(t, []string{"first", "second", "third"}, s.FetchAndFlush("key"))
+		require.Equal(t, []string{}, s.FetchAndFlush("key"))
+		require.Equal(t, []string{}, s.Keys())
+		requireCount(t, s, 0)
+	})
+	t.Run("should clean outdated entries", func(t *testing.T) {
+		testCleanOutdatedEntries(t, newStorage)
+	})
+	t.Run("should read entries without removing them", func(t *testing.T) {
+		s := newStorage(t)
+		for _, value := range []string{"first", "second", "third"} {
+			s.Append("key", value, time.Second)
+		}
+		s.Append("key", "outdated", -time.Second)
+		require.Equal(t, []string{"first", "second", "third"}, s.Peek("key"))
+		items := s.Range("key", storage.RangeQuery{})
+		require.Len(t, items, 3)
+		require.Less(t, items[0].Sequence, items[1].Sequence)
+		require.Less(t, items[1].Sequence, items[2].Sequence)
+		require.WithinDuration(t, time.Now(), items[0].AddedAt, time.Second)
+		require.Equal(t, items[1:], s.Range("key", storage.RangeQuery{FromSequence: items[1].Sequence}))
+		require.Equal(t, items[:1], s.Range("key", storage.RangeQuery{Limit: 1}))
+		require.Empty(t, s.Range("key", storage.RangeQuery{From: time.Now().Add(time.Minute)}))
+		require.Equal(t, 3, s.Len("key"))
+		require.True(t, s.Delete("key"))
+		require.False(t, s.Delete("key"))
+		require.Empty(t, s.Peek("key"))
+		require.Empty(t, s.Keys())
+	})
+	t.Run("should append up to the limit", func(t *testing.T) {
+		s := newStorage(t)
+		bounded, ok := s.(storage.BoundedAppender[string])
+		if !ok {
+			t.Skip("the storage does not check limits while appending")
+		}
+		require.True(t, bounded.AppendBounded("key", "first", time.Second, 2))
+		require.True(t, bounded.AppendBounded("key", "second", time.Second, 2))
+		require.False(t, bounded.AppendBounded("key", "third", time.Second, 2))
+		require.True(t, bounded.AppendBounded("other", "fourth", time.Second, 2))
+		require.True(t, bounded.AppendBounded("key", "fifth", time.Second, 0))
+		require.Equal(t, []string{"first", "second", "fifth"}, s.FetchAndFlush("key"))
+		require.Equal(t, []string{"fourth"}, s.FetchAndFlush("other"))
+	})
+	t.Run("should scan keys page by page", func(t *testing.T) {
+		s := newStorage(t)
+		for _, key := range []string{"c", "a", "d", "b"} {
+			s.Append(key, "value", time.Second)
+		}
+		keys, cursor := s.Scan("", 3)
+		require.Equal(t, []string{"a", "b", "c"}, keys)
+		require.Equal(t, "c", cursor)
+		keys, cursor = s.Scan(cursor, 3)
+		require.Equal(t, []string{"d"}, keys)
+		require.Empty(t, cursor)
+	})
+}
+
+func testCleanOutdatedEntries(t *testing.T, newStorage func(t *testing.T) ListStorage) {
+	type testCase struct {
+		name       string
+		outdated   []string
+		active     []string
+		wantBefore int
+		wantAfter  int
+		wantKeys   []string
+	}
+	tests := []testCase{
+		{name: "should do nothing for missing key", wantKeys: []string{}},
+		{
+			name:       "should keep active entries",
+			outdated:   []string{"first", "second"},
+			active:     []string{"third"},
+			wantBefore: 3,
+			wantAfter:  1,
+			wantKeys:   []string{"key"},
+		},
+		{
+			name:       "should remove key without active entries",
+			outdated:   []string{"first", "second"},
+			wantBefore: 2,
+			wantAfter:  0,
+			wantKeys:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t)
+			for _, value := range tt.outdated {
+				s.Append("key", value, -time.Second)
+			}
+			for _, value := range tt.active {
+				s.Append("key", value, time.Second)
+			}
+			before, after := s.CleanOutdated("key")
+			require.Equal(t, tt.wantBefore, before)
+			require.Equal(t, tt.wantAfter, after)
+			require.Equal(t, tt.wantKeys, s.Keys())
+			require.Equal(t, tt.active, nilIfEmpty(s.FetchAndFlush("key")))
+		})
+	}
+}
+
+// requireCount checks the number of keys of storages which can count them without reading all of them.
+func requireCount(t *testing.T, s ListStorage, want int) {
+	if counter, ok := s.(storage.Counter); ok {
+		require.Equal(t, want, counter.Count())
+	}
+}
+
+func nilIfEmpty(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
@@ -0,0 +1,82 @@
+package supervisor
+
+import (
+	"context"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/storage"
+)
+
+// Exclusive runs the component only while the lease is held, so only one of replicas sharing a storage runs it.
+// The lease is acquired and renewed every interval, which should be a fraction of its TTL, the component is stopped
+// as soon as renewing fails and it waits for the lease again. standby is called whenever the lease is not held.
+// A failure of the component gives the lease up, so another replica can take over while this one backs off.
+func Exclusive(lease storage.Lease, interval time.Duration, component Component, standby func()) Component {
+	return func(ctx context.Context) error {
+		defer func() {
+			if err := lease.Release(context.Background()); err != nil {
+				logging.Module("supervisor").Err(err).Msg("Cannot release lease")
+			}
+		}()
+		for {
+			standby()
+			if !waitForLease(ctx, lease, interval) {
+				return nil
+			}
+			logging.Module("supervisor").Info().Msg("Lease acquired, starting exclusive component")
+			if err := holdLease(ctx, lease, interval, component); err != nil || ctx.Err() != nil {
+				return err
+			}
+			logging.Module("supervisor").Warn().Msg("Lease lost, exclusive component stopped")
+		}
+	}
+}
+
+// waitForLease returns false when ctx is done before the lease was acquired.
+func waitForLease(ctx context.Context, lease storage.Lease, interval time.Duration) bool {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		acquired, err := lease.Acquire(ctx)
+		if err != nil {
+			logging.Module("supervisor").Err(err).Msg("Cannot acquire lease")
+		}
+		if acquired {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// holdLease runs the component until it returns or the lease cannot be renewed.
+func holdLease(ctx context.Context, lease storage.Lease, interval time.Duration, component Component) error {
+	holding, lost := context.WithCancel(ctx)
+	defer lost()
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-holding.Done():
+				return
+			case <-ticker.C:
+			}
+			if acquired, err := lease.Acquire(holding); err != nil || !acquired {
+				logging.Module("supervisor").Err(err).Msg("Cannot renew lease")
+				lost()
+				return
+			}
+		}
+	}()
+	err := run(holding, component)
+	lost()
+	<-renewed
+	return err
+}
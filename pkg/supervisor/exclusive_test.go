@@ -0,0 +1,86 @@
+package supervisor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLease is held by the first caller until it is taken away by revoke.
+type fakeLease struct {
+	mutex    sync.Mutex
+	holder   string
+	caller   string
+	released atomic.Int32
+}
+
+func (l *fakeLease) Acquire(context.Context) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.holder == "" {
+		l.holder = l.caller
+	}
+	return l.holder == l.caller, nil
+}
+
+func (l *fakeLease) Release(context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.holder == l.caller {
+		l.holder = ""
+	}
+	l.released.Add(1)
+	return nil
+}
+
+func (l *fakeLease) set(holder string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.holder = holder
+}
+
+func TestShouldRunExclusiveComponentOnlyWhileHoldingLease(t *testing.T) {
+	lease := &fakeLease{caller: "replica", holder: "other"}
+	var running atomic.Bool
+	var standby atomic.Int32
+	component := Exclusive(lease, time.Millisecond, func(ctx context.Context) error {
+		running.Store(true)
+		<-ctx.Done()
+		running.Store(false)
+		return nil
+	}, func() { standby.Add(1) })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- component(ctx) }()
+
+	time.Sleep(time.Millisecond * 20)
+	require.False(t, running.Load())
+	require.Equal(t, int32(1), standby.Load())
+
+	lease.set("")
+	require.Eventually(t, running.Load, time.Second, time.Millisecond)
+
+	// another replica took the lease over
+	lease.set("other")
+	require.Eventually(t, func() bool { return !running.Load() }, time.Second, time.Millisecond)
+	require.Equal(t, int32(2), standby.Load())
+
+	lease.set("replica")
+	require.Eventually(t, running.Load, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	require.False(t, running.Load())
+	require.Equal(t, int32(1), lease.released.Load())
+}
+
+func TestShouldReleaseLeaseWhenExclusiveComponentFails(t *testing.T) {
+	lease := &fakeLease{caller: "replica"}
+	component := Exclusive(lease, time.Millisecond, func(context.Context) error { return errTest }, func() {})
+
+	require.ErrorIs(t, component(context.Background()), errTest)
+	require.Equal(t, "", lease.holder)
+}
@@ -0,0 +1,138 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/logging"
+)
+
+const (
+	defaultMaxRestarts    = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+)
+
+// ErrRestartsExhausted is wrapped by the error returned from Run when a component failed too many times in a row.
+var ErrRestartsExhausted = errors.New("restarts exhausted")
+
+// Component is a long-running part of the service, it should return when its context is done.
+// An error or a panic is a failure and the component is restarted after a backoff.
+type Component func(ctx context.Context) error
+
+type child struct {
+	name      string
+	component Component
+}
+
+// Supervisor runs components and restarts them when they fail. Components are stopped in the reverse order
+// of adding, each one only after the following ones returned, so a producer can be stopped before its consumer
+// and the consumer can drain what was produced.
+type Supervisor struct {
+	cfg      config.SupervisorConfig
+	children []child
+}
+
+func New(cfg *config.SupervisorConfig) *Supervisor {
+	supervisor := &Supervisor{cfg: config.SupervisorConfig{
+		MaxRestarts:    defaultMaxRestarts,
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+	}}
+	if cfg != nil {
+		if cfg.MaxRestarts != 0 {
+			supervisor.cfg.MaxRestarts = cfg.MaxRestarts
+		}
+		if cfg.InitialBackoff > 0 {
+			supervisor.cfg.InitialBackoff = cfg.InitialBackoff
+		}
+		if cfg.MaxBackoff > 0 {
+			supervisor.cfg.MaxBackoff = cfg.MaxBackoff
+		}
+	}
+	return supervisor
+}
+
+func (s *Supervisor) Add(name string, component Component) {
+	s.children = append(s.children, child{name: name, component: component})
+}
+
+// Run blocks until ctx is done or a component exhausted its restarts, in both cases all components are stopped
+// before Run returns. The error of the component which exhausted its restarts is returned.
+func (s *Supervisor) Run(ctx context.Context) error {
+	fatal := make(chan error, len(s.children))
+	cancels := make([]context.CancelFunc, len(s.children))
+	done := make([]chan struct{}, len(s.children))
+	for i, c := range s.children {
+		childCtx, cancel := context.WithCancel(context.Background())
+		cancels[i], done[i] = cancel, make(chan struct{})
+		go func() {
+			defer close(done[i])
+			if err := s.supervise(childCtx, c); err != nil {
+				fatal <- err
+			}
+		}()
+	}
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-fatal:
+		logging.Module("supervisor").Err(err).Msg("Component failed, stopping all components")
+	}
+	for i := len(s.children) - 1; i >= 0; i-- {
+		cancels[i]()
+		<-done[i]
+		logging.Module("supervisor").Info().Str("component", s.children[i].name).Msg("Component stopped")
+	}
+	return err
+}
+
+// supervise restarts the component until it returns without an error or its context is done,
+// failures in a row are counted only when the component fails before running for MaxBackoff.
+func (s *Supervisor) supervise(ctx context.Context, c child) error {
+	backoff := s.cfg.InitialBackoff
+	failures := 0
+	for {
+		start := time.Now()
+		err := run(ctx, c.component)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(start) > s.cfg.MaxBackoff {
+			failures, backoff = 0, s.cfg.InitialBackoff
+		}
+		failures++
+		if s.cfg.MaxRestarts >= 0 && failures > s.cfg.MaxRestarts {
+			return fmt.Errorf("%s: %w after %d failures: %w", c.name, ErrRestartsExhausted, failures, err)
+		}
+		logging.Module("supervisor").Err(err).Str("component", c.name).Dur("backoff", backoff).
+			Msg("Component failed, restarting")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, s.cfg.MaxBackoff)
+	}
+}
+
+// run converts a panic of the component into an error.
+func run(ctx context.Context, component Component) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return component(ctx)
+}
+
+// Func adapts a function which does not report errors, e.g. Cleaner.Start, to a component.
+func Func(start func(ctx context.Context)) Component {
+	return func(ctx context.Context) error {
+		start(ctx)
+		return nil
+	}
+}
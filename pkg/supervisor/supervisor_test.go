@@ -0,0 +1,104 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+)
+
+var errTest = errors.New("test failure")
+
+func newTestSupervisor(maxRestarts int) *Supervisor {
+	return New(&config.SupervisorConfig{
+		MaxRestarts:    maxRestarts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	})
+}
+
+func TestShouldRestartFailedComponent(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure func()
+	}{
+		{"Should restart component returning an error", func() {}},
+		{"Should restart panicking component", func() { panic(errTest) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var starts atomic.Int32
+			restarted := make(chan struct{})
+			supervisor := newTestSupervisor(3)
+			supervisor.Add("component", func(ctx context.Context) error {
+				if starts.Add(1) == 1 {
+					tt.failure()
+					return errTest
+				}
+				close(restarted)
+				<-ctx.Done()
+				return nil
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			result := make(chan error)
+			go func() { result <- supervisor.Run(ctx) }()
+
+			<-restarted
+			cancel()
+			require.NoError(t, <-result)
+			require.Equal(t, int32(2), starts.Load())
+		})
+	}
+}
+
+func TestShouldStopAllComponentsWhenRestartsAreExhausted(t *testing.T) {
+	var starts atomic.Int32
+	stopped := false
+	supervisor := newTestSupervisor(2)
+	supervisor.Add("healthy", func(ctx context.Context) error {
+		<-ctx.Done()
+		stopped = true
+		return nil
+	})
+	supervisor.Add("failing", func(context.Context) error {
+		starts.Add(1)
+		return errTest
+	})
+
+	err := supervisor.Run(context.Background())
+
+	require.ErrorIs(t, err, ErrRestartsExhausted)
+	require.ErrorIs(t, err, errTest)
+	require.Equal(t, int32(3), starts.Load())
+	require.True(t, stopped)
+}
+
+func TestShouldStopComponentsInReverseOrder(t *testing.T) {
+	var mutex sync.Mutex
+	var order []string
+	started := sync.WaitGroup{}
+	supervisor := newTestSupervisor(0)
+	for _, name := range []string{"consumer", "producer", "server"} {
+		started.Add(1)
+		supervisor.Add(name, Func(func(ctx context.Context) {
+			started.Done()
+			<-ctx.Done()
+			mutex.Lock()
+			defer mutex.Unlock()
+			order = append(order, name)
+		}))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() { result <- supervisor.Run(ctx) }()
+
+	started.Wait()
+	cancel()
+	require.NoError(t, <-result)
+	require.Equal(t, []string{"server", "producer", "consumer"}, order)
+}
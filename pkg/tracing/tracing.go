@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ziollek/etherscription/pkg/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentation    = "github.com/ziollek/etherscription"
+	defaultServiceName = "etherscription"
+	traceParentKey     = "traceparent"
+)
+
+// propagator serializes span contexts carried on transactions, W3C trace context keeps them short and readable.
+var propagator = propagation.TraceContext{}
+
+// Setup installs a tracer provider exporting spans via OTLP over HTTP, the returned function flushes
+// pending spans and has to be called on shutdown. When tracing is disabled, spans are not recorded at all.
+func Setup(ctx context.Context, cfg *config.TracingConfig) (func(context.Context) error, error) {
+	if cfg == nil || !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return provider.Shutdown, nil
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Start begins a span, it is a shortcut for Tracer().Start.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// Fail marks the span as failed when err is not nil.
+func Fail(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// Inject serializes the span context of ctx, so it can be carried on a transaction.
+// An empty string is returned when there is no span, e.g. when tracing is disabled.
+func Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier[traceParentKey]
+}
+
+// Extract returns ctx with the span context serialized by Inject, so new spans become its children.
+func Extract(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{traceParentKey: traceParent})
+}
+
+// Link points to the span serialized by Inject, it relates spans of different traces,
+// e.g. a request which served a transaction with the trace in which the transaction was fetched.
+func Link(traceParent string) (trace.Link, bool) {
+	spanContext := trace.SpanContextFromContext(Extract(context.Background(), traceParent))
+	return trace.Link{SpanContext: spanContext}, spanContext.IsValid()
+}
+
+// InjectHeaders propagates the span context of ctx to an outgoing HTTP request.
+func InjectHeaders(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// ExtractHeaders continues the trace of an incoming HTTP request.
+func ExtractHeaders(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/pkg/config"
+	collector "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCollector accepts spans exported via OTLP over HTTP.
+type fakeCollector struct {
+	spans []*tracepb.Span
+	mutex sync.Mutex
+}
+
+func (c *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	request := &collector.ExportTraceServiceRequest{}
+	if err == nil {
+		err = proto.Unmarshal(body, request)
+	}
+	if err != nil || r.URL.Path != "/v1/traces" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, resourceSpans := range request.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			c.spans = append(c.spans, scopeSpans.Spans...)
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *fakeCollector) byName() map[string]*tracepb.Span {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	spans := make(map[string]*tracepb.Span)
+	for _, span := range c.spans {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func TestShouldExportSpansContinuedFromTransactionContext(t *testing.T) {
+	collector := &fakeCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	shutdown, err := Setup(context.Background(), &config.TracingConfig{
+		Enabled:     true,
+		Endpoint:    strings.TrimPrefix(server.URL, "http://"),
+		Insecure:    true,
+		SampleRatio: 1,
+	})
+	require.NoError(t, err)
+
+	ctx, fetch := Start(context.Background(), "fetcher.transaction")
+	traceParent := Inject(ctx)
+	fetch.End()
+	_, consume := Start(Extract(context.Background(), traceParent), "parser.consume")
+	consume.End()
+	_, request := Start(context.Background(), "GET /api/new-transactions/:address")
+	link, ok := Link(traceParent)
+	require.True(t, ok)
+	request.AddLink(link)
+	request.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	spans := collector.byName()
+	require.Len(t, spans, 3)
+	require.Equal(t, spans["fetcher.transaction"].TraceId, spans["parser.consume"].TraceId)
+	require.Equal(t, spans["fetcher.transaction"].SpanId, spans["parser.consume"].ParentSpanId)
+	require.NotEqual(t, spans["fetcher.transaction"].TraceId, spans["GET /api/new-transactions/:address"].TraceId)
+	require.Len(t, spans["GET /api/new-transactions/:address"].Links, 1)
+	require.Equal(t, spans["fetcher.transaction"].SpanId, spans["GET /api/new-transactions/:address"].Links[0].SpanId)
+}
+
+func TestShouldNotCarryContextWhenTracingIsDisabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), &config.TracingConfig{Enabled: false})
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	ctx, span := Start(context.Background(), "fetcher.transaction")
+	defer span.End()
+	require.Empty(t, Inject(ctx))
+	_, ok := Link("")
+	require.False(t, ok)
+}
@@ -0,0 +1,266 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/logging"
+	"github.com/ziollek/etherscription/pkg/model"
+	"github.com/ziollek/etherscription/pkg/storage"
+	"github.com/ziollek/etherscription/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	errQueueFull = "delivery queue is full"
+	errStopped   = "dispatcher stopped"
+	errDisabled  = "webhooks are disabled"
+	// idleQueueTimeout is the time after which the queue of a callback URL without deliveries is removed
+	idleQueueTimeout = time.Minute
+)
+
+// Payload is the body POSTed to subscriber callback URLs.
+type Payload struct {
+	Address     string            `json:"address"`
+	Transaction model.Transaction `json:"transaction"`
+}
+
+// DeliveryRecorder is notified about every successful delivery.
+type DeliveryRecorder interface {
+	RecordDelivery(subscription model.Subscription, at time.Time)
+}
+
+type delivery struct {
+	subscription model.Subscription
+	transaction  model.TracedTransaction
+}
+
+// Dispatcher pushes transactions to subscriber callback URLs.
+// Every endpoint has its own queue served by a dedicated goroutine,
+// so a slow or failing receiver does not delay deliveries to other ones.
+// Queues are created and removed only by Start, a queue idle for idleTimeout is removed with its goroutine.
+// Deliveries which are still queued when the dispatcher stops are moved to dead letters, so they are not lost.
+type Dispatcher struct {
+	cfg         *config.WebhookConfig
+	httpClient  *http.Client
+	signer      *Signer
+	deadLetters storage.ListSaver[model.DeadLetter]
+	recorder    DeliveryRecorder
+	deliveries  chan delivery
+	queues      map[string]chan delivery
+	// idle receives callback URLs of queues which had nothing to deliver for idleTimeout
+	idle        chan string
+	idleTimeout time.Duration
+	// serving tracks goroutines serving queues, Start waits for them before it returns
+	serving sync.WaitGroup
+}
+
+// NewDispatcher connects only to addresses allowed by the guard, proxies are not used,
+// so the guard sees the address of the receiver.
+func NewDispatcher(
+	cfg *config.WebhookConfig, guard *Guard, deadLetters storage.ListSaver[model.DeadLetter], recorder DeliveryRecorder,
+) *Dispatcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: cfg.Timeout, Control: guard.Control}).DialContext
+	return &Dispatcher{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+		},
+		signer:      NewSigner(cfg.Secret),
+		deadLetters: deadLetters,
+		recorder:    recorder,
+		deliveries:  make(chan delivery, cfg.QueueSize),
+		queues:      make(map[string]chan delivery),
+		idle:        make(chan string),
+		idleTimeout: idleQueueTimeout,
+	}
+}
+
+// Notify never blocks the caller, a delivery which does not fit into the queue goes straight to dead letters,
+// as well as every delivery when there is no secret to sign it with, e.g. of a subscription stored before it was removed.
+func (d *Dispatcher) Notify(subscription model.Subscription, transaction model.TracedTransaction) {
+	entry := delivery{subscription: subscription, transaction: transaction}
+	if d.cfg.Secret == "" {
+		d.bury(entry, 0, errDisabled)
+		return
+	}
+	select {
+	case d.deliveries <- entry:
+	default:
+		logging.Module("webhook").Warn().Str("url", subscription.CallbackURL).Msg(errQueueFull)
+		d.bury(entry, 0, errQueueFull)
+	}
+}
+
+func (d *Dispatcher) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			logging.Module("webhook").Warn().Msg("Context done, stopping dispatcher")
+			d.stop()
+			return
+		case entry := <-d.deliveries:
+			queue, found := d.queues[entry.subscription.CallbackURL]
+			if !found {
+				queue = make(chan delivery, d.cfg.QueueSize)
+				d.queues[entry.subscription.CallbackURL] = queue
+				d.serving.Add(1)
+				go d.serve(ctx, entry.subscription.CallbackURL, queue)
+			}
+			select {
+			case queue <- entry:
+			default:
+				logging.Module("webhook").Warn().Str("url", entry.subscription.CallbackURL).Msg(errQueueFull)
+				d.bury(entry, 0, errQueueFull)
+			}
+		case url := <-d.idle:
+			// entries could have been queued after the goroutine reported being idle
+			if queue := d.queues[url]; len(queue) == 0 {
+				delete(d.queues, url)
+				close(queue)
+			}
+		}
+	}
+}
+
+// stop waits until deliveries in progress finish, interrupted ones are buried by deliver,
+// and buries entries left in the queues, so storages can be closed once Start returns.
+func (d *Dispatcher) stop() {
+	d.serving.Wait()
+	buried := 0
+	for _, queue := range d.queues {
+		buried += d.drain(queue)
+	}
+	buried += d.drain(d.deliveries)
+	d.queues = make(map[string]chan delivery)
+	if buried > 0 {
+		logging.Module("webhook").Warn().Int("deliveries", buried).Msg("Queued deliveries moved to dead letters")
+	}
+}
+
+func (d *Dispatcher) drain(queue chan delivery) int {
+	drained := 0
+	for {
+		select {
+		case entry := <-queue:
+			d.bury(entry, 0, errStopped)
+			drained++
+		default:
+			return drained
+		}
+	}
+}
+
+// serve delivers entries of the queue until it is closed by Start or ctx is done.
+func (d *Dispatcher) serve(ctx context.Context, url string, queue <-chan delivery) {
+	defer d.serving.Done()
+	idle := time.NewTimer(d.idleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry, ok := <-queue:
+			if !ok {
+				return
+			}
+			if ctx.Err() != nil {
+				// select picks ready cases randomly, so the queue may win with a done context
+				d.bury(entry, 0, errStopped)
+				return
+			}
+			d.deliver(ctx, entry)
+		case <-idle.C:
+			select {
+			case d.idle <- url:
+			case <-ctx.Done():
+				return
+			}
+		}
+		idle.Reset(d.idleTimeout)
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, entry delivery) {
+	// the delivery continues the trace of the transaction, so time spent in the queue is visible
+	ctx, span := tracing.Start(
+		tracing.Extract(ctx, entry.transaction.TraceParent),
+		"webhook.deliver",
+		attribute.String("webhook.url", entry.subscription.CallbackURL),
+	)
+	defer span.End()
+	body, err := json.Marshal(Payload{Address: entry.subscription.Address, Transaction: entry.transaction.Transaction})
+	if err != nil {
+		d.bury(entry, 0, err.Error())
+		return
+	}
+	backoff := d.cfg.InitialBackoff
+	attempt := 0
+	for {
+		attempt++
+		err = d.post(ctx, entry.subscription.CallbackURL, body)
+		if err == nil {
+			logging.Module("webhook").Debug().Str("url", entry.subscription.CallbackURL).Int("attempt", attempt).Msg("Transaction delivered")
+			d.recorder.RecordDelivery(entry.subscription, time.Now())
+			span.SetAttributes(attribute.Int("webhook.attempts", attempt))
+			return
+		}
+		logging.Module("webhook").Warn().Err(err).Str("url", entry.subscription.CallbackURL).Int("attempt", attempt).Msg("Delivery failed")
+		if attempt > d.cfg.MaxRetries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			d.bury(entry, attempt, ctx.Err().Error())
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, d.cfg.MaxBackoff)
+	}
+	tracing.Fail(span, err)
+	d.bury(entry, attempt, err.Error())
+}
+
+func (d *Dispatcher) post(ctx context.Context, url string, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error while creating HTTP request: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(SignatureHeader, d.signer.Sign(timestamp, body))
+	tracing.InjectHeaders(ctx, request.Header)
+	resp, err := d.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error while making HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (d *Dispatcher) bury(entry delivery, attempts int, reason string) {
+	d.deadLetters.Append(entry.subscription.Key(), model.DeadLetter{
+		Tenant:      entry.subscription.Tenant,
+		Address:     entry.subscription.Address,
+		CallbackURL: entry.subscription.CallbackURL,
+		Transaction: entry.transaction.Transaction,
+		Attempts:    attempts,
+		LastError:   reason,
+		FailedAt:    time.Now(),
+	}, d.cfg.DeadLetterRetention)
+}
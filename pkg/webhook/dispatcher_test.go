@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/internal/storage/memory"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/model"
+)
+
+func TestShouldVerifyOnlyProperSignatures(t *testing.T) {
+	signer := NewSigner("secret")
+	body := []byte(`{"address":"0x1"}`)
+	signature := signer.Sign(1000, body)
+	require.True(t, signer.Verify(1000, body, signature))
+	require.False(t, signer.Verify(1001, body, signature))
+	require.False(t, signer.Verify(1000, []byte(`{"address":"0x2"}`), signature))
+	require.False(t, NewSigner("other").Verify(1000, body, signature))
+}
+
+type countingRecorder struct {
+	deliveries atomic.Int32
+}
+
+func (r *countingRecorder) RecordDelivery(model.Subscription, time.Time) {
+	r.deliveries.Add(1)
+}
+
+func TestShouldDeliverTransactionsToCallbackURL(t *testing.T) {
+	type testCase struct {
+		name            string
+		failures        int32
+		maxRetries      int
+		wantAttempts    int32
+		wantDeadLetters int
+	}
+	tests := []testCase{
+		{name: "should deliver at first attempt", failures: 0, maxRetries: 2, wantAttempts: 1, wantDeadLetters: 0},
+		{name: "should retry failed deliveries", failures: 2, maxRetries: 2, wantAttempts: 3, wantDeadLetters: 0},
+		{name: "should move delivery to dead letters after all retries", failures: 10, maxRetries: 2, wantAttempts: 3, wantDeadLetters: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := NewSigner("secret")
+			attempts := atomic.Int32{}
+			done := make(chan struct{}, 10)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer func() { done <- struct{}{} }()
+				body, _ := io.ReadAll(r.Body)
+				timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+				require.True(t, signer.Verify(timestamp, body, r.Header.Get(SignatureHeader)))
+				var payload Payload
+				require.NoError(t, json.Unmarshal(body, &payload))
+				require.Equal(t, "0x2", payload.Address)
+				if attempts.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			deadLetters := memory.NewListStorage[model.DeadLetter]()
+			recorder := &countingRecorder{}
+			cfg := &config.WebhookConfig{
+				Secret:              "secret",
+				Timeout:             time.Second,
+				MaxRetries:          tt.maxRetries,
+				InitialBackoff:      time.Millisecond,
+				MaxBackoff:          time.Millisecond * 2,
+				QueueSize:           10,
+				DeadLetterRetention: time.Minute,
+				AllowedNetworks:     []string{"127.0.0.0/8"},
+			}
+			dispatcher := NewDispatcher(cfg, mustGuard(t, cfg), deadLetters, recorder)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go dispatcher.Start(ctx)
+
+			transaction := model.TracedTransaction{Transaction: model.Transaction{From: "0x1", To: "0x2", Value: 1}}
+			dispatcher.Notify(model.Subscription{Tenant: model.DefaultTenant, Address: "0x2", CallbackURL: server.URL}, transaction)
+			for i := int32(0); i < tt.wantAttempts; i++ {
+				<-done
+			}
+			require.Eventually(t, func() bool {
+				return len(deadLetters.Keys()) == tt.wantDeadLetters
+			}, time.Second, time.Millisecond*10)
+			require.Equal(t, tt.wantAttempts, attempts.Load())
+			require.Equal(t, int32(1-tt.wantDeadLetters), recorder.deliveries.Load())
+			if tt.wantDeadLetters > 0 {
+				letters := deadLetters.FetchAndFlush(model.ScopedKey(model.DefaultTenant, "0x2"))
+				require.Len(t, letters, tt.wantDeadLetters)
+				require.Equal(t, transaction.Transaction, letters[0].Transaction)
+				require.Equal(t, int(tt.wantAttempts), letters[0].Attempts)
+			}
+		})
+	}
+}
+
+func TestShouldBuryDeliveriesWhenQueueIsFull(t *testing.T) {
+	deadLetters := memory.NewListStorage[model.DeadLetter]()
+	cfg := &config.WebhookConfig{Secret: "secret", QueueSize: 1, DeadLetterRetention: time.Minute}
+	dispatcher := NewDispatcher(cfg, mustGuard(t, cfg), deadLetters, &countingRecorder{})
+	subscription := model.Subscription{Tenant: model.DefaultTenant, Address: "0x2", CallbackURL: "http://localhost/hook"}
+
+	// the dispatcher is not started, so nothing consumes the queue
+	dispatcher.Notify(subscription, model.TracedTransaction{Transaction: model.Transaction{Hash: "0x10"}})
+	dispatcher.Notify(subscription, model.TracedTransaction{Transaction: model.Transaction{Hash: "0x11"}})
+
+	letters := deadLetters.Peek(subscription.Key())
+	require.Len(t, letters, 1)
+	require.Equal(t, "0x11", letters[0].Transaction.Hash)
+	require.Equal(t, errQueueFull, letters[0].LastError)
+}
+
+func TestShouldRemoveIdleQueues(t *testing.T) {
+	delivered := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		delivered <- struct{}{}
+	}))
+	defer server.Close()
+
+	cfg := &config.WebhookConfig{Secret: "secret", Timeout: time.Second, QueueSize: 10, AllowedNetworks: []string{"127.0.0.0/8"}}
+	dispatcher := NewDispatcher(cfg, mustGuard(t, cfg), memory.NewListStorage[model.DeadLetter](), &countingRecorder{})
+	dispatcher.idleTimeout = time.Millisecond * 10
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		dispatcher.Start(ctx)
+		close(stopped)
+	}()
+
+	subscription := model.Subscription{Tenant: model.DefaultTenant, Address: "0x2", CallbackURL: server.URL}
+	dispatcher.Notify(subscription, model.TracedTransaction{Transaction: model.Transaction{Hash: "0x10"}})
+	<-delivered
+	time.Sleep(time.Millisecond * 100)
+	// a removed queue is created again for the next delivery
+	dispatcher.Notify(subscription, model.TracedTransaction{Transaction: model.Transaction{Hash: "0x11"}})
+	<-delivered
+	time.Sleep(time.Millisecond * 100)
+	cancel()
+	<-stopped
+	require.Empty(t, dispatcher.queues)
+}
+
+func TestShouldBuryQueuedDeliveriesOnStop(t *testing.T) {
+	attempted := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		attempted <- struct{}{}
+	}))
+	defer server.Close()
+
+	deadLetters := memory.NewListStorage[model.DeadLetter]()
+	cfg := &config.WebhookConfig{
+		Secret:              "secret",
+		Timeout:             time.Second,
+		QueueSize:           10,
+		MaxRetries:          5,
+		InitialBackoff:      time.Hour,
+		MaxBackoff:          time.Hour,
+		DeadLetterRetention: time.Minute,
+		AllowedNetworks:     []string{"127.0.0.0/8"},
+	}
+	dispatcher := NewDispatcher(cfg, mustGuard(t, cfg), deadLetters, &countingRecorder{})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		dispatcher.Start(ctx)
+		close(stopped)
+	}()
+
+	subscription := model.Subscription{Tenant: model.DefaultTenant, Address: "0x2", CallbackURL: server.URL}
+	for _, hash := range []string{"0x10", "0x11", "0x12"} {
+		dispatcher.Notify(subscription, model.TracedTransaction{Transaction: model.Transaction{Hash: hash}})
+	}
+	// the first delivery waits for the next attempt, the other ones wait in the queue
+	<-attempted
+	cancel()
+	<-stopped
+
+	letters := deadLetters.Peek(subscription.Key())
+	require.Len(t, letters, 3)
+	reasons := map[string]string{}
+	for _, letter := range letters {
+		reasons[letter.Transaction.Hash] = letter.LastError
+	}
+	require.Equal(t, map[string]string{"0x10": context.Canceled.Error(), "0x11": errStopped, "0x12": errStopped}, reasons)
+	require.Empty(t, dispatcher.queues)
+}
+
+func TestShouldBuryDeliveriesWhenWebhooksAreDisabled(t *testing.T) {
+	deadLetters := memory.NewListStorage[model.DeadLetter]()
+	cfg := &config.WebhookConfig{QueueSize: 1, DeadLetterRetention: time.Minute}
+	dispatcher := NewDispatcher(cfg, mustGuard(t, cfg), deadLetters, &countingRecorder{})
+	subscription := model.Subscription{Tenant: model.DefaultTenant, Address: "0x2", CallbackURL: "https://example.com/hook"}
+
+	dispatcher.Notify(subscription, model.TracedTransaction{Transaction: model.Transaction{Hash: "0x10"}})
+
+	require.Empty(t, dispatcher.deliveries)
+	letters := deadLetters.Peek(subscription.Key())
+	require.Len(t, letters, 1)
+	require.Equal(t, errDisabled, letters[0].LastError)
+}
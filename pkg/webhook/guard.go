@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+
+	"github.com/ziollek/etherscription/pkg/config"
+)
+
+var (
+	ErrInvalidCallbackURL = errors.New("callback URL must be an absolute http or https URL")
+	ErrForbiddenCallback  = errors.New("callback URL points to a forbidden network")
+	ErrWebhooksDisabled   = errors.New("webhooks are disabled, webhook.secret is not configured")
+)
+
+// Guard decides which addresses callback URLs may point to. Denied networks are checked first, then allowed ones,
+// addresses matching neither are allowed unless they are loopback, private, link-local, unspecified or multicast,
+// so subscribers cannot make the service call its own infrastructure, e.g. cloud metadata endpoints.
+// Callback URLs are rejected altogether when there is no secret to sign deliveries with.
+type Guard struct {
+	allowed  []netip.Prefix
+	denied   []netip.Prefix
+	resolver *net.Resolver
+	enabled  bool
+}
+
+func NewGuard(cfg *config.WebhookConfig) (*Guard, error) {
+	allowed, err := parsePrefixes(cfg.AllowedNetworks)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := parsePrefixes(cfg.DeniedNetworks)
+	if err != nil {
+		return nil, err
+	}
+	return &Guard{allowed: allowed, denied: denied, resolver: net.DefaultResolver, enabled: cfg.Secret != ""}, nil
+}
+
+func parsePrefixes(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, len(networks))
+	for i, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", network, err)
+		}
+		prefixes[i] = prefix.Masked()
+	}
+	return prefixes, nil
+}
+
+// Allows reports whether callbacks may be delivered to the address.
+func (g *Guard) Allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range g.denied {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() &&
+		!addr.IsUnspecified() && !addr.IsMulticast() && !addr.IsInterfaceLocalMulticast()
+}
+
+// CheckURL validates the callback URL when a subscription is made, every address the host resolves to must be allowed.
+// Resolved addresses may change later, so deliveries are checked again when connecting, see Control.
+func (g *Guard) CheckURL(ctx context.Context, callbackURL string) error {
+	return g.checkURL(ctx, callbackURL, g.checkHost)
+}
+
+// Batch returns CheckURL which resolves every distinct host only once, it is meant for URLs of a single bulk request.
+func (g *Guard) Batch() func(ctx context.Context, callbackURL string) error {
+	hosts := make(map[string]error)
+	checkHost := func(ctx context.Context, host string) error {
+		if err, found := hosts[host]; found {
+			return err
+		}
+		err := g.checkHost(ctx, host)
+		hosts[host] = err
+		return err
+	}
+	return func(ctx context.Context, callbackURL string) error {
+		return g.checkURL(ctx, callbackURL, checkHost)
+	}
+}
+
+func (g *Guard) checkURL(ctx context.Context, callbackURL string, checkHost func(ctx context.Context, host string) error) error {
+	if !g.enabled {
+		return ErrWebhooksDisabled
+	}
+	parsed, err := url.Parse(callbackURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ErrInvalidCallbackURL
+	}
+	return checkHost(ctx, parsed.Hostname())
+}
+
+func (g *Guard) checkHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !g.Allows(addr) {
+			return ErrForbiddenCallback
+		}
+		return nil
+	}
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve callback host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !g.Allows(addr) {
+			return ErrForbiddenCallback
+		}
+	}
+	return nil
+}
+
+// Control is used as net.Dialer.Control, it rejects connections to forbidden addresses after the host was resolved.
+func (g *Guard) Control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenCallback, address)
+	}
+	if !g.Allows(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenCallback, addrPort.Addr())
+	}
+	return nil
+}
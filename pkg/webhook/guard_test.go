@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ziollek/etherscription/internal/storage/memory"
+	"github.com/ziollek/etherscription/pkg/config"
+	"github.com/ziollek/etherscription/pkg/model"
+)
+
+func mustGuard(t *testing.T, cfg *config.WebhookConfig) *Guard {
+	t.Helper()
+	guard, err := NewGuard(cfg)
+	require.NoError(t, err)
+	return guard
+}
+
+func TestShouldAllowOnlyPublicNetworksByDefault(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.WebhookConfig
+		address string
+		want    bool
+	}{
+		{"should allow public address", config.WebhookConfig{}, "93.184.216.34", true},
+		{"should allow public IPv6 address", config.WebhookConfig{}, "2606:2800:220:1::1", true},
+		{"should reject loopback", config.WebhookConfig{}, "127.0.0.1", false},
+		{"should reject IPv6 loopback", config.WebhookConfig{}, "::1", false},
+		{"should reject IPv4 mapped loopback", config.WebhookConfig{}, "::ffff:127.0.0.1", false},
+		{"should reject private network", config.WebhookConfig{}, "10.1.2.3", false},
+		{"should reject metadata endpoint", config.WebhookConfig{}, "169.254.169.254", false},
+		{"should reject unspecified address", config.WebhookConfig{}, "0.0.0.0", false},
+		{
+			"should allow listed private network",
+			config.WebhookConfig{AllowedNetworks: []string{"10.1.0.0/16"}},
+			"10.1.2.3", true,
+		},
+		{
+			"should reject private network not being listed",
+			config.WebhookConfig{AllowedNetworks: []string{"10.1.0.0/16"}},
+			"10.2.2.3", false,
+		},
+		{
+			"should reject denied network even if it is allowed",
+			config.WebhookConfig{AllowedNetworks: []string{"10.0.0.0/8"}, DeniedNetworks: []string{"10.1.0.0/16"}},
+			"10.1.2.3", false,
+		},
+		{
+			"should reject denied public network",
+			config.WebhookConfig{DeniedNetworks: []string{"93.184.0.0/16"}},
+			"93.184.216.34", false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, mustGuard(t, &tt.cfg).Allows(netip.MustParseAddr(tt.address)))
+		})
+	}
+}
+
+func TestShouldCheckCallbackURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{"should accept public address", "https://93.184.216.34/hook", nil},
+		{"should reject unsupported scheme", "ftp://93.184.216.34/hook", ErrInvalidCallbackURL},
+		{"should reject URL without host", "http:///hook", ErrInvalidCallbackURL},
+		{"should reject metadata endpoint", "http://169.254.169.254/latest/meta-data", ErrForbiddenCallback},
+		{"should reject IPv6 loopback", "http://[::1]:8080/hook", ErrForbiddenCallback},
+		{"should reject host resolving to loopback", "http://localhost:8080/hook", ErrForbiddenCallback},
+	}
+	require.ErrorIs(t, mustGuard(t, &config.WebhookConfig{}).CheckURL(context.Background(), tests[0].url), ErrWebhooksDisabled)
+	guard := mustGuard(t, &config.WebhookConfig{Secret: "secret"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, guard.CheckURL(context.Background(), tt.url), tt.wantErr)
+		})
+	}
+}
+
+func TestShouldResolveEveryHostOnceInBatch(t *testing.T) {
+	var lookups atomic.Int32
+	guard := mustGuard(t, &config.WebhookConfig{Secret: "secret"})
+	guard.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			lookups.Add(1)
+			return nil, errors.New("unreachable")
+		},
+	}
+	checkURL := guard.Batch()
+	require.Error(t, checkURL(context.Background(), "https://hooks.invalid/first"))
+	resolved := lookups.Load()
+	require.Positive(t, resolved)
+	require.Error(t, checkURL(context.Background(), "https://hooks.invalid:8443/second"))
+	require.ErrorIs(t, checkURL(context.Background(), "ftp://hooks.invalid/third"), ErrInvalidCallbackURL)
+	require.Equal(t, resolved, lookups.Load())
+	require.Error(t, checkURL(context.Background(), "https://other.invalid/first"))
+	require.Greater(t, lookups.Load(), resolved)
+}
+
+func TestShouldRejectInvalidNetworks(t *testing.T) {
+	_, err := NewGuard(&config.WebhookConfig{AllowedNetworks: []string{"10.0.0.1"}})
+	require.Error(t, err)
+}
+
+func TestShouldNotConnectToForbiddenAddresses(t *testing.T) {
+	called := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		called <- struct{}{}
+	}))
+	defer server.Close()
+
+	deadLetters := memory.NewListStorage[model.DeadLetter]()
+	cfg := &config.WebhookConfig{Timeout: time.Second, QueueSize: 1, DeadLetterRetention: time.Minute}
+	dispatcher := NewDispatcher(cfg, mustGuard(t, cfg), deadLetters, &countingRecorder{})
+	subscription := model.Subscription{Tenant: model.DefaultTenant, Address: "0x2", CallbackURL: server.URL}
+	dispatcher.deliver(context.Background(), delivery{subscription: subscription, transaction: model.TracedTransaction{Transaction: model.Transaction{Hash: "0x10"}}})
+
+	require.Empty(t, called)
+	letters := deadLetters.Peek(subscription.Key())
+	require.Len(t, letters, 1)
+	require.Contains(t, letters[0].LastError, ErrForbiddenCallback.Error())
+}
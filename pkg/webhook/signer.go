@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	SignatureHeader = "X-Etherscription-Signature"
+	TimestampHeader = "X-Etherscription-Timestamp"
+	signaturePrefix = "sha256="
+)
+
+// Signer computes HMAC-SHA256 signatures of webhook payloads.
+// The signed message is the unix timestamp and the body joined with a dot,
+// so receivers can reject replayed requests by checking the timestamp.
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret)}
+}
+
+func (s *Signer) Sign(timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Signer) Verify(timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(s.Sign(timestamp, body)), []byte(signature))
+}